@@ -21,6 +21,13 @@ type Expression interface {
     expressionNode()
 }
 
+// Pattern is the target of a binding. A plain identifier binds the whole value
+// while array and hashmap patterns destructure it.
+type Pattern interface {
+    Node
+    patternNode()
+}
+
 type Program struct {
     Statements []Statement
 }
@@ -50,6 +57,7 @@ type Identifier struct {
 }
 
 func (id *Identifier) expressionNode() {}
+func (id *Identifier) patternNode() {}
 func (id *Identifier) TokenLiteral() string {
     return id.Token.Literal
 }
@@ -57,9 +65,14 @@ func (id *Identifier) String() string {
     return id.Value
 }
 
+// AssignmentStatement assigns to Identifier, or to every name in Pattern for a
+// destructuring assignment such as "[a, b] = [b, a];"
 type AssignmentStatement struct {
     Token      token.Token
     Identifier *Identifier
+    Pattern    Pattern
+    // Target is set when assigning to a member or index, e.g. "p.x = 1" or "arr[0] = 1"
+    Target     Expression
     Value      Expression
 }
 
@@ -70,7 +83,14 @@ func (ls *AssignmentStatement) TokenLiteral() string {
 func (ls *AssignmentStatement) String() string {
     var out bytes.Buffer
 
-    out.WriteString(ls.Identifier.String())
+    switch {
+    case ls.Pattern != nil:
+        out.WriteString(ls.Pattern.String())
+    case ls.Target != nil:
+        out.WriteString(ls.Target.String())
+    default:
+        out.WriteString(ls.Identifier.String())
+    }
     out.WriteString(" = ")
 
     if ls.Value != nil {
@@ -81,6 +101,41 @@ func (ls *AssignmentStatement) String() string {
     return out.String()
 }
 
+// LetStatement declares a new binding in the current scope. The token is either
+// "let" or "const"; const bindings cannot be reassigned. Pattern is set instead
+// of Identifier for destructuring declarations such as "let {name, age} = person;"
+type LetStatement struct {
+    Token      token.Token
+    Identifier *Identifier
+    Pattern    Pattern
+    Value      Expression
+}
+
+func (ls *LetStatement) statementNode() {}
+func (ls *LetStatement) TokenLiteral() string {
+    return ls.Token.Literal
+}
+func (ls *LetStatement) IsConstant() bool {
+    return ls.Token.Type == token.CONST
+}
+func (ls *LetStatement) String() string {
+    var out bytes.Buffer
+
+    out.WriteString(ls.TokenLiteral() + " ")
+    if ls.Pattern != nil {
+        out.WriteString(ls.Pattern.String())
+    } else {
+        out.WriteString(ls.Identifier.String())
+    }
+
+    if ls.Value != nil {
+        out.WriteString(" = ")
+        out.WriteString(ls.Value.String())
+    }
+
+    out.WriteString(";")
+    return out.String()
+}
 
 type ReturnStatement struct {
     Token      token.Token
@@ -193,6 +248,13 @@ func (b *BooleanLiteral) expressionNode() {}
 func (b *BooleanLiteral) TokenLiteral() string { return b.Token.Literal }
 func (b *BooleanLiteral) String() string { return b.Token.Literal }
 
+type NullLiteral struct {
+    Token token.Token
+}
+func (n *NullLiteral) expressionNode() {}
+func (n *NullLiteral) TokenLiteral() string { return n.Token.Literal }
+func (n *NullLiteral) String() string { return n.Token.Literal }
+
 type StringLiteral struct {
     Token token.Token
     Value string
@@ -201,6 +263,27 @@ func (s *StringLiteral) expressionNode() {}
 func (s *StringLiteral) TokenLiteral() string { return s.Token.Literal }
 func (s *StringLiteral) String() string { return s.Token.Literal }
 
+// TemplateLiteral is a string with embedded expressions such as "a ${x} b".
+// Parts alternates between *StringLiteral text and the embedded expressions,
+// starting and ending with text
+type TemplateLiteral struct {
+    Token token.Token
+    Parts []Expression
+}
+func (tl *TemplateLiteral) expressionNode() {}
+func (tl *TemplateLiteral) TokenLiteral() string { return tl.Token.Literal }
+func (tl *TemplateLiteral) String() string {
+    var out bytes.Buffer
+    for i, part := range tl.Parts {
+        if i % 2 == 0 {
+            out.WriteString(part.String())
+        } else {
+            out.WriteString("${" + part.String() + "}")
+        }
+    }
+    return out.String()
+}
+
 type BlockStatement struct {
     Token token.Token
     Statements []Statement
@@ -241,9 +324,40 @@ func (ie *IfStatement) String() string {
     return out.String()
 }
 
+// Parameter is a single entry of a function's parameter list. Default is
+// evaluated at call time when no argument is supplied for the parameter. A rest
+// parameter collects the remaining positional arguments into an array. A
+// destructuring parameter has a Pattern and no Name.
+type Parameter struct {
+    Token token.Token
+    Name *Identifier
+    Pattern Pattern
+    Default Expression
+    Rest bool
+}
+func (p *Parameter) TokenLiteral() string { return p.Token.Literal }
+func (p *Parameter) String() string {
+    var target string
+    if p.Pattern != nil {
+        target = p.Pattern.String()
+    } else {
+        target = p.Name.String()
+    }
+
+    if p.Rest {
+        return "..." + target
+    }
+
+    if p.Default != nil {
+        return target + " = " + p.Default.String()
+    }
+
+    return target
+}
+
 type FunctionLiteral struct {
     Token token.Token
-    Parameters []*Identifier
+    Parameters []*Parameter
     Body *BlockStatement
 }
 func (fl *FunctionLiteral) expressionNode() {}
@@ -265,6 +379,32 @@ func (fl *FunctionLiteral) String() string {
     return out.String()
 }
 
+// LambdaLiteral is the concise form of a function literal. For example:
+// "x => x * 2" or "(a, b) => { return a + b; }". An expression body is stored as
+// a block with a single expression statement.
+type LambdaLiteral struct {
+    Token token.Token
+    Parameters []*Parameter
+    Body *BlockStatement
+}
+func (ll *LambdaLiteral) expressionNode() {}
+func (ll *LambdaLiteral) TokenLiteral() string { return ll.Token.Literal }
+func (ll *LambdaLiteral) String() string {
+    var out bytes.Buffer
+
+    params := []string {}
+    for _, p := range ll.Parameters {
+        params = append(params, p.String())
+    }
+
+    out.WriteString("(")
+    out.WriteString(strings.Join(params, ", "))
+    out.WriteString(") => ")
+    out.WriteString(ll.Body.String())
+
+    return out.String()
+}
+
 type FunctionStatement struct {
     Token token.Token
     Identifier *Identifier
@@ -290,6 +430,99 @@ func (fs *FunctionStatement) String() string {
     return out.String()
 }
 
+// StructStatement declares a struct type with fields and methods.
+// Fields use the parameter syntax: a name and an optional default.
+type StructStatement struct {
+    Token token.Token
+    Name *Identifier
+    Fields []*Parameter
+    Methods []*FunctionStatement
+}
+func (ss *StructStatement) statementNode() {}
+func (ss *StructStatement) TokenLiteral() string { return ss.Token.Literal }
+func (ss *StructStatement) String() string {
+    var out bytes.Buffer
+
+    out.WriteString("struct " + ss.Name.String() + " { ")
+    for _, field := range ss.Fields {
+        out.WriteString(field.String() + "; ")
+    }
+    for _, method := range ss.Methods {
+        out.WriteString(method.String() + " ")
+    }
+    out.WriteString("}")
+
+    return out.String()
+}
+
+// EnumVariant is a variant of an enum declaration. Fields is nil for variants
+// without a payload, e.g. "Red" as opposed to "Ok(value)".
+type EnumVariant struct {
+    Name *Identifier
+    Fields []*Identifier
+}
+func (ev *EnumVariant) String() string {
+    if ev.Fields == nil {
+        return ev.Name.String()
+    }
+
+    fields := []string{}
+    for _, field := range ev.Fields {
+        fields = append(fields, field.String())
+    }
+    return ev.Name.String() + "(" + strings.Join(fields, ", ") + ")"
+}
+
+// EnumStatement declares an enum. For example: "enum Result { Ok(value), Err(message) }"
+type EnumStatement struct {
+    Token token.Token
+    Name *Identifier
+    Variants []*EnumVariant
+}
+func (es *EnumStatement) statementNode() {}
+func (es *EnumStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *EnumStatement) String() string {
+    variants := []string{}
+    for _, variant := range es.Variants {
+        variants = append(variants, variant.String())
+    }
+    return "enum " + es.Name.String() + " { " + strings.Join(variants, ", ") + " }"
+}
+
+// ImportStatement loads a module. "import "lib/utils";" binds the module as
+// utils, while "import { a, b } from "lib/utils";" binds the listed exports.
+type ImportStatement struct {
+    Token token.Token
+    Path *StringLiteral
+    Names []*Identifier
+}
+func (is *ImportStatement) statementNode() {}
+func (is *ImportStatement) TokenLiteral() string { return is.Token.Literal }
+func (is *ImportStatement) String() string {
+    path := "\"" + is.Path.Value + "\""
+    if is.Names == nil {
+        return "import " + path + ";"
+    }
+
+    names := []string{}
+    for _, name := range is.Names {
+        names = append(names, name.String())
+    }
+    return "import { " + strings.Join(names, ", ") + " } from " + path + ";"
+}
+
+// ExportStatement makes the names a declaration binds visible to importers.
+// For example: "export func add(a, b) { ... }"
+type ExportStatement struct {
+    Token token.Token
+    Declaration Statement
+}
+func (es *ExportStatement) statementNode() {}
+func (es *ExportStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExportStatement) String() string {
+    return "export " + es.Declaration.String()
+}
+
 type CallExpression struct {
     Token token.Token
     FunctionLiteral Expression
@@ -313,6 +546,39 @@ func (ce *CallExpression) String() string {
     return out.String()
 }
 
+// PipeExpression passes Left as the first argument of the call on the Right.
+// For example: "data |> filter(isValid)" calls "filter(data, isValid)"
+type PipeExpression struct {
+    Token token.Token
+    Left Expression
+    Right Expression
+}
+func (pe *PipeExpression) expressionNode() {}
+func (pe *PipeExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PipeExpression) String() string {
+    return "(" + pe.Left.String() + " |> " + pe.Right.String() + ")"
+}
+
+// SpreadExpression expands an array into the surrounding call arguments or
+// array literal. For example: "add(...numbers)"
+type SpreadExpression struct {
+    Token token.Token
+    Value Expression
+}
+func (se *SpreadExpression) expressionNode() {}
+func (se *SpreadExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SpreadExpression) String() string { return "..." + se.Value.String() }
+
+// KeywordArgument is an argument passed by parameter name. For example: "f(b: 3)"
+type KeywordArgument struct {
+    Token token.Token
+    Name *Identifier
+    Value Expression
+}
+func (ka *KeywordArgument) expressionNode() {}
+func (ka *KeywordArgument) TokenLiteral() string { return ka.Token.Literal }
+func (ka *KeywordArgument) String() string { return ka.Name.String() + ": " + ka.Value.String() }
+
 type ArrayLiteral struct {
     Token token.Token
     Elements []Expression
@@ -335,10 +601,51 @@ func (a *ArrayLiteral) String() string {
     return out.String()
 }
 
+// TupleLiteral is a parenthesized list with at least one comma, or "()".
+// For example: "(1, 2)" or "(1,)"
+type TupleLiteral struct {
+    Token token.Token
+    Elements []Expression
+}
+
+func (tl *TupleLiteral) expressionNode() {}
+func (tl *TupleLiteral) TokenLiteral() string { return tl.Token.Literal }
+func (tl *TupleLiteral) String() string {
+    elements := []string{}
+    for _, exp := range tl.Elements {
+        elements = append(elements, exp.String())
+    }
+
+    if len(elements) == 1 {
+        return "(" + elements[0] + ",)"
+    }
+    return "(" + strings.Join(elements, ", ") + ")"
+}
+
+// SetLiteral is a braced list without keys. For example: "{1, 2, 3}".
+// "{}" is an empty hashmap rather than a set.
+type SetLiteral struct {
+    Token token.Token
+    Elements []Expression
+}
+
+func (sl *SetLiteral) expressionNode() {}
+func (sl *SetLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *SetLiteral) String() string {
+    elements := []string{}
+    for _, exp := range sl.Elements {
+        elements = append(elements, exp.String())
+    }
+
+    return "{" + strings.Join(elements, ", ") + "}"
+}
+
 type IndexExpression struct {
     Token token.Token
     Left Expression
     Index Expression
+    // Optional is set for "a?[i]", which is null when a is null
+    Optional bool
 }
 
 func (i *IndexExpression) expressionNode() {}
@@ -348,6 +655,9 @@ func (i *IndexExpression) String() string {
 
     out.WriteString("(")
     out.WriteString(i.Left.String())
+    if i.Optional {
+        out.WriteString("?")
+    }
     out.WriteString("[")
     out.WriteString(i.Index.String())
     out.WriteString("])")
@@ -355,9 +665,58 @@ func (i *IndexExpression) String() string {
     return out.String()
 }
 
+// SliceExpression takes a part of an array, tuple or string. Any of the
+// bounds can be omitted. For example: "arr[1:-1]" or "s[::2]"
+type SliceExpression struct {
+    Token token.Token
+    Left Expression
+    Start Expression
+    End Expression
+    Step Expression
+    Optional bool
+}
+
+func (se *SliceExpression) expressionNode() {}
+func (se *SliceExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SliceExpression) String() string {
+    bound := func(exp Expression) string {
+        if exp == nil {
+            return ""
+        }
+        return exp.String()
+    }
+
+    out := "(" + se.Left.String()
+    if se.Optional {
+        out += "?"
+    }
+    out += "[" + bound(se.Start) + ":" + bound(se.End)
+    if se.Step != nil {
+        out += ":" + se.Step.String()
+    }
+    return out + "])"
+}
+
+// MemberExpression accesses a field or method of a value. For example: "s.upper"
+type MemberExpression struct {
+    Token token.Token
+    Object Expression
+    Property *Identifier
+    // Optional is set for "a?.b", which is null when a is null
+    Optional bool
+}
+
+func (me *MemberExpression) expressionNode() {}
+func (me *MemberExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MemberExpression) String() string {
+    return me.Object.String() + me.Token.Literal + me.Property.String()
+}
+
 type HashMapLiteral struct {
     Token token.Token
     Map map[Expression]Expression
+    // Keys lists the keys of Map in source order
+    Keys []Expression
 }
 func (hm *HashMapLiteral) expressionNode() {}
 func (hm *HashMapLiteral) TokenLiteral() string { return hm.Token.Literal }
@@ -366,8 +725,8 @@ func (hm *HashMapLiteral) String() string {
 
     pairs := []string{}
 
-    for key, value := range hm.Map {
-        pairs = append(pairs, key.String() + ":" + value.String())
+    for _, key := range hm.Keys {
+        pairs = append(pairs, key.String() + ":" + hm.Map[key].String())
     }
 
     out.WriteString("{")
@@ -397,3 +756,176 @@ func (w *WhileStatement) String() string {
     return out.String()
 }
 
+
+// ArrayPattern destructures an array by position. For example: "[first, second, ...rest]"
+type ArrayPattern struct {
+    Token token.Token
+    Elements []Pattern
+    Rest *Identifier
+}
+
+func (ap *ArrayPattern) patternNode() {}
+func (ap *ArrayPattern) TokenLiteral() string { return ap.Token.Literal }
+func (ap *ArrayPattern) String() string {
+    elements := []string{}
+    for _, element := range ap.Elements {
+        elements = append(elements, element.String())
+    }
+
+    if ap.Rest != nil {
+        elements = append(elements, "..." + ap.Rest.String())
+    }
+
+    return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// HashMapPatternEntry destructures the value stored under Key. Shorthand entries
+// such as "{name}" bind the value of the "name" key to an identifier of the same name.
+type HashMapPatternEntry struct {
+    Key Expression
+    Value Pattern
+    Shorthand bool
+}
+
+func (e *HashMapPatternEntry) String() string {
+    if e.Shorthand {
+        return e.Value.String()
+    }
+    return e.Key.String() + ": " + e.Value.String()
+}
+
+// HashMapPattern destructures a hashmap by key. For example: "{name, age: years}"
+type HashMapPattern struct {
+    Token token.Token
+    Entries []*HashMapPatternEntry
+}
+
+func (hp *HashMapPattern) patternNode() {}
+func (hp *HashMapPattern) TokenLiteral() string { return hp.Token.Literal }
+func (hp *HashMapPattern) String() string {
+    entries := []string{}
+    for _, entry := range hp.Entries {
+        entries = append(entries, entry.String())
+    }
+
+    return "{" + strings.Join(entries, ", ") + "}"
+}
+
+// DefaultPattern provides a value for Target when the destructured element is missing.
+// For example: "[a, b = 2]"
+type DefaultPattern struct {
+    Token token.Token
+    Target Pattern
+    Default Expression
+}
+
+func (dp *DefaultPattern) patternNode() {}
+func (dp *DefaultPattern) TokenLiteral() string { return dp.Token.Literal }
+func (dp *DefaultPattern) String() string {
+    return dp.Target.String() + " = " + dp.Default.String()
+}
+
+// WildcardPattern matches any value without binding it: "_"
+type WildcardPattern struct {
+    Token token.Token
+}
+
+func (wp *WildcardPattern) patternNode() {}
+func (wp *WildcardPattern) TokenLiteral() string { return wp.Token.Literal }
+func (wp *WildcardPattern) String() string { return "_" }
+
+// LiteralPattern matches values equal to a literal. For example: "1", "-2.5", "\"ok\"" or "null"
+type LiteralPattern struct {
+    Token token.Token
+    Value Expression
+}
+
+func (lp *LiteralPattern) patternNode() {}
+func (lp *LiteralPattern) TokenLiteral() string { return lp.Token.Literal }
+func (lp *LiteralPattern) String() string { return lp.Value.String() }
+
+// TypePattern matches values of the named type and binds them to Target.
+// For example: "n: Integer" or "_: String"
+type TypePattern struct {
+    Token token.Token
+    Target Pattern
+    TypeName *Identifier
+}
+
+func (tp *TypePattern) patternNode() {}
+func (tp *TypePattern) TokenLiteral() string { return tp.Token.Literal }
+func (tp *TypePattern) String() string {
+    return tp.Target.String() + ": " + tp.TypeName.String()
+}
+
+// EnumPattern matches a variant of an enum and destructures its payload.
+// For example: "Result.Ok(value)" or "Color.Red"
+type EnumPattern struct {
+    Token token.Token
+    Enum *Identifier
+    Variant *Identifier
+    Payload []Pattern
+}
+
+func (ep *EnumPattern) patternNode() {}
+func (ep *EnumPattern) TokenLiteral() string { return ep.Token.Literal }
+func (ep *EnumPattern) String() string {
+    name := ep.Enum.String() + "." + ep.Variant.String()
+    if ep.Payload == nil {
+        return name
+    }
+
+    payload := []string{}
+    for _, pattern := range ep.Payload {
+        payload = append(payload, pattern.String())
+    }
+    return name + "(" + strings.Join(payload, ", ") + ")"
+}
+
+// MatchArm is a single "pattern if guard => body" entry of a match expression.
+// Like lambdas, an expression body is stored as a block with one expression statement.
+type MatchArm struct {
+    Pattern Pattern
+    Guard Expression
+    Body *BlockStatement
+}
+
+func (ma *MatchArm) String() string {
+    var out bytes.Buffer
+
+    out.WriteString(ma.Pattern.String())
+    if ma.Guard != nil {
+        out.WriteString(" if ")
+        out.WriteString(ma.Guard.String())
+    }
+    out.WriteString(" => ")
+    out.WriteString(ma.Body.String())
+
+    return out.String()
+}
+
+// MatchExpression evaluates the body of the first arm whose pattern matches Subject
+type MatchExpression struct {
+    Token token.Token
+    Subject Expression
+    Arms []*MatchArm
+}
+
+func (me *MatchExpression) expressionNode() {}
+func (me *MatchExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MatchExpression) String() string {
+    var out bytes.Buffer
+
+    arms := []string{}
+    for _, arm := range me.Arms {
+        arms = append(arms, arm.String())
+    }
+
+    out.WriteString("match (")
+    out.WriteString(me.Subject.String())
+    out.WriteString(") { ")
+    out.WriteString(strings.Join(arms, ", "))
+    out.WriteString(" }")
+
+    return out.String()
+}
@@ -2,21 +2,54 @@ package evaluator
 
 import (
 	"charm/object"
-	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
 )
 
-var builtins = map[string]*object.Builtin {
+// builtins holds every builtin function. It starts out empty and is filled
+// from the tables of each file in init, since the builtins call back into
+// the interpreter, which looks names up here.
+var builtins = map[string]*object.Builtin{}
+
+func init() {
+    tables := []map[string]*object.Builtin{
+        coreBuiltins,
+        collectionBuiltins,
+        fileBuiltins,
+        formatBuiltins,
+        jsonBuiltins,
+        regexBuiltins,
+        processBuiltins,
+    }
+    for _, table := range tables {
+        for name, builtin := range table {
+            builtins[name] = builtin
+        }
+    }
+}
+
+var coreBuiltins = map[string]*object.Builtin {
     "len": {
         Fn: func(args ...object.Object) object.Object {
             if len(args) != 1 {
                 return newError("wrong number of arguments. got=%d, want=1", len(args))
             }
+            if result, ok := callSpecialMethod(args[0], "__len__"); ok {
+                return result
+            }
 
             switch arg := args[0].(type) {
             case *object.String:
-                return &object.Integer{Value: int64(len(arg.Value))}
+                return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
             case *object.Array:
                 return &object.Integer{Value: int64(len(arg.Elements))}
+            case *object.HashMap:
+                return &object.Integer{Value: int64(len(arg.Map))}
+            case *object.Set:
+                return &object.Integer{Value: int64(len(arg.Elements))}
+            case *object.Tuple:
+                return &object.Integer{Value: int64(len(arg.Elements))}
             default:
                 return newError("argument to `len` not supported, got %s", args[0].Type())
             }
@@ -72,7 +105,7 @@ var builtins = map[string]*object.Builtin {
             hashMapObj := args[0].(*object.HashMap)
             keys := &object.Array{Elements: []object.Object{}}
 
-            for _, pair := range hashMapObj.Map {
+            for _, pair := range hashMapObj.Pairs() {
                 keys.Elements = append(keys.Elements, pair.Key)
             }
 
@@ -92,24 +125,187 @@ var builtins = map[string]*object.Builtin {
 
             hashMapObj := args[0].(*object.HashMap)
 
-            hashable, ok := args[1].(object.Hashable)
+            hashable, ok := object.AsHashable(args[1])
             if !ok {
                 return newError("unusable as a hashkey: %s", args[1].Type())
             }
 
-            delete(hashMapObj.Map, hashable.HashCode())
+            hashMapObj.Delete(hashable)
 
             return NULL
         },
     },
-    "print": {
+    "variants": {
+        Fn: func(args ...object.Object) object.Object {
+            if len(args) != 1 {
+                return newError("wrong number of arguments. got=%d, want=1", len(args))
+            }
+            enumType, ok := args[0].(*object.EnumType)
+            if !ok {
+                return newError("argument to `variants` must be ENUM, got %s", args[0].Type())
+            }
+
+            names := &object.Array{Elements: []object.Object{}}
+            for _, name := range enumVariantNames(enumType) {
+                names.Elements = append(names.Elements, &object.String{Value: name})
+            }
+
+            return names
+        },
+    },
+    "variant": {
+        Fn: func(args ...object.Object) object.Object {
+            if len(args) != 1 {
+                return newError("wrong number of arguments. got=%d, want=1", len(args))
+            }
+            enumValue, ok := args[0].(*object.EnumValue)
+            if !ok {
+                return newError("argument to `variant` must be an enum value, got %s", args[0].Type())
+            }
+
+            return &object.String{Value: enumValue.Variant.Name}
+        },
+    },
+    "set": {
+        Fn: func(args ...object.Object) object.Object {
+            set := object.NewSet()
+            if len(args) == 0 {
+                return set
+            }
+            if len(args) != 1 {
+                return newError("wrong number of arguments. got=%d, want=0 or 1", len(args))
+            }
+
+            var elements []object.Object
+            switch arg := args[0].(type) {
+            case *object.Array:
+                elements = arg.Elements
+            case *object.Tuple:
+                elements = arg.Elements
+            case *object.Set:
+                for _, element := range arg.Values() {
+                    elements = append(elements, element)
+                }
+            default:
+                return newError("argument to `set` must be ARRAY, TUPLE or SET, got %s", args[0].Type())
+            }
+
+            for _, element := range elements {
+                hashable, ok := object.AsHashable(element)
+                if !ok {
+                    return newError("unusable as set element: %s", element.Type())
+                }
+                set.Add(hashable)
+            }
+
+            return set
+        },
+    },
+    "int": {
+        Fn: func(args ...object.Object) object.Object {
+            if len(args) != 1 && len(args) != 2 {
+                return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+            }
+            if len(args) == 1 {
+                return toInteger(args[0], 10)
+            }
+
+            base, ok := args[1].(*object.Integer)
+            if !ok {
+                return newError("base argument to `int` must be INTEGER, got %s", args[1].Type())
+            }
+            if base.Value < 2 || base.Value > 36 {
+                return newError("base argument to `int` must be between 2 and 36, got %d", base.Value)
+            }
+            if args[0].Type() != object.STRING_OBJ {
+                return newError("argument to `int` with a base must be STRING, got %s", args[0].Type())
+            }
+            return toInteger(args[0], int(base.Value))
+        },
+    },
+    "float": {
+        Fn: func(args ...object.Object) object.Object {
+            if len(args) != 1 {
+                return newError("wrong number of arguments. got=%d, want=1", len(args))
+            }
+            return toFloat(args[0])
+        },
+    },
+    "str": {
+        Fn: func(args ...object.Object) object.Object {
+            if len(args) != 1 {
+                return newError("wrong number of arguments. got=%d, want=1", len(args))
+            }
+            return &object.String{Value: toString(args[0])}
+        },
+    },
+    "bool": {
+        Fn: func(args ...object.Object) object.Object {
+            if len(args) != 1 {
+                return newError("wrong number of arguments. got=%d, want=1", len(args))
+            }
+            return nativeBooltoBoolObject(isTruthy(args[0]))
+        },
+    },
+    "type": {
         Fn: func(args ...object.Object) object.Object {
+            if len(args) != 1 {
+                return newError("wrong number of arguments. got=%d, want=1", len(args))
+            }
+            return &object.String{Value: string(args[0].Type())}
+        },
+    },
+    "is_callable": {
+        Fn: func(args ...object.Object) object.Object {
+            if len(args) != 1 {
+                return newError("wrong number of arguments. got=%d, want=1", len(args))
+            }
+            return nativeBooltoBoolObject(isCallable(args[0]))
+        },
+    },
+    "print": {
+        ContextFn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+            if err := checkCall("print", ctx, args, 0, len(args), "sep", "end"); err != nil {
+                return err
+            }
+            sep, err := stringKeyword("print", ctx, "sep", " ")
+            if err != nil {
+                return err
+            }
+            end, err := stringKeyword("print", ctx, "end", "\n")
+            if err != nil {
+                return err
+            }
+
+            texts := []string{}
             for _, arg := range args {
-                fmt.Print(arg.Inspect(), " ")
+                texts = append(texts, toString(arg))
             }
-            fmt.Println()
+            io.WriteString(ctx.Runtime.Out, strings.Join(texts, sep) + end)
 
             return NULL
         },
     },
 }
+
+// checkCall validates the argument count and keyword names of a call to a
+// builtin that takes a call context
+func checkCall(name string, ctx *object.CallContext, args []object.Object, min, max int, keywords ...string) *object.Error {
+    if len(args) < min || len(args) > max {
+        if min == max {
+            return newError("wrong number of arguments. got=%d, want=%d", len(args), min)
+        }
+        return newError("wrong number of arguments. got=%d, want=%d to %d", len(args), min, max)
+    }
+
+    for keyword := range ctx.Keywords {
+        found := false
+        for _, allowed := range keywords {
+            found = found || keyword == allowed
+        }
+        if !found {
+            return newError("unexpected keyword argument %s in call to %s", keyword, name)
+        }
+    }
+    return nil
+}
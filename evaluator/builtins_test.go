@@ -1,9 +1,11 @@
 package evaluator
 
 import (
+	"bytes"
 	"charm/lexer"
 	"charm/object"
 	"charm/parser"
+	"math"
 	"testing"
 )
 
@@ -46,9 +48,9 @@ func TestBuiltinPushFunction(t *testing.T) {
         input string
         expected any
     } {
-        {`x = [1, 2, 3]; push(x, 4);`, []int{1, 2, 3, 4}},
-        {`x = []; push(x, 4);`, []int{4}},
-        {`x = "string"; push(x, 4);`, "argument to `push` must be ARRAY, got STRING"},
+        {`let x = [1, 2, 3]; push(x, 4);`, []int{1, 2, 3, 4}},
+        {`let x = []; push(x, 4);`, []int{4}},
+        {`let x = "string"; push(x, 4);`, "argument to `push` must be ARRAY, got STRING"},
     }
 
     for _, test := range tests {
@@ -90,8 +92,8 @@ func TestBuiltinPopFunction(t *testing.T) {
         expectedArray []int64
         expectedRetValue int64
     } {
-        {`x = [1, 2, 3]; pop(x);`, []int64{1, 2}, 3},
-        {`x = [1]; pop(x);`, []int64{}, 1},
+        {`let x = [1, 2, 3]; pop(x);`, []int64{1, 2}, 3},
+        {`let x = [1]; pop(x);`, []int64{}, 1},
     }
 
     for _, test := range tests {
@@ -139,7 +141,7 @@ func TestBuiltinPopFunction(t *testing.T) {
 }
 
 func TestBuiltinKeysFunction(t *testing.T) {
-    input := `two = "two";
+    input := `let two = "two";
     keys({
     "one": 10 - 9,
     two: 1 + 1,
@@ -199,7 +201,7 @@ func TestBuiltinKeysFunction(t *testing.T) {
 
 func TestBuiltinDeleteFunction(t *testing.T) {
     input := `
-    x = {
+    let x = {
     "one": 10 - 9,
     "two": 1 + 1,
     };
@@ -234,3 +236,428 @@ func TestBuiltinDeleteFunction(t *testing.T) {
         t.Fatalf("Missing entry")
     }
 }
+
+func TestBuiltinConversionFunctions(t *testing.T) {
+    tests := []struct {
+        input string
+        expected any
+    } {
+        {`int("42");`, 42},
+        {`int(" -7 ");`, -7},
+        {`int(3.9);`, 3},
+        {`int(-3.9);`, -3},
+        {`int(-9223372036854775808.0);`, -9223372036854775808},
+        {`int(true);`, 1},
+        {`int("ff", 16);`, 255},
+        {`float("2.5");`, 2.5},
+        {`float(2);`, 2.0},
+        {`float(false);`, 0.0},
+        {`str(42);`, "42"},
+        {`str([1, "a"]);`, "[1, a]"},
+        {`str(null);`, "null"},
+        {`bool(0);`, true},
+        {`bool(null);`, false},
+        {`bool("");`, true},
+        {`type(1);`, "INTEGER"},
+        {`type("a");`, "STRING"},
+        {`type(null);`, "NULL"},
+        {`struct P { x; } type(P(1));`, "P"},
+        {`is_callable(len);`, true},
+        {`is_callable(func() {});`, true},
+        {`is_callable("a".upper);`, true},
+        {`struct P { x; } is_callable(P);`, true},
+        {`struct P { x; } is_callable(P(1));`, false},
+        {`struct F { func __call__() { 1; } } is_callable(F());`, true},
+        {`is_callable(1);`, false},
+        {`"n = " + 1;`, "n = 1"},
+        {`1.5 + " apples";`, "1.5 apples"},
+        {`"list: " + [1, 2];`, "list: [1, 2]"},
+        {`"x" + null;`, "xnull"},
+        {`1 + 2.5;`, 3.5},
+    }
+
+    for _, test := range tests {
+        evaluated := evalTest(test.input)
+
+        switch expected := test.expected.(type) {
+        case int:
+            testIntegerObject(t, evaluated, int64(expected))
+        case float64:
+            testFloatObject(t, evaluated, expected)
+        case string:
+            testStringObject(t, evaluated, expected)
+        case bool:
+            testBooleanObject(t, evaluated, expected)
+        }
+    }
+}
+
+func TestBuiltinConversionErrors(t *testing.T) {
+    tests := []struct {
+        input string
+        expected string
+    } {
+        {`int("abc");`, `invalid literal for int() with base 10: "abc"`},
+        {`int("1.5");`, `invalid literal for int() with base 10: "1.5"`},
+        {`int("zz", 16);`, `invalid literal for int() with base 16: "zz"`},
+        {`int(1.5, 16);`, "argument to `int` with a base must be STRING, got FLOAT"},
+        {`int("1", 40);`, "base argument to `int` must be between 2 and 36, got 40"},
+        {`int("1", "2");`, "base argument to `int` must be INTEGER, got STRING"},
+        {`int([1]);`, "cannot convert ARRAY to INTEGER"},
+        {`int(float("inf"));`, "cannot convert +Inf to INTEGER"},
+        {`int(float("nan"));`, "cannot convert NaN to INTEGER"},
+        {`int(100000000000000000000.0);`, "cannot convert 1e+20 to INTEGER"},
+        {`int(9223372036854775808.0);`, "cannot convert 9.223372036854776e+18 to INTEGER"},
+        {`float("one");`, `invalid literal for float(): "one"`},
+        {`float(null);`, "cannot convert NULL to FLOAT"},
+        {`str();`, "wrong number of arguments. got=0, want=1"},
+        {`type(1, 2);`, "wrong number of arguments. got=2, want=1"},
+        {`1 - "a";`, "type mismatch: INTEGER - STRING"},
+    }
+
+    for _, test := range tests {
+        testErrorObject(t, evalTest(test.input), test.expected)
+    }
+}
+
+func TestCollectionBuiltins(t *testing.T) {
+    tests := []struct {
+        input string
+        expected any
+    } {
+        {"map([1, 2, 3], func(x) { x * 2; });", "[2, 4, 6]"},
+        {"map((1, 2), str);", "[1, 2]"},
+        {`map("ab", func(c) { c.upper(); });`, "[A, B]"},
+        {"[1, 2, 3] |> map(func(x) { x + 1; });", "[2, 3, 4]"},
+        {"filter([1, 2, 3, 4], func(x) { x > 2; });", "[3, 4]"},
+        {"reduce([1, 2, 3], func(a, b) { a + b; });", 6},
+        {"reduce([], func(a, b) { a + b; }, 10);", 10},
+        {`reduce(["a", "b"], func(acc, s) { acc + s; }, ">");`, ">ab"},
+        {"any([1, 2, 3], func(x) { x > 2; });", true},
+        {"any([]);", false},
+        {"all([1, 2, 3], func(x) { x > 0; });", true},
+        {"all([1, null, 3]);", false},
+        {"sort([3, 1, 2.5]);", "[1, 2.5, 3]"},
+        {`sort(["b", "c", "a"], reverse: true);`, "[c, b, a]"},
+        {`sort(["bb", "a", "ccc"], key: len);`, "[a, bb, ccc]"},
+        {"sort([1, 3, 2], compare: func(a, b) { b - a; });", "[3, 2, 1]"},
+        {`sort([(2, "a"), (1, "b"), (1, "a")]);`, "[(1, a), (1, b), (2, a)]"},
+        {`sort(["b1", "a1", "b0"], key: func(s) { s[0]; });`, "[a1, b1, b0]"},
+        {`sort([(1, "a"), (1, "b"), (0, "c")], key: func(p) { p[0]; }, reverse: true);`, "[(1, a), (1, b), (0, c)]"},
+        {`sort(["a1", "b1", "a2"], compare: func(x, y) { x.byte_len() - y.byte_len(); }, reverse: true);`, "[a1, b1, a2]"},
+        {"struct V { n; func __lt__(o) { self.n < o.n; } } sort([V(2), V(1)]);", "[V(n: 1), V(n: 2)]"},
+        {"let xs = [2, 1]; sort(xs); xs;", "[2, 1]"},
+        {"reverse([1, 2, 3]);", "[3, 2, 1]"},
+        {`reverse("héllo");`, "olléh"},
+        {"reverse((1, 2));", "(2, 1)"},
+        {`zip([1, 2, 3], "ab");`, "[(1, a), (2, b)]"},
+        {"zip([1], [2], [3]);", "[(1, 2, 3)]"},
+        {`enumerate(["a", "b"]);`, "[(0, a), (1, b)]"},
+        {`enumerate(["a"], 1);`, "[(1, a)]"},
+        {"flatten([1, [2, [3]], (4,)]);", "[1, 2, [3], 4]"},
+        {"flatten([1, [2, [3]]], 5);", "[1, 2, 3]"},
+        {"let groups = group_by([1, 2, 3, 4], func(x) { x > 2; }); (groups[false], groups[true]);", "([1, 2], [3, 4])"},
+        {"unique([1, 1.0, 2, [1], [1]]);", "[1, 2, [1]]"},
+        {`unique([2.0, 2, "a", "a", (1, 2), (1, 2), (1, [2]), (1, [2]), true, 1]);`, `[2.0, a, (1, 2), (1, [2]), true, 1]`},
+        {`unique("xy".repeat(5000).chars());`, "[x, y]"},
+    }
+
+    for _, test := range tests {
+        evaluated := evalTest(test.input)
+
+        switch expected := test.expected.(type) {
+        case int:
+            testIntegerObject(t, evaluated, int64(expected))
+        case bool:
+            testBooleanObject(t, evaluated, expected)
+        case string:
+            if evaluated.Inspect() != expected {
+                t.Errorf("wrong result for %q. expected=%q, got=%q", test.input, expected, evaluated.Inspect())
+            }
+        }
+    }
+}
+
+func TestCollectionBuiltinErrors(t *testing.T) {
+    tests := []struct {
+        input string
+        expected string
+    } {
+        {"map(1, str);", "argument to `map` must be iterable, got INTEGER"},
+        {"map([1], 2);", "argument to `map` must be callable, got INTEGER"},
+        {"map([1]);", "wrong number of arguments. got=1, want=2"},
+        {"map([1, 2], func(x) { x + true; });", "type mismatch: INTEGER + BOOLEAN"},
+        {"map([1], str, key: 1);", "unexpected keyword argument key in call to map"},
+        {"reduce([], func(a, b) { a + b; });", "reduce of empty sequence with no initial value"},
+        {`sort([1, "a"]);`, "cannot compare STRING with INTEGER"},
+        {"sort([1], key: len, compare: len);", "`sort` takes either key or compare, not both"},
+        {`sort([1, 2], compare: func(a, b) { "x"; });`, "compare function of `sort` must return INTEGER, got STRING"},
+        {"group_by([1], func(x) { [x]; });", "unusable as hashkey in `group_by`: ARRAY"},
+        {"flatten(1);", "argument to `flatten` must be ARRAY or TUPLE, got INTEGER"},
+        {"len([1], key: 1);", "keyword arguments not supported by builtin functions"},
+    }
+
+    for _, test := range tests {
+        testErrorObject(t, evalTest(test.input), test.expected)
+    }
+}
+
+func TestBuiltinCallContext(t *testing.T) {
+    builtins["twice"] = &object.Builtin{
+        ContextFn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+            once := ctx.Call(args[0], args[1])
+            if isError(once) {
+                return once
+            }
+            return ctx.Call(args[0], once)
+        },
+    }
+    defer delete(builtins, "twice")
+
+    testIntegerObject(t, evalTest("twice(func(x) { x * 3; }, 2);"), 18)
+}
+
+func TestJsonBuiltins(t *testing.T) {
+    tests := []struct {
+        json string
+        input string
+        expected string
+    } {
+        {`{"b": 1, "a": 2}`, "json_parse(text);", "{b: 1, a: 2}"},
+        {`[1, 1.0, 1e2, -0.5, "s", true, null]`, "map(json_parse(text), type);",
+            "[INTEGER, FLOAT, FLOAT, FLOAT, STRING, BOOLEAN, NULL]"},
+        {`{"a": {"b": [1, {"c": null}]}}`, `json_parse(text)["a"]["b"][1];`, "{c: null}"},
+        {`{"a": 1, "a": 2}`, "json_parse(text);", "{a: 2}"},
+        {`"é\n"`, "json_parse(text).len();", "2"},
+        {`{"b": 1, "a": [1.0, 2]}`, "json_stringify(json_parse(text));", `{"b":1,"a":[1.0,2]}`},
+        {`{"b": 1, "a": [1.0, 2]}`, "json_stringify(json_parse(text), sort_keys: true);", `{"a":[1.0,2],"b":1}`},
+        {`{"a": [1, {}], "b": []}`, "json_stringify(json_parse(text), indent: 2);",
+            "{\n  \"a\": [\n    1,\n    {}\n  ],\n  \"b\": []\n}"},
+        {`[1]`, `json_stringify(json_parse(text), indent: "--");`, "[\n--1\n]"},
+        {``, `json_stringify(("x<>", 2.5, 1000000000000000000000.0, null));`, `["x<>",2.5,1e+21,null]`},
+        {``, `json_stringify({"k": text});`, `{"k":""}`},
+        {``, `json_parse(json_stringify({"f": 3.0, "i": 3}))["f"] |> type;`, "FLOAT"},
+    }
+
+    for _, test := range tests {
+        program := parser.New(lexer.New(test.input)).ParseProgram()
+        env := object.NewEnvironment()
+        env.Declare("text", &object.String{Value: test.json}, false)
+
+        evaluated := Eval(program, env)
+        if evaluated.Inspect() != test.expected {
+            t.Errorf("wrong result for %q. expected=%q, got=%q", test.input, test.expected, evaluated.Inspect())
+        }
+    }
+}
+
+func TestJsonBuiltinErrors(t *testing.T) {
+    tests := []struct {
+        json string
+        input string
+        expected string
+    } {
+        {`[1, 2`, "json_parse(text);", "invalid JSON: unexpected end of input"},
+        {`{"a" 1}`, "json_parse(text);", "invalid JSON at offset 6: invalid character '1' after object key"},
+        {`[1] x`, "json_parse(text);", "invalid JSON at offset 3: unexpected data after value"},
+        {`99999999999999999999`, "json_parse(text);", "invalid JSON at offset 20: integer out of range: 99999999999999999999"},
+        {``, "json_parse(1);", "argument to `json_parse` must be STRING, got INTEGER"},
+        {``, "json_stringify({1: 2});", "cannot encode hashmap key 1 as JSON: keys must be STRING, got INTEGER"},
+        {``, "json_stringify([func() {}]);", "cannot encode FUNCTION as JSON"},
+        {``, "json_stringify({1, 2});", "cannot encode SET as JSON"},
+        {``, `import "math"; json_stringify(math.nan);`, "cannot encode NaN as JSON"},
+        {``, "let a = [1]; a.push(a); json_stringify(a);", "cannot encode cyclic ARRAY as JSON"},
+        {``, "json_stringify(1, pretty: true);", "unexpected keyword argument pretty in call to json_stringify"},
+        {``, "json_stringify(1, indent: 1.5);", "indent of `json_stringify` must be INTEGER or STRING, got FLOAT"},
+        {``, "json_stringify(1, indent: 9223372036854775807);", "indent of `json_stringify` must be at most 100, got 9223372036854775807"},
+    }
+
+    for _, test := range tests {
+        program := parser.New(lexer.New(test.input)).ParseProgram()
+        env := object.NewEnvironment()
+        env.Declare("text", &object.String{Value: test.json}, false)
+
+        testErrorObject(t, Eval(program, env), test.expected)
+    }
+}
+
+func TestRegexBuiltin(t *testing.T) {
+    tests := []struct {
+        text string
+        input string
+        expected string
+    } {
+        {"", `regex("a+b");`, `regex("a+b")`},
+        {"", `type(regex("a"));`, "REGEX"},
+        {"", `regex("a") == regex("a");`, "true"},
+        {"ERROR 12:30 disk", `regex("^ERROR").matches(text);`, "true"},
+        {"INFO 12:30 disk", `regex("^ERROR").matches(text);`, "false"},
+        {"é 12:30", `regex("(\d+):(\d+)").match(text);`, `{text: 12:30, start: 2, end: 7, groups: [12, 30], named: {}}`},
+        {"12:30", `regex("(?P<h>\d+):(?P<m>\d+)(x)?").match(text)["named"];`, "{h: 12, m: 30}"},
+        {"12:30", `regex("(\d+)(x)?").match(text)["groups"];`, "[12, null]"},
+        {"abc", `regex("\d").match(text);`, "null"},
+        {"a1 b22 c333", `regex("\d+").find_all(text);`, "[1, 22, 333]"},
+        {"a1 b22 c333", `regex("\d+").find_all(text, 2);`, "[1, 22]"},
+        {"k=1 j=2", `map(regex("(?P<key>\w)=(?P<value>\d)").match_all(text), func(m) { m["named"]["key"]; });`, "[k, j]"},
+        {"a, b;c", `regex("[,;] *").split(text);`, "[a, b, c]"},
+        {"a, b;c", `regex("[,;] *").split(text, 1);`, "[a, b;c]"},
+        {"2024-01-05", `regex("(?P<y>\d+)-(\d+)-(\d+)").replace(text, "$3.$2.$y");`, "05.01.2024"},
+        {"a1 b22", `regex("\d+").replace(text, "#", 1);`, "a# b22"},
+        {"a1 b22", `regex("\d+").replace(text, func(m) { str(int(m["text"]) * 2); });`, "a2 b44"},
+        {"a b", `regex("\w").replace(text, func(m) { m["text"].upper(); });`, "A B"},
+        {"ab", `regex("x").replace(text, func(m) { 1; });`, "ab"},
+    }
+
+    for _, test := range tests {
+        program := parser.New(lexer.New(test.input)).ParseProgram()
+        env := object.NewEnvironment()
+        env.Declare("text", &object.String{Value: test.text}, false)
+
+        evaluated := Eval(program, env)
+        if evaluated.Inspect() != test.expected {
+            t.Errorf("wrong result for %q. expected=%q, got=%q", test.input, test.expected, evaluated.Inspect())
+        }
+    }
+}
+
+func TestRegexBuiltinErrors(t *testing.T) {
+    tests := []struct {
+        input string
+        expected string
+    } {
+        {`regex("(a");`, "invalid regex \"(a\": missing closing ): `(a`"},
+        {`regex(1);`, "argument to `regex` must be STRING, got INTEGER"},
+        {`regex("a").match(1);`, "argument to `match` must be STRING, got INTEGER"},
+        {`regex("a").replace("a", func(m) { 1; });`, "replacement function of `replace` must return STRING, got INTEGER"},
+        {`regex("a").replace("a", func(m) { m["nope"].upper(); });`, "NULL has no member upper"},
+        {`regex("a").replace("a", "b", count: 1);`, "keyword arguments not supported by builtin methods"},
+        {`regex("a").split();`, "wrong number of arguments to split: expected 1 to 2, got 0"},
+    }
+
+    for _, test := range tests {
+        testErrorObject(t, evalTest(test.input), test.expected)
+    }
+}
+
+func TestRegexCache(t *testing.T) {
+    runtime := object.NewRuntime()
+    first := evalWithRuntime(`regex("cache[d]");`, runtime)
+    second := evalWithRuntime(`let p = "cache" + "[d]"; regex(p);`, runtime)
+    if first != second {
+        t.Errorf("regex with the same pattern was compiled twice")
+    }
+    if evalTest(`regex("cache[d]");`) == first {
+        t.Errorf("regex was shared between runtimes")
+    }
+
+    evalWithRuntime(`let n = 0; while (n < 300) { regex("p" + str(n)); n = n + 1; }`, runtime)
+    if _, ok := runtime.CachedRegex("p0"); ok {
+        t.Errorf("least recently used pattern was kept")
+    }
+    if _, ok := runtime.CachedRegex("p299"); !ok {
+        t.Errorf("most recently used pattern was dropped")
+    }
+
+    shadowed := evalTest(`let regex = func(p) { p; }; regex("cache[d]");`)
+    if shadowed.Inspect() != "cache[d]" {
+        t.Errorf("a user-defined regex was bypassed. got=%s", shadowed.Inspect())
+    }
+}
+
+func TestFormatBuiltin(t *testing.T) {
+    tests := []struct {
+        input string
+        expected string
+    } {
+        {`format("%d items", 3);`, "3 items"},
+        {`format("[%5d|%-5d|%05d]", 42, 42, 42);`, "[   42|42   |00042]"},
+        {`format("%+d %x %X %o %b", 5, 255, 255, 8, 5);`, "+5 ff FF 10 101"},
+        {`format("%.2f %8.3f %e", 3.14159, 2, 1234.5);`, "3.14    2.000 1.234500e+03"},
+        {`format("%g %g", 0.1, 100000000.0);`, "0.1 1e+08"},
+        {`format("%s|%6s|%-6s|%.2s", "héllo", "ab", "ab", "héllo");`, "héllo|    ab|ab    |hé"},
+        {`format("%v %v %s", [1, 2.5], null, {"a": true});`, "[1, 2.5] null {a: true}"},
+        {`format("%q", "a b");`, `"a b"`},
+        {`format("%c%c", 72, 105);`, "Hi"},
+        {`format("%t", 1 < 2);`, "true"},
+        {`format("100%%");`, "100%"},
+        {`format("%x", "hi");`, "6869"},
+    }
+
+    for _, test := range tests {
+        evaluated := evalTest(test.input)
+        if evaluated.Inspect() != test.expected {
+            t.Errorf("wrong result for %q. expected=%q, got=%q", test.input, test.expected, evaluated.Inspect())
+        }
+    }
+}
+
+func TestFormatBuiltinErrors(t *testing.T) {
+    tests := []struct {
+        input string
+        expected string
+    } {
+        {`format("%d", "x");`, "%d in `format` requires INTEGER, got STRING"},
+        {`format("%.1f", "x");`, "%.1f in `format` requires INTEGER or FLOAT, got STRING"},
+        {`format("%d %d", 1);`, "wrong number of arguments to `format`: the format string uses 2, got 1"},
+        {`format("%d", 1, 2);`, "wrong number of arguments to `format`: the format string uses 1, got 2"},
+        {`format("%y", 1);`, "unknown verb %y in the format string of `format`"},
+        {`format("50%");`, "incomplete verb % at the end of the format string of `format`"},
+        {`format(1);`, "format string of `format` must be STRING, got INTEGER"},
+        {`printf("%t", 1);`, "%t in `printf` requires BOOLEAN, got INTEGER"},
+        {`print(1, sep: 2);`, "sep of `print` must be STRING, got INTEGER"},
+        {`print(1, flush: true);`, "unexpected keyword argument flush in call to print"},
+    }
+
+    for _, test := range tests {
+        testErrorObject(t, evalTest(test.input), test.expected)
+    }
+}
+
+func TestPrintOutput(t *testing.T) {
+    tests := []struct {
+        input string
+        expected string
+    } {
+        {`print("a", 1, 2.5);`, "a 1 2.5\n"},
+        {`print();`, "\n"},
+        {`print(1, 2, 3, sep: ", ");`, "1, 2, 3\n"},
+        {`print("no newline", end: "");`, "no newline"},
+        {`printf("%-4s|%3d", "ab", 7); printf("!");`, "ab  |  7!"},
+    }
+
+    for _, test := range tests {
+        var out bytes.Buffer
+        runtime := object.NewRuntime()
+        runtime.Out = &out
+        evalWithRuntime(test.input, runtime)
+
+        if out.String() != test.expected {
+            t.Errorf("wrong output for %q. expected=%q, got=%q", test.input, test.expected, out.String())
+        }
+    }
+}
+
+func TestFloatInspect(t *testing.T) {
+    tests := []struct {
+        value float64
+        expected string
+    } {
+        {5.6, "5.6"},
+        {2, "2.0"},
+        {-0.5, "-0.5"},
+        {0.30000000000000004, "0.30000000000000004"},
+        {1e21, "1e+21"},
+        {1234567890123456.0, "1234567890123456.0"},
+        {0.00001, "1e-05"},
+        {0, "0.0"},
+        {math.Inf(1), "+Inf"},
+        {math.NaN(), "NaN"},
+    }
+
+    for _, test := range tests {
+        inspected := (&object.Float{Value: test.value}).Inspect()
+        if inspected != test.expected {
+            t.Errorf("wrong inspect for %v. expected=%q, got=%q", test.value, test.expected, inspected)
+        }
+    }
+}
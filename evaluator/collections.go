@@ -0,0 +1,527 @@
+package evaluator
+
+import (
+	"charm/object"
+	"cmp"
+	"sort"
+	"strings"
+)
+
+// The collection builtins call back into the interpreter through their call
+// context. Each takes the collection first, so that "xs |> map(f)" reads
+// naturally, and returns a new array instead of modifying its argument.
+var collectionBuiltins = map[string]*object.Builtin{
+    "map": {ContextFn: builtinMap},
+    "filter": {ContextFn: builtinFilter},
+    "reduce": {ContextFn: builtinReduce},
+    "any": {ContextFn: builtinAny},
+    "all": {ContextFn: builtinAll},
+    "sort": {ContextFn: builtinSort},
+    "reverse": {ContextFn: builtinReverse},
+    "zip": {ContextFn: builtinZip},
+    "enumerate": {ContextFn: builtinEnumerate},
+    "flatten": {ContextFn: builtinFlatten},
+    "group_by": {ContextFn: builtinGroupBy},
+    "unique": {ContextFn: builtinUnique},
+}
+
+// iterate lists the elements of an array, tuple or set, the characters of a
+// string or the keys of a hashmap
+func iterate(name string, obj object.Object) ([]object.Object, *object.Error) {
+    switch obj := obj.(type) {
+    case *object.Array:
+        return obj.Elements, nil
+    case *object.Tuple:
+        return obj.Elements, nil
+    case *object.Set:
+        elements := []object.Object{}
+        for _, element := range obj.Values() {
+            elements = append(elements, element)
+        }
+        return elements, nil
+    case *object.String:
+        chars := []object.Object{}
+        for _, char := range obj.Value {
+            chars = append(chars, &object.String{Value: string(char)})
+        }
+        return chars, nil
+    case *object.HashMap:
+        keys := []object.Object{}
+        for _, pair := range obj.Pairs() {
+            keys = append(keys, pair.Key)
+        }
+        return keys, nil
+    default:
+        return nil, newError("argument to `%s` must be iterable, got %s", name, obj.Type())
+    }
+}
+
+func callbackArgument(name string, arg object.Object) *object.Error {
+    if !isCallable(arg) {
+        return newError("argument to `%s` must be callable, got %s", name, arg.Type())
+    }
+    return nil
+}
+
+// mapElements applies fn to every element, stopping at the first error
+func mapElements(ctx *object.CallContext, fn object.Object, elements []object.Object) ([]object.Object, *object.Error) {
+    results := []object.Object{}
+    for _, element := range elements {
+        result := ctx.Call(fn, element)
+        if isError(result) {
+            return nil, result.(*object.Error)
+        }
+        results = append(results, result)
+    }
+    return results, nil
+}
+
+// collectionArguments is the common prologue of builtins called as
+// name(collection, fn)
+func collectionArguments(name string, ctx *object.CallContext, args []object.Object) ([]object.Object, *object.Error) {
+    if err := checkCall(name, ctx, args, 2, 2); err != nil {
+        return nil, err
+    }
+    elements, err := iterate(name, args[0])
+    if err != nil {
+        return nil, err
+    }
+    if err := callbackArgument(name, args[1]); err != nil {
+        return nil, err
+    }
+    return elements, nil
+}
+
+func builtinMap(ctx *object.CallContext, args ...object.Object) object.Object {
+    elements, err := collectionArguments("map", ctx, args)
+    if err != nil {
+        return err
+    }
+    results, err := mapElements(ctx, args[1], elements)
+    if err != nil {
+        return err
+    }
+    return &object.Array{Elements: results}
+}
+
+func builtinFilter(ctx *object.CallContext, args ...object.Object) object.Object {
+    elements, err := collectionArguments("filter", ctx, args)
+    if err != nil {
+        return err
+    }
+
+    kept := []object.Object{}
+    for _, element := range elements {
+        keep := ctx.Call(args[1], element)
+        if isError(keep) {
+            return keep
+        }
+        if isTruthy(keep) {
+            kept = append(kept, element)
+        }
+    }
+    return &object.Array{Elements: kept}
+}
+
+// reduce(xs, fn, initial) folds xs from the left with fn(accumulator, element).
+// Without initial the first element is the starting accumulator.
+func builtinReduce(ctx *object.CallContext, args ...object.Object) object.Object {
+    if err := checkCall("reduce", ctx, args, 2, 3); err != nil {
+        return err
+    }
+    elements, err := iterate("reduce", args[0])
+    if err != nil {
+        return err
+    }
+    if err := callbackArgument("reduce", args[1]); err != nil {
+        return err
+    }
+
+    if len(args) == 2 {
+        if len(elements) == 0 {
+            return newError("reduce of empty sequence with no initial value")
+        }
+        args = append(args, elements[0])
+        elements = elements[1:]
+    }
+
+    accumulator := args[2]
+    for _, element := range elements {
+        accumulator = ctx.Call(args[1], accumulator, element)
+        if isError(accumulator) {
+            return accumulator
+        }
+    }
+    return accumulator
+}
+
+// predicateBuiltin builds any and all. Without a predicate the truthiness of
+// the elements themselves is tested.
+func predicateBuiltin(name string, ctx *object.CallContext, args []object.Object, want bool) object.Object {
+    if err := checkCall(name, ctx, args, 1, 2); err != nil {
+        return err
+    }
+    elements, err := iterate(name, args[0])
+    if err != nil {
+        return err
+    }
+    if len(args) == 2 {
+        if err := callbackArgument(name, args[1]); err != nil {
+            return err
+        }
+    }
+
+    for _, element := range elements {
+        result := element
+        if len(args) == 2 {
+            if result = ctx.Call(args[1], element); isError(result) {
+                return result
+            }
+        }
+        if isTruthy(result) == want {
+            return nativeBooltoBoolObject(want)
+        }
+    }
+    return nativeBooltoBoolObject(!want)
+}
+
+func builtinAny(ctx *object.CallContext, args ...object.Object) object.Object {
+    return predicateBuiltin("any", ctx, args, true)
+}
+
+func builtinAll(ctx *object.CallContext, args ...object.Object) object.Object {
+    return predicateBuiltin("all", ctx, args, false)
+}
+
+// compareObjects orders numbers, strings, datetimes, durations, and arrays or
+// tuples of them lexicographically. Instances are ordered by their __lt__ method.
+func compareObjects(left, right object.Object) (int, *object.Error) {
+    switch {
+    case isNumber(left) && isNumber(right):
+        return compareNumbers("sort", left, right)
+    case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+        return strings.Compare(left.(*object.String).Value, right.(*object.String).Value), nil
+    case left.Type() == object.DATETIME_OBJ && right.Type() == object.DATETIME_OBJ:
+        return left.(*object.DateTime).Time.Compare(right.(*object.DateTime).Time), nil
+    case left.Type() == object.DURATION_OBJ && right.Type() == object.DURATION_OBJ:
+        return cmp.Compare(left.(*object.Duration).Value, right.(*object.Duration).Value), nil
+    }
+
+    leftElements, leftIsSequence := sequenceElements(left)
+    rightElements, rightIsSequence := sequenceElements(right)
+    if leftIsSequence && rightIsSequence {
+        for i := 0; i < len(leftElements) && i < len(rightElements); i++ {
+            compared, err := compareObjects(leftElements[i], rightElements[i])
+            if err != nil || compared != 0 {
+                return compared, err
+            }
+        }
+        return compareNumbers("sort", &object.Integer{Value: int64(len(leftElements))},
+            &object.Integer{Value: int64(len(rightElements))})
+    }
+
+    if less, ok := callSpecialMethod(left, "__lt__", right); ok {
+        if isError(less) {
+            return 0, less.(*object.Error)
+        }
+        if isTruthy(less) {
+            return -1, nil
+        }
+        greater, _ := callSpecialMethod(right, "__lt__", left)
+        if isError(greater) {
+            return 0, greater.(*object.Error)
+        }
+        if isTruthy(greater) {
+            return 1, nil
+        }
+        return 0, nil
+    }
+
+    return 0, newError("cannot compare %s with %s", left.Type(), right.Type())
+}
+
+func sequenceElements(obj object.Object) ([]object.Object, bool) {
+    switch obj := obj.(type) {
+    case *object.Array:
+        return obj.Elements, true
+    case *object.Tuple:
+        return obj.Elements, true
+    default:
+        return nil, false
+    }
+}
+
+// sort(xs) returns the elements of xs in ascending order. The sort is stable
+// and takes the keyword arguments key, a function whose results are compared
+// instead of the elements, compare, a function of two elements returning a
+// negative, zero or positive INTEGER, and reverse.
+func builtinSort(ctx *object.CallContext, args ...object.Object) object.Object {
+    if err := checkCall("sort", ctx, args, 1, 1, "key", "compare", "reverse"); err != nil {
+        return err
+    }
+    elements, err := iterate("sort", args[0])
+    if err != nil {
+        return err
+    }
+
+    key, hasKey := ctx.Keywords["key"]
+    compare, hasCompare := ctx.Keywords["compare"]
+    if hasKey && hasCompare {
+        return newError("`sort` takes either key or compare, not both")
+    }
+
+    keys := elements
+    if hasKey {
+        if err := callbackArgument("sort", key); err != nil {
+            return err
+        }
+        if keys, err = mapElements(ctx, key, elements); err != nil {
+            return err
+        }
+    }
+    if hasCompare {
+        if err := callbackArgument("sort", compare); err != nil {
+            return err
+        }
+    }
+
+    order := make([]int, len(elements))
+    for i := range order {
+        order[i] = i
+    }
+
+    // reverse flips the comparison rather than the result, so that equal
+    // elements keep their order either way
+    reverse, hasReverse := ctx.Keywords["reverse"]
+    descending := hasReverse && isTruthy(reverse)
+    less := func(compared int64) bool {
+        if descending {
+            return compared > 0
+        }
+        return compared < 0
+    }
+
+    var sortErr *object.Error
+    sort.SliceStable(order, func(i, j int) bool {
+        if sortErr != nil {
+            return false
+        }
+        left, right := keys[order[i]], keys[order[j]]
+
+        if !hasCompare {
+            compared, err := compareObjects(left, right)
+            sortErr = err
+            return less(int64(compared))
+        }
+
+        result := ctx.Call(compare, left, right)
+        if isError(result) {
+            sortErr = result.(*object.Error)
+            return false
+        }
+        integer, ok := result.(*object.Integer)
+        if !ok {
+            sortErr = newError("compare function of `sort` must return INTEGER, got %s", result.Type())
+            return false
+        }
+        return less(integer.Value)
+    })
+    if sortErr != nil {
+        return sortErr
+    }
+
+    sorted := make([]object.Object, len(elements))
+    for i, index := range order {
+        sorted[i] = elements[index]
+    }
+    return &object.Array{Elements: sorted}
+}
+
+// reverse keeps the type of strings and tuples and returns an array otherwise
+func builtinReverse(ctx *object.CallContext, args ...object.Object) object.Object {
+    if err := checkCall("reverse", ctx, args, 1, 1); err != nil {
+        return err
+    }
+    elements, err := iterate("reverse", args[0])
+    if err != nil {
+        return err
+    }
+
+    reversed := make([]object.Object, len(elements))
+    for i, element := range elements {
+        reversed[len(elements) - 1 - i] = element
+    }
+
+    switch args[0].(type) {
+    case *object.String:
+        var out strings.Builder
+        for _, char := range reversed {
+            out.WriteString(char.(*object.String).Value)
+        }
+        return &object.String{Value: out.String()}
+    case *object.Tuple:
+        return &object.Tuple{Elements: reversed}
+    default:
+        return &object.Array{Elements: reversed}
+    }
+}
+
+// zip pairs up the elements of its arguments into tuples, stopping at the
+// shortest
+func builtinZip(ctx *object.CallContext, args ...object.Object) object.Object {
+    if err := checkCall("zip", ctx, args, 1, len(args)); err != nil {
+        return err
+    }
+
+    sequences := [][]object.Object{}
+    shortest := -1
+    for _, arg := range args {
+        elements, err := iterate("zip", arg)
+        if err != nil {
+            return err
+        }
+        sequences = append(sequences, elements)
+        if shortest < 0 || len(elements) < shortest {
+            shortest = len(elements)
+        }
+    }
+
+    zipped := []object.Object{}
+    for i := 0; i < shortest; i++ {
+        tuple := &object.Tuple{}
+        for _, elements := range sequences {
+            tuple.Elements = append(tuple.Elements, elements[i])
+        }
+        zipped = append(zipped, tuple)
+    }
+    return &object.Array{Elements: zipped}
+}
+
+// enumerate pairs every element with its index, counting from the optional start
+func builtinEnumerate(ctx *object.CallContext, args ...object.Object) object.Object {
+    if err := checkCall("enumerate", ctx, args, 1, 2); err != nil {
+        return err
+    }
+    elements, err := iterate("enumerate", args[0])
+    if err != nil {
+        return err
+    }
+    var start int64
+    if len(args) == 2 {
+        if start, err = integerArgument("enumerate", args[1]); err != nil {
+            return err
+        }
+    }
+
+    pairs := []object.Object{}
+    for i, element := range elements {
+        index := &object.Integer{Value: start + int64(i)}
+        pairs = append(pairs, &object.Tuple{Elements: []object.Object{index, element}})
+    }
+    return &object.Array{Elements: pairs}
+}
+
+// flatten splices nested arrays and tuples into their parent, one level deep
+// or as many levels as the optional depth
+func builtinFlatten(ctx *object.CallContext, args ...object.Object) object.Object {
+    if err := checkCall("flatten", ctx, args, 1, 2); err != nil {
+        return err
+    }
+    elements, isSequence := sequenceElements(args[0])
+    if !isSequence {
+        return newError("argument to `flatten` must be ARRAY or TUPLE, got %s", args[0].Type())
+    }
+    depth := int64(1)
+    if len(args) == 2 {
+        var err *object.Error
+        if depth, err = integerArgument("flatten", args[1]); err != nil {
+            return err
+        }
+    }
+
+    return &object.Array{Elements: flattenElements(elements, depth)}
+}
+
+func flattenElements(elements []object.Object, depth int64) []object.Object {
+    flattened := []object.Object{}
+    for _, element := range elements {
+        if nested, ok := sequenceElements(element); ok && depth > 0 {
+            flattened = append(flattened, flattenElements(nested, depth - 1)...)
+        } else {
+            flattened = append(flattened, element)
+        }
+    }
+    return flattened
+}
+
+// group_by(xs, fn) maps each result of fn to the array of elements giving it
+func builtinGroupBy(ctx *object.CallContext, args ...object.Object) object.Object {
+    elements, err := collectionArguments("group_by", ctx, args)
+    if err != nil {
+        return err
+    }
+
+    groups := object.NewHashMap()
+    for _, element := range elements {
+        key := ctx.Call(args[1], element)
+        if isError(key) {
+            return key
+        }
+        hashable, ok := object.AsHashable(key)
+        if !ok {
+            return newError("unusable as hashkey in `group_by`: %s", key.Type())
+        }
+
+        pair, ok := groups.Map[hashable.HashCode()]
+        if !ok {
+            pair = object.Pair{Key: key, Value: &object.Array{Elements: []object.Object{}}}
+            groups.Put(hashable, pair.Value)
+        }
+        group := pair.Value.(*object.Array)
+        group.Elements = append(group.Elements, element)
+    }
+    return groups
+}
+
+// unique keeps the first of every group of equal elements
+func builtinUnique(ctx *object.CallContext, args ...object.Object) object.Object {
+    if err := checkCall("unique", ctx, args, 1, 1); err != nil {
+        return err
+    }
+    elements, err := iterate("unique", args[0])
+    if err != nil {
+        return err
+    }
+
+    // hashable elements are looked up by hash code. The others, like floats
+    // and arrays, are compared with every kept element, as 1.0 == 1.
+    kept := []object.Object{}
+    buckets := map[uint64][]object.Object{}
+    unhashable := []object.Object{}
+    contains := func(candidates []object.Object, element object.Object) bool {
+        for _, candidate := range candidates {
+            if object.Equal(candidate, element) {
+                return true
+            }
+        }
+        return false
+    }
+
+    for _, element := range elements {
+        hashable, ok := object.AsHashable(element)
+        if ok {
+            code := hashable.HashCode()
+            if contains(buckets[code], element) || contains(unhashable, element) {
+                continue
+            }
+            buckets[code] = append(buckets[code], element)
+        } else {
+            if contains(kept, element) {
+                continue
+            }
+            unhashable = append(unhashable, element)
+        }
+        kept = append(kept, element)
+    }
+    return &object.Array{Elements: kept}
+}
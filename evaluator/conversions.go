@@ -0,0 +1,83 @@
+package evaluator
+
+import (
+	"charm/object"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// The conversion rules below back the int, float, str and bool builtins. The
+// evaluator uses the same rules for mixed operands: numbers are promoted with
+// toFloat and "+" with a string operand concatenates with toString.
+
+// toInteger converts obj to an Integer, parsing strings in the given base.
+// Floats are truncated toward zero and must fit in an Integer.
+func toInteger(obj object.Object, base int) object.Object {
+    switch obj := obj.(type) {
+    case *object.Integer:
+        return obj
+    case *object.Float:
+        // -2^63 and 2^63 are exact floats, and NaN fails both comparisons
+        if !(obj.Value >= math.MinInt64 && obj.Value < -math.MinInt64) {
+            return newError("cannot convert %s to INTEGER", obj.Inspect())
+        }
+        return &object.Integer{Value: int64(obj.Value)}
+    case *object.String:
+        value, err := strconv.ParseInt(strings.TrimSpace(obj.Value), base, 64)
+        if err != nil {
+            return newError("invalid literal for int() with base %d: %q", base, obj.Value)
+        }
+        return &object.Integer{Value: value}
+    case *object.Boolean:
+        if obj.Value {
+            return &object.Integer{Value: 1}
+        }
+        return &object.Integer{Value: 0}
+    default:
+        return newError("cannot convert %s to INTEGER", obj.Type())
+    }
+}
+
+// toFloat converts obj to a Float
+func toFloat(obj object.Object) object.Object {
+    switch obj := obj.(type) {
+    case *object.Integer:
+        return &object.Float{Value: float64(obj.Value)}
+    case *object.Float:
+        return obj
+    case *object.String:
+        value, err := strconv.ParseFloat(strings.TrimSpace(obj.Value), 64)
+        if err != nil {
+            return newError("invalid literal for float(): %q", obj.Value)
+        }
+        return &object.Float{Value: value}
+    case *object.Boolean:
+        if obj.Value {
+            return &object.Float{Value: 1}
+        }
+        return &object.Float{Value: 0}
+    default:
+        return newError("cannot convert %s to FLOAT", obj.Type())
+    }
+}
+
+// toString is the text of obj as printed, which is also how template strings
+// embed values
+func toString(obj object.Object) string {
+    return obj.Inspect()
+}
+
+// isCallable reports whether obj can be called, including instances whose
+// struct defines __call__
+func isCallable(obj object.Object) bool {
+    switch obj := obj.(type) {
+    case *object.Function, *object.Builtin, *object.BoundMethod, *object.StructType, *object.EnumVariant:
+        return true
+    case *object.Instance:
+        _, ok := obj.Struct.Methods["__call__"]
+        return ok
+    default:
+        return false
+    }
+}
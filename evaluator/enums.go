@@ -0,0 +1,74 @@
+package evaluator
+
+import (
+	"charm/ast"
+	"charm/object"
+)
+
+func evalEnumStatement(stmt *ast.EnumStatement, env *object.Environment) object.Object {
+    enumType := &object.EnumType{Name: stmt.Name.Value}
+
+    for _, variantNode := range stmt.Variants {
+        variant := &object.EnumVariant{Enum: enumType, Name: variantNode.Name.Value}
+
+        if variantNode.Fields != nil {
+            variant.Fields = []string{}
+            for _, field := range variantNode.Fields {
+                variant.Fields = append(variant.Fields, field.Value)
+            }
+        } else {
+            variant.Value = &object.EnumValue{Variant: variant}
+        }
+
+        enumType.Variants = append(enumType.Variants, variant)
+    }
+
+    if err := env.Declare(stmt.Name.Value, enumType, false); err != nil {
+        return newError("%s: %s", err, stmt.Name.Value)
+    }
+
+    return enumType
+}
+
+// constructEnumValue builds a value of a payload variant, e.g. "Result.Ok(1)"
+func constructEnumValue(variant *object.EnumVariant, arguments []object.Object, keywords []keywordArgument) object.Object {
+    if len(keywords) > 0 {
+        return newError("keyword arguments not supported by enum variants")
+    }
+
+    if len(arguments) != len(variant.Fields) {
+        return newError("wrong number of arguments to %s: expected %d, got %d",
+            variant.Inspect(), len(variant.Fields), len(arguments))
+    }
+
+    return &object.EnumValue{Variant: variant, Payload: arguments}
+}
+
+// variants without a payload are values, the others are constructors
+func getEnumVariant(enumType *object.EnumType, name string) object.Object {
+    variant, ok := enumType.Variant(name)
+    if !ok {
+        return newError("%s has no variant %s", enumType.Name, name)
+    }
+
+    if variant.Value != nil {
+        return variant.Value
+    }
+    return variant
+}
+
+func getEnumField(enumValue *object.EnumValue, name string) object.Object {
+    value, ok := enumValue.Field(name)
+    if !ok {
+        return newError("%s.%s has no field %s", enumValue.Variant.Enum.Name, enumValue.Variant.Name, name)
+    }
+    return value
+}
+
+func enumVariantNames(enumType *object.EnumType) []string {
+    names := []string{}
+    for _, variant := range enumType.Variants {
+        names = append(names, variant.Name)
+    }
+    return names
+}
@@ -4,14 +4,23 @@ import (
 	"charm/ast"
 	"charm/object"
 	"fmt"
+	"strings"
 )
 
 var (
-    TRUE = &object.Boolean{Value: true}
-    FALSE = &object.Boolean{Value: false}
-    NULL = &object.Null{}
+    TRUE = object.TRUE
+    FALSE = object.FALSE
+    NULL = object.NULL
 )
 
+// skipped stands in for null once a ?. or ?[ has met null, so the rest of
+// the member/index/call chain is skipped. Eval turns it back into NULL.
+var skipped = &skippedChain{NULL}
+
+type skippedChain struct {
+    *object.Null
+}
+
 func Eval(node ast.Node, env *object.Environment) object.Object {
     switch node := node.(type) {
     case *ast.Program:
@@ -24,8 +33,12 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
         return &object.Float{Value: node.Value}
     case *ast.StringLiteral:
         return &object.String{Value: node.Value}
+    case *ast.TemplateLiteral:
+        return evalTemplateLiteral(node, env)
     case *ast.BooleanLiteral:
         return nativeBooltoBoolObject(node.Value)
+    case *ast.NullLiteral:
+        return NULL
     case *ast.PrefixExpression:
         return evalPrefixExpression(node, env)
     case *ast.InfixExpression:
@@ -42,25 +55,48 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
             return returnValue
         }
         return &object.ReturnValue{Value: returnValue}
+    case *ast.LetStatement:
+        return evalLetStatement(node, env)
     case *ast.AssignmentStatement:
-        value := Eval(node.Value, env)
-        if isError(value) {
-            return value
-        }
-        env.Set(node.Identifier.Value, value)
-        return value
+        return evalAssignmentStatement(node, env)
     case *ast.FunctionStatement:
         return evalFunctionStatement(node, env)
+    case *ast.StructStatement:
+        return evalStructStatement(node, env)
+    case *ast.EnumStatement:
+        return evalEnumStatement(node, env)
+    case *ast.ImportStatement:
+        return evalImportStatement(node, env)
+    case *ast.ExportStatement:
+        return evalExportStatement(node, env)
     case *ast.Identifier:
         return evalIdentifier(node, env)
     case *ast.FunctionLiteral:
         return &object.Function{Parameters: node.Parameters, Body: node.Body, Env: env}
+    case *ast.LambdaLiteral:
+        return &object.Function{Parameters: node.Parameters, Body: node.Body, Env: env}
     case *ast.CallExpression:
-        return evalCallExpression(node, env)
+        return endChain(evalCallExpression(node, env))
+    case *ast.PipeExpression:
+        return evalPipeExpression(node, env)
+    case *ast.MatchExpression:
+        return evalMatchExpression(node, env)
     case *ast.ArrayLiteral:
         return evalArrayLiteral(node, env)
     case *ast.IndexExpression:
-        return evalIndexExpression(node, env)
+        return endChain(evalIndexExpression(node, env))
+    case *ast.SliceExpression:
+        return endChain(evalSliceExpression(node, env))
+    case *ast.MemberExpression:
+        return endChain(evalMemberExpression(node, env))
+    case *ast.TupleLiteral:
+        elements := evalExpressions(node.Elements, env)
+        if len(elements) == 1 && isError(elements[0]) {
+            return elements[0]
+        }
+        return &object.Tuple{Elements: elements}
+    case *ast.SetLiteral:
+        return evalSetLiteral(node, env)
     case *ast.HashMapLiteral:
         return evalHashMapLiteral(node, env)
     }
@@ -76,7 +112,9 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
         case *object.ReturnValue:
             return result.Value
         case *object.Error:
-            fmt.Println(result.Message)
+            if !result.Exit {
+                fmt.Fprintln(env.Runtime().Err, result.Message)
+            }
             return result
         }
     }
@@ -108,6 +146,9 @@ func evalPrefixExpression(exp *ast.PrefixExpression, env *object.Environment) ob
     case "!":
         return evalBangPrefixExpression(right)
     case "-":
+        if result, ok := callSpecialMethod(right, "__neg__"); ok {
+            return result
+        }
         return evalMinusPrefixExpression(right)
     default:
         return newError("unknown operator: %s%s",exp.Operator, right.Type())
@@ -135,12 +176,18 @@ func evalMinusPrefixExpression(right object.Object) object.Object {
         return &object.Integer{Value: -obj.Value}
     case *object.Float:
         return &object.Float{Value: -obj.Value}
+    case *object.Duration:
+        return &object.Duration{Value: -obj.Value}
     default:
         return newError("unknown operator: -%s", right.Type())
     }
 }
 
 func evalInfixExpression(exp *ast.InfixExpression, env *object.Environment) object.Object {
+    if exp.Operator == "??" {
+        return evalCoalesceExpression(exp, env)
+    }
+
     right := Eval(exp.Right, env)
     if isError(right) {
         return right
@@ -150,35 +197,40 @@ func evalInfixExpression(exp *ast.InfixExpression, env *object.Environment) obje
         return left
     }
 
+    switch exp.Operator {
+    case "in":
+        return evalMembershipExpression(left, right)
+    case "not in":
+        contains := evalMembershipExpression(left, right)
+        if isError(contains) {
+            return contains
+        }
+        return nativeBooltoBoolObject(!isTruthy(contains))
+    }
+
+    if result, ok := evalOverloadedInfixExpression(left, exp.Operator, right); ok {
+        return result
+    }
+
     switch {
     case right.Type() == object.INTEGER_OBJ && left.Type() == object.INTEGER_OBJ:
         rightValue := right.(*object.Integer).Value
         leftValue := left.(*object.Integer).Value
         return evalIntegerInfixExpression(leftValue, exp.Operator, rightValue)
 
-    case right.Type() == object.INTEGER_OBJ && left.Type() == object.FLOAT_OBJ:
-        rightValue := float64(right.(*object.Integer).Value)
-        leftValue := float64(left.(*object.Float).Value)
-        return evalFloatInfixExpression(leftValue, exp.Operator, rightValue)
-
-    case right.Type() == object.FLOAT_OBJ && left.Type() == object.INTEGER_OBJ:
-        rightValue := float64(right.(*object.Float).Value)
-        leftValue := float64(left.(*object.Integer).Value)
-        return evalFloatInfixExpression(leftValue, exp.Operator, rightValue)
-
-    case right.Type() == object.FLOAT_OBJ && left.Type() == object.FLOAT_OBJ:
-        rightValue := right.(*object.Float).Value
-        leftValue := left.(*object.Float).Value
+    case isNumber(right) && isNumber(left):
+        rightValue := toFloat(right).(*object.Float).Value
+        leftValue := toFloat(left).(*object.Float).Value
         return evalFloatInfixExpression(leftValue, exp.Operator, rightValue)
 
-    case right.Type() == object.STRING_OBJ && left.Type() == object.STRING_OBJ && exp.Operator == "+":
-        rightValue := right.(*object.String).Value
-        leftValue := left.(*object.String).Value
-        return &object.String{Value: leftValue + rightValue}
+    case (right.Type() == object.STRING_OBJ || left.Type() == object.STRING_OBJ) && exp.Operator == "+":
+        return &object.String{Value: toString(left) + toString(right)}
     case exp.Operator == "==":
-        return nativeBooltoBoolObject(left == right)
+        return nativeBooltoBoolObject(object.Equal(left, right))
     case exp.Operator == "!=":
-        return nativeBooltoBoolObject(left != right)
+        return nativeBooltoBoolObject(!object.Equal(left, right))
+    case isTimeValue(left) || isTimeValue(right):
+        return evalTimeInfixExpression(left, exp.Operator, right)
     case left.Type() != right.Type():
         return newError("type mismatch: %s %s %s", left.Type(), exp.Operator, right.Type())
     default:
@@ -247,9 +299,9 @@ func evalIfStatement(node *ast.IfStatement, env *object.Environment) object.Obje
     }
 
     if isTruthy(condition) {
-        return Eval(node.Consequence, env)
+        return Eval(node.Consequence, object.NewEnclosedEnvironment(env))
     } else if node.Alternative != nil {
-        return Eval(node.Alternative, env)
+        return Eval(node.Alternative, object.NewEnclosedEnvironment(env))
     } else {
         return NULL
     }
@@ -264,62 +316,511 @@ func evalWhileStatemnt(node *ast.WhileStatement, env *object.Environment) object
 
     var evaluated object.Object = NULL
     for isTruthy(condition) {
-        evaluated = Eval(node.Body, env)
+        evaluated = Eval(node.Body, object.NewEnclosedEnvironment(env))
+        if evaluated == nil {
+            evaluated = NULL
+        }
 
-        if evaluated.Type() == object.RETURN_VALUE_OBJ {
+        if evaluated.Type() == object.RETURN_VALUE_OBJ || isError(evaluated) {
             return evaluated
         }
 
         condition = Eval(node.Condition, env)
+        if isError(condition) {
+            return condition
+        }
     }
 
     return evaluated
 }
 
+func evalLetStatement(stmt *ast.LetStatement, env *object.Environment) object.Object {
+    var value object.Object = NULL
+    if stmt.Value != nil {
+        value = Eval(stmt.Value, env)
+        if isError(value) {
+            return value
+        }
+    }
+
+    declare := func(name string, value object.Object) *object.Error {
+        if err := env.Declare(name, value, stmt.IsConstant()); err != nil {
+            return newError("%s: %s", err, name)
+        }
+        return nil
+    }
+
+    if stmt.Pattern != nil {
+        if err := bindPattern(stmt.Pattern, value, env, declare); err != nil {
+            return err
+        }
+        return value
+    }
+
+    // "let add = func(a, b) {...};" names the function after its binding
+    if function, ok := value.(*object.Function); ok && function.Name == "" {
+        switch stmt.Value.(type) {
+        case *ast.FunctionLiteral, *ast.LambdaLiteral:
+            function.Name = stmt.Identifier.Value
+        }
+    }
+
+    if err := declare(stmt.Identifier.Value, value); err != nil {
+        return err
+    }
+
+    return value
+}
+
+func evalAssignmentStatement(stmt *ast.AssignmentStatement, env *object.Environment) object.Object {
+    value := Eval(stmt.Value, env)
+    if isError(value) {
+        return value
+    }
+
+    assign := func(name string, value object.Object) *object.Error {
+        switch env.Assign(name, value) {
+        case object.ErrUndeclared:
+            return newError("assignment to undeclared identifier: %s", name)
+        case object.ErrConstant:
+            return newError("assignment to constant: %s", name)
+        }
+        return nil
+    }
+
+    if stmt.Pattern != nil {
+        if err := bindPattern(stmt.Pattern, value, env, assign); err != nil {
+            return err
+        }
+        return value
+    }
+
+    if stmt.Target != nil {
+        if err := assignTarget(stmt.Target, value, env); err != nil {
+            return err
+        }
+        return value
+    }
+
+    if err := assign(stmt.Identifier.Value, value); err != nil {
+        return err
+    }
+
+    return value
+}
+
+// assigns to a member or index of a value, e.g. "p.x = 1" or "arr[0] = 1"
+func assignTarget(target ast.Expression, value object.Object, env *object.Environment) *object.Error {
+    switch target := target.(type) {
+    case *ast.MemberExpression:
+        obj := Eval(target.Object, env)
+        if isError(obj) {
+            return obj.(*object.Error)
+        }
+
+        switch obj := obj.(type) {
+        case *object.Instance:
+            return setInstanceField(obj, target.Property.Value, value)
+        case *object.HashMap:
+            obj.Put(&object.String{Value: target.Property.Value}, value)
+            return nil
+        default:
+            return newError("cannot assign to member of %s", obj.Type())
+        }
+    case *ast.IndexExpression:
+        obj := Eval(target.Left, env)
+        if isError(obj) {
+            return obj.(*object.Error)
+        }
+        indexObj := Eval(target.Index, env)
+        if isError(indexObj) {
+            return indexObj.(*object.Error)
+        }
+
+        switch obj := obj.(type) {
+        case *object.Array:
+            index, ok := indexObj.(*object.Integer)
+            if !ok {
+                return newError("not an integer: %s", indexObj.Type())
+            }
+            i, ok := resolveIndex(index.Value, len(obj.Elements))
+            if !ok {
+                return newError("index out of range: %d with length %d", index.Value, len(obj.Elements))
+            }
+            obj.Elements[i] = value
+            return nil
+        case *object.HashMap:
+            key, ok := object.AsHashable(indexObj)
+            if !ok {
+                return newError("unusable as haskey: %s", indexObj.Type())
+            }
+            obj.Put(key, value)
+            return nil
+        default:
+            if result, ok := callSpecialMethod(obj, "__setindex__", indexObj, value); ok {
+                if isError(result) {
+                    return result.(*object.Error)
+                }
+                return nil
+            }
+            return newError("index assignment not supported: %s", obj.Type())
+        }
+    default:
+        return newError("cannot assign to %s", target.String())
+    }
+}
+
 func evalFunctionStatement(stmt *ast.FunctionStatement, env *object.Environment) object.Object {
     function := &object.Function{
+        Name: stmt.Identifier.Value,
         Parameters: stmt.FunctionLiteral.Parameters,
         Body: stmt.FunctionLiteral.Body,
         Env: env,
     }
 
-    env.Set(stmt.Identifier.Value, function)
+    if err := env.Declare(stmt.Identifier.Value, function, false); err != nil {
+        return newError("%s: %s", err, stmt.Identifier.Value)
+    }
 
     return function
 }
 
 func evalCallExpression(node *ast.CallExpression, env *object.Environment) object.Object {
-    arguments := evalExpressions(node.Arguments, env)
-    if len(arguments) == 1 && arguments[0].Type() == object.ERROR_OBJ {
-        return arguments[0]
+    // a callee that may be skipped is evaluated first, so that its arguments
+    // are skipped with it
+    if isOptionalChain(node.FunctionLiteral) {
+        obj := evalChainLink(node.FunctionLiteral, env)
+        if isError(obj) || obj == skipped {
+            return obj
+        }
+        arguments, keywords, err := evalArguments(node.Arguments, env)
+        if err != nil {
+            return err
+        }
+        return applyFunction(obj, arguments, keywords, env.Runtime())
+    }
+
+    arguments, keywords, err := evalArguments(node.Arguments, env)
+    if err != nil {
+        return err
     }
 
     obj := Eval(node.FunctionLiteral, env)
+    if isError(obj) {
+        return obj
+    }
 
-    switch functionObj := obj.(type) {
-    case *object.Function:
-        if len(arguments) > len(functionObj.Parameters) {
-            return newError("too many arguments")
-        }
-        if len(arguments) < len(functionObj.Parameters) {
-            return newError("not enough arguments")
+    return applyFunction(obj, arguments, keywords, env.Runtime())
+}
+
+// evalChainLink evaluates the object a member, index, slice or call applies
+// to. Unlike Eval it passes skipped through, so "a?.b.c" is null when a is.
+func evalChainLink(node ast.Expression, env *object.Environment) object.Object {
+    switch node := node.(type) {
+    case *ast.CallExpression:
+        return evalCallExpression(node, env)
+    case *ast.IndexExpression:
+        return evalIndexExpression(node, env)
+    case *ast.SliceExpression:
+        return evalSliceExpression(node, env)
+    case *ast.MemberExpression:
+        return evalMemberExpression(node, env)
+    }
+    return Eval(node, env)
+}
+
+// isOptionalChain reports whether node is a chain containing a ?. or ?[.
+func isOptionalChain(node ast.Expression) bool {
+    switch node := node.(type) {
+    case *ast.CallExpression:
+        return isOptionalChain(node.FunctionLiteral)
+    case *ast.IndexExpression:
+        return node.Optional || isOptionalChain(node.Left)
+    case *ast.SliceExpression:
+        return node.Optional || isOptionalChain(node.Left)
+    case *ast.MemberExpression:
+        return node.Optional || isOptionalChain(node.Object)
+    }
+    return false
+}
+
+// skipChain reports whether the rest of a chain is skipped after obj.
+func skipChain(obj object.Object, optional bool) bool {
+    return obj == skipped || optional && obj == NULL
+}
+
+func endChain(obj object.Object) object.Object {
+    if obj == skipped {
+        return NULL
+    }
+    return obj
+}
+
+// "x |> f(y)" evaluates as "f(x, y)" and "x |> f" as "f(x)"
+func evalPipeExpression(node *ast.PipeExpression, env *object.Environment) object.Object {
+    left := Eval(node.Left, env)
+    if isError(left) {
+        return left
+    }
+
+    call, ok := node.Right.(*ast.CallExpression)
+    if !ok {
+        function := Eval(node.Right, env)
+        if isError(function) {
+            return function
         }
+        return applyFunction(function, []object.Object{left}, nil, env.Runtime())
+    }
 
+    arguments, keywords, err := evalArguments(call.Arguments, env)
+    if err != nil {
+        return err
+    }
+
+    function := Eval(call.FunctionLiteral, env)
+    if isError(function) {
+        return function
+    }
+
+    arguments = append([]object.Object{left}, arguments...)
+    return applyFunction(function, arguments, keywords, env.Runtime())
+}
+
+type keywordArgument struct {
+    name string
+    value object.Object
+}
+
+func newCallContext(keywords []keywordArgument, runtime *object.Runtime) *object.CallContext {
+    ctx := &object.CallContext{Runtime: runtime, Keywords: map[string]object.Object{}}
+    for _, keyword := range keywords {
+        ctx.Keywords[keyword.name] = keyword.value
+    }
+    ctx.Call = func(fn object.Object, args ...object.Object) object.Object {
+        return applyFunction(fn, args, nil, runtime)
+    }
+    return ctx
+}
+
+// applyFunction calls obj. runtime is the runtime of the caller, which is
+// handed to builtins that take a call context.
+func applyFunction(obj object.Object, arguments []object.Object, keywords []keywordArgument, runtime *object.Runtime) object.Object {
+    switch functionObj := obj.(type) {
+    case *object.Function:
         enclosedEnv := object.NewEnclosedEnvironment(functionObj.Env)
 
-        for i, param := range functionObj.Parameters {
-            enclosedEnv.Set(param.Value, arguments[i])
+        if err := bindArguments(functionObj, arguments, keywords, enclosedEnv); err != nil {
+            return err
         }
 
         evaluated := Eval(functionObj.Body, enclosedEnv)
         return unwrapReturnValue(evaluated)
     case *object.Builtin:
+        if functionObj.ContextFn != nil {
+            return functionObj.ContextFn(newCallContext(keywords, runtime), arguments...)
+        }
+        if len(keywords) > 0 {
+            return newError("keyword arguments not supported by builtin functions")
+        }
         return functionObj.Fn(arguments...)
+    case *object.BoundMethod:
+        if functionObj.ContextFn != nil {
+            return functionObj.ContextFn(newCallContext(keywords, runtime), functionObj.Receiver, arguments...)
+        }
+        if len(keywords) > 0 {
+            return newError("keyword arguments not supported by builtin methods")
+        }
+        return functionObj.Call(arguments...)
+    case *object.StructType:
+        return constructInstance(functionObj, arguments, keywords)
+    case *object.EnumVariant:
+        return constructEnumValue(functionObj, arguments, keywords)
+    case *object.Instance:
+        call, ok := functionObj.Struct.Methods["__call__"]
+        if !ok {
+            return newError("not a function: %s", obj.Type())
+        }
+        return applyFunction(bindMethod(functionObj, call), arguments, keywords, runtime)
     default:
         return newError("not a function: %s", obj.Type())
     }
 }
 
+// evaluates call arguments left to right, expanding spread arguments and
+// collecting keyword arguments separately
+func evalArguments(args []ast.Expression, env *object.Environment) ([]object.Object, []keywordArgument, *object.Error) {
+    positional := []object.Object{}
+    keywords := []keywordArgument{}
+
+    for _, arg := range args {
+        switch arg := arg.(type) {
+        case *ast.KeywordArgument:
+            for _, keyword := range keywords {
+                if keyword.name == arg.Name.Value {
+                    return nil, nil, newError("duplicate keyword argument: %s", arg.Name.Value)
+                }
+            }
+
+            value := Eval(arg.Value, env)
+            if isError(value) {
+                return nil, nil, value.(*object.Error)
+            }
+            keywords = append(keywords, keywordArgument{name: arg.Name.Value, value: value})
+        case *ast.SpreadExpression:
+            elements, err := evalSpreadExpression(arg, env)
+            if err != nil {
+                return nil, nil, err
+            }
+            positional = append(positional, elements...)
+        default:
+            value := Eval(arg, env)
+            if isError(value) {
+                return nil, nil, value.(*object.Error)
+            }
+            positional = append(positional, value)
+        }
+    }
+
+    return positional, keywords, nil
+}
+
+func evalSpreadExpression(spread *ast.SpreadExpression, env *object.Environment) ([]object.Object, *object.Error) {
+    value := Eval(spread.Value, env)
+    if isError(value) {
+        return nil, value.(*object.Error)
+    }
+
+    switch value := value.(type) {
+    case *object.Array:
+        return value.Elements, nil
+    default:
+        return nil, newError("cannot spread %s", value.Type())
+    }
+}
+
+// binds positional arguments in order, the rest parameter collects leftover
+// positional arguments, keyword arguments are matched by name and missing
+// parameters fall back to their default value
+func bindArguments(fn *object.Function, arguments []object.Object, keywords []keywordArgument, env *object.Environment) *object.Error {
+    bound := make([]bool, len(fn.Parameters))
+    next := 0
+
+    for i, param := range fn.Parameters {
+        if param.Rest {
+            rest := []object.Object{}
+            if next < len(arguments) {
+                rest = append(rest, arguments[next:]...)
+            }
+            env.Set(param.Name.Value, &object.Array{Elements: rest})
+            bound[i] = true
+            next = len(arguments)
+            break
+        }
+
+        if next < len(arguments) {
+            if err := bindParameter(param, arguments[next], env); err != nil {
+                return err
+            }
+            bound[i] = true
+            next++
+        }
+    }
+
+    if next < len(arguments) {
+        return arityError(fn, len(arguments) + len(keywords))
+    }
+
+    for _, keyword := range keywords {
+        i := findParameter(fn, keyword.name)
+        if i < 0 || fn.Parameters[i].Rest {
+            return newError("unexpected keyword argument %s in call to %s", keyword.name, fn.Signature())
+        }
+        if bound[i] {
+            return newError("multiple values for argument %s in call to %s", keyword.name, fn.Signature())
+        }
+
+        env.Set(keyword.name, keyword.value)
+        bound[i] = true
+    }
+
+    for i, param := range fn.Parameters {
+        if bound[i] {
+            continue
+        }
+
+        if param.Rest {
+            env.Set(param.Name.Value, &object.Array{Elements: []object.Object{}})
+            continue
+        }
+
+        if param.Default == nil {
+            return arityError(fn, len(arguments) + len(keywords))
+        }
+
+        // defaults are evaluated in the function's scope so they can refer to
+        // earlier parameters
+        value := Eval(param.Default, env)
+        if isError(value) {
+            return value.(*object.Error)
+        }
+        if err := bindParameter(param, value, env); err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
+
+func bindParameter(param *ast.Parameter, value object.Object, env *object.Environment) *object.Error {
+    if param.Pattern == nil {
+        env.Set(param.Name.Value, value)
+        return nil
+    }
+
+    return bindPattern(param.Pattern, value, env, func(name string, value object.Object) *object.Error {
+        env.Set(name, value)
+        return nil
+    })
+}
+
+// returns the index of the named parameter or -1. Destructuring parameters
+// have no name and can only be passed positionally.
+func findParameter(fn *object.Function, name string) int {
+    for i, param := range fn.Parameters {
+        if param.Name != nil && param.Name.Value == name {
+            return i
+        }
+    }
+    return -1
+}
+
+func arityError(fn *object.Function, got int) *object.Error {
+    required, optional, rest := 0, 0, false
+
+    for _, param := range fn.Parameters {
+        switch {
+        case param.Rest:
+            rest = true
+        case param.Default != nil:
+            optional++
+        default:
+            required++
+        }
+    }
+
+    var expected string
+    switch {
+    case rest:
+        expected = fmt.Sprintf("at least %d", required)
+    case optional > 0:
+        expected = fmt.Sprintf("%d to %d", required, required + optional)
+    default:
+        expected = fmt.Sprintf("%d", required)
+    }
+
+    return newError("wrong number of arguments to %s: expected %s, got %d", fn.Signature(), expected, got)
+}
+
 func evalIdentifier(Identifier *ast.Identifier, env *object.Environment) object.Object {
     if val, ok := env.Get(Identifier.Value); ok {
         return val
@@ -329,43 +830,99 @@ func evalIdentifier(Identifier *ast.Identifier, env *object.Environment) object.
         return builtin
     }
 
+    if Identifier.Value == "args" {
+        return scriptArguments(env.Runtime())
+    }
+
     return newError("identifier not found: %s", Identifier.Value)
 }
 
 func evalIndexExpression(indexExpr *ast.IndexExpression, env *object.Environment) object.Object {
-    obj := Eval(indexExpr.Left, env)
-    // what happens if this is NULL?
+    obj := evalChainLink(indexExpr.Left, env)
+    if isError(obj) {
+        return obj
+    }
+    if skipChain(obj, indexExpr.Optional) {
+        return skipped
+    }
     indexObj := Eval(indexExpr.Index, env)
-
-    if isError(indexObj) || indexObj == NULL {
+    if isError(indexObj) {
+        return indexObj
+    }
+    if indexObj == NULL {
         return newError("error evaluating index: %s", indexObj.Inspect())
     }
 
     switch obj := obj.(type) {
     case *object.Array:
-        return evalArrayIndexExpression(obj, indexObj, env)
+        return evalSequenceIndexExpression(obj.Elements, indexObj, env)
+    case *object.Tuple:
+        return evalSequenceIndexExpression(obj.Elements, indexObj, env)
+    case *object.String:
+        return evalStringIndexExpression(obj, indexObj, env)
     case *object.HashMap:
         return evalHashMapIndexExpression(obj, indexObj, env)
     default:
+        if result, ok := callSpecialMethod(obj, "__index__", indexObj); ok {
+            return result
+        }
         return newError("index operator not supported: %s", obj.Type())
     }
 }
 
-func evalArrayIndexExpression(arrayObj *object.Array, indexObj object.Object, env *object.Environment) object.Object {
+// negative indices count from the end. Out of range indices are null, or an
+// error in strict mode.
+func evalSequenceIndexExpression(elements []object.Object, indexObj object.Object, env *object.Environment) object.Object {
     index, ok := indexObj.(*object.Integer)
     if !ok {
-        return newError("not an integer: %T", index)
+        return newError("not an integer: %s", indexObj.Type())
     }
 
-    if int(index.Value) >= len(arrayObj.Elements) || index.Value < 0 {
-        return NULL
+    i, ok := resolveIndex(index.Value, len(elements))
+    if !ok {
+        return indexOutOfRange(index.Value, len(elements), env)
     }
 
-    return arrayObj.Elements[index.Value]
+    return elements[i]
+}
+
+// strings are indexed by rune
+func evalStringIndexExpression(str *object.String, indexObj object.Object, env *object.Environment) object.Object {
+    index, ok := indexObj.(*object.Integer)
+    if !ok {
+        return newError("not an integer: %s", indexObj.Type())
+    }
+
+    runes := []rune(str.Value)
+    i, ok := resolveIndex(index.Value, len(runes))
+    if !ok {
+        return indexOutOfRange(index.Value, len(runes), env)
+    }
+
+    return &object.String{Value: string(runes[i])}
+}
+
+// resolveIndex turns a possibly negative index into a position in a sequence
+// of the given length
+func resolveIndex(index int64, length int) (int, bool) {
+    if index < 0 {
+        index += int64(length)
+    }
+    if index < 0 || index >= int64(length) {
+        return 0, false
+    }
+    return int(index), true
+}
+
+func indexOutOfRange(index int64, length int, env *object.Environment) object.Object {
+    if env.Runtime().Strict {
+        return newError("index out of range: %d with length %d", index, length)
+    }
+    return NULL
 }
 
 func evalHashMapIndexExpression(hashMapObj *object.HashMap, indexObj object.Object, env *object.Environment) object.Object {
-    HashObj, ok := indexObj.(object.Hashable)
+    HashObj, ok := object.AsHashable(indexObj)
     if !ok {
         return newError("unusable as haskey: %s", indexObj.Type())
     }
@@ -379,18 +936,60 @@ func evalHashMapIndexExpression(hashMapObj *object.HashMap, indexObj object.Obje
     return pair.Value
 }
 
-func evalHashMapLiteral(hashMap *ast.HashMapLiteral, env *object.Environment) object.Object {
-    hashMapObj := &object.HashMap{
-        Map : make(map[uint64]object.Pair),
+func evalMemberExpression(member *ast.MemberExpression, env *object.Environment) object.Object {
+    obj := evalChainLink(member.Object, env)
+    if isError(obj) {
+        return obj
+    }
+    if skipChain(obj, member.Optional) {
+        return skipped
+    }
+
+    return getMember(obj, member.Property.Value)
+}
+
+// getMember looks up a field or method of obj. On a hashmap, "m.name" is
+// sugar for m["name"] unless the key is missing and name is a method.
+func getMember(obj object.Object, name string) object.Object {
+    switch obj := obj.(type) {
+    case *object.Instance:
+        return getInstanceMember(obj, name)
+    case *object.EnumType:
+        return getEnumVariant(obj, name)
+    case *object.EnumValue:
+        return getEnumField(obj, name)
+    case *object.Module:
+        return getModuleExport(obj, name)
     }
 
-    for key, val := range hashMap.Map {
+    hashMap, isHashMap := obj.(*object.HashMap)
+    if isHashMap {
+        if pair, ok := hashMap.Map[(&object.String{Value: name}).HashCode()]; ok {
+            return pair.Value
+        }
+    }
+
+    if method, ok := object.LookupMethod(obj, name); ok {
+        return method
+    }
+
+    if isHashMap {
+        return NULL
+    }
+    return newError("%s has no member %s", obj.Type(), name)
+}
+
+func evalHashMapLiteral(hashMap *ast.HashMapLiteral, env *object.Environment) object.Object {
+    hashMapObj := object.NewHashMap()
+
+    for _, key := range hashMap.Keys {
+        val := hashMap.Map[key]
         keyObj := Eval(key, env)
         if isError(keyObj) {
             return keyObj
         }
         
-        hashableKey, ok := keyObj.(object.Hashable)
+        hashableKey, ok := object.AsHashable(keyObj)
         if !ok {
             return newError("Object not hashable: %s", keyObj.Type())
         }
@@ -400,7 +999,7 @@ func evalHashMapLiteral(hashMap *ast.HashMapLiteral, env *object.Environment) ob
             return valObj
         }
 
-        hashMapObj.Map[hashableKey.HashCode()] = object.Pair{Key: keyObj, Value: valObj}
+        hashMapObj.Put(hashableKey, valObj)
     }
 
     return hashMapObj
@@ -424,6 +1023,10 @@ func isTruthy(obj object.Object) bool {
     }
 }
 
+func isNumber(obj object.Object) bool {
+    return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
 func newError(format string, arguments ...any) *object.Error {
     return &object.Error{Message: fmt.Sprintf(format, arguments...)}
 }
@@ -447,11 +1050,32 @@ func evalExpressions(args []ast.Expression, env *object.Environment) []object.Ob
     return evaledArgs
 }
 
+func evalTemplateLiteral(node *ast.TemplateLiteral, env *object.Environment) object.Object {
+    var out strings.Builder
+    for _, part := range node.Parts {
+        evaluated := Eval(part, env)
+        if isError(evaluated) {
+            return evaluated
+        }
+        out.WriteString(toString(evaluated))
+    }
+    return &object.String{Value: out.String()}
+}
+
 func evalArrayLiteral(node *ast.ArrayLiteral, env *object.Environment) object.Object {
     elements := []object.Object{}
     var evaluated object.Object
 
     for _, exp := range node.Elements {
+        if spread, ok := exp.(*ast.SpreadExpression); ok {
+            spreadElements, err := evalSpreadExpression(spread, env)
+            if err != nil {
+                return err
+            }
+            elements = append(elements, spreadElements...)
+            continue
+        }
+
         evaluated = Eval(exp, env)
         if evaluated != nil && evaluated.Type() == object.ERROR_OBJ {
             return evaluated
@@ -463,6 +1087,25 @@ func evalArrayLiteral(node *ast.ArrayLiteral, env *object.Environment) object.Ob
     return &object.Array{Elements: elements}
 }
 
+func evalSetLiteral(node *ast.SetLiteral, env *object.Environment) object.Object {
+    set := object.NewSet()
+
+    for _, exp := range node.Elements {
+        evaluated := Eval(exp, env)
+        if isError(evaluated) {
+            return evaluated
+        }
+
+        element, ok := object.AsHashable(evaluated)
+        if !ok {
+            return newError("unusable as set element: %s", evaluated.Type())
+        }
+        set.Add(element)
+    }
+
+    return set
+}
+
 func unwrapReturnValue(obj object.Object) object.Object {
     if returnValue, ok := obj.(*object.ReturnValue); ok {
         return returnValue.Value
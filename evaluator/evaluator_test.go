@@ -1,10 +1,16 @@
 package evaluator
 
 import (
+	"bytes"
 	"charm/lexer"
 	"charm/object"
 	"charm/parser"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
     "math"
 )
 
@@ -108,6 +114,12 @@ func TestStringLiteral(t *testing.T) {
     } {
         {`"Hello World!";`, "Hello World!"},
         {`"Hello" + " Concat";`, "Hello Concat"},
+        {`let name = "Bob"; let age = 30; "Hello ${name}, you are ${age + 1}";`, "Hello Bob, you are 31"},
+        {`"${[1, true, null]} ${ {"a": 1}["a"] }";`, "[1, true, null] 1"},
+        {`let x = "in"; "out ${"${x}ner"}";`, "out inner"},
+        {`func f() { let local = 2; return "local=${local}"; } f();`, "local=2"},
+        {`"${1}${2}";`, "12"},
+        {`"cost: $5 {x}";`, "cost: $5 {x}"},
     }
 
     for _, test := range tests {
@@ -161,6 +173,10 @@ func TestErrorHandling(t *testing.T) {
                 return 1;
             }`, "unknown operator: BOOLEAN + BOOLEAN",
         },
+        {
+            `"value: ${missing}";`,
+            "identifier not found: missing",
+        },
     }
 
     for _, test := range tests {
@@ -179,258 +195,1776 @@ func TestErrorHandling(t *testing.T) {
     }
 }
 
-// TODO: refactor tests to run every sub-test in its own goroutine
-func TestIfElseExpressions(t *testing.T) {
+// TODO: refactor tests to run every sub-test in its own goroutine
+func TestIfElseExpressions(t *testing.T) {
+    tests := []struct {
+        input string
+        expected any
+    } {
+        {"if (true) { 10; }", 10},
+        {"if (false) { 10; }", nil},
+        {"if (1) { 10; }", 10},
+        {"if (1 < 2) { 10; }", 10},
+        {"if (1 > 2) { 10; }", nil},
+        {"if (1 > 2) { 10; } else { 20; }", 20},
+        {"if (1 < 2) { 10; } else { 20; }", 10},
+        {"if (1 < 2) { 10; 20; 30; } else { 20; }", 30},
+    }
+
+    for _, test := range tests {
+        evalulated := evalTest(test.input)
+        integer, ok := test.expected.(int)
+        if ok {
+            testIntegerObject(t, evalulated, int64(integer))
+        } else {
+            testNullObject(t, evalulated)
+        }
+    }
+}
+
+func TestReturnStatements(t *testing.T) {
+    tests := []struct {
+        input string
+        expected int64
+    } {
+        {"return 10;", 10},
+        {"return 10; 11;", 10},
+        {"return 2 * 5; 9;", 10},
+        {"9; return 2 * 5; 9;", 10},
+        {`if (10 > 1) {
+            if (10 > 1) {
+                return 10;
+            }
+            return 1;
+            }`, 10,
+        },
+    }
+
+    for _, test := range tests {
+        evaluated := evalTest(test.input)
+        testIntegerObject(t, evaluated, test.expected)
+    }
+}
+
+func TestWhileStatement(t *testing.T) {
+    input := `
+    let x = 10;
+    while (x > 0) { x = x - 1; }
+    `
+
+    evaluated := evalTest(input)
+
+    int, ok := evaluated.(*object.Integer)
+    if !ok {
+        t.Fatalf("Expected=%s. Got=%s", object.INTEGER_OBJ, evaluated.Type())
+    }
+
+    testIntegerObject(t, int, 0)
+}
+
+func TestReturnInWhileLoop(t *testing.T) {
+    input := `
+    let x = 10;
+    while (x > 0) { 
+        x = x - 1;
+        return x;
+    }
+    `
+    evaluated := evalTest(input)
+
+    int, ok := evaluated.(*object.Integer)
+    if !ok {
+        t.Fatalf("Expected=%s. Got=%s", object.INTEGER_OBJ, evaluated.Type())
+    }
+
+    testIntegerObject(t, int, 9)
+}
+
+func TestWhileErrors(t *testing.T) {
+    tests := []struct {
+        input string
+        expected string
+    } {
+        {"let i = 0; while (i < 3) { j = 1; i = i + 1; }", "assignment to undeclared identifier: j"},
+        {"let i = 0; while (i < 3) { i = i + 1; missing; }", "identifier not found: missing"},
+        {"let i = 0; while (i < 3 + missing) { i = i + 1; }", "identifier not found: missing"},
+        {"let i = 0; while (i < 3) { i = i + 1; if (i == 2) { let x = missing; } }", "identifier not found: missing"},
+    }
+
+    for _, test := range tests {
+        testErrorObject(t, evalTest(test.input), test.expected)
+    }
+}
+
+func TestWhileEmptyBody(t *testing.T) {
+    testNullObject(t, evalTest("let i = 0; while (i < 0) {} while (false) {}"))
+    testNullObject(t, evalTest("let once = true; func next() { let was = once; once = false; return was; } while (next()) {}"))
+}
+
+func TestWhileNoEval(t *testing.T) {
+    input := `
+    let x = 10;
+    while (x > 120) {
+        x = x - 1;
+        return x;
+    }
+    `
+    evaluated := evalTest(input)
+
+    testNullObject(t, evaluated)
+}
+
+func TestAssignmentStatement(t *testing.T) {
+    tests := []struct {
+        input string
+        expected int64
+    } {
+        {"let a = 5; a;", 5},
+        {"let a = 5 * 5; a;", 25},
+        {"let a = 5; let b = a; b;", 5},
+        {"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+    }
+
+    for _, test := range tests {
+        testIntegerObject(t, evalTest(test.input), test.expected)
+    }
+}
+
+func TestScoping(t *testing.T) {
+    tests := []struct {
+        input string
+        expected any
+    } {
+        {"let a; a;", nil},
+        {"let a = 1; a = 2; a;", 2},
+        {"const a = 1; a;", 1},
+        {"let a = 1; if (true) { a = 2; } a;", 2},
+        {"let a = 1; if (true) { let a = 2; } a;", 1},
+        {"let a = 1; if (false) { } else { let a = 2; } a;", 1},
+        {"let i = 0; let sum = 0; while (i < 3) { let step = i; sum = sum + step; i = i + 1; } sum;", 3},
+        {"let a = 1; func f() { a = 2; } f(); a;", 2},
+        {"let a = 1; func f() { let a = 2; } f(); a;", 1},
+        {"func counter() { let count = 0; return func() { count = count + 1; count; }; } let c = counter(); c(); c();", 2},
+        {"b = 1;", "assignment to undeclared identifier: b"},
+        {"const a = 1; a = 2;", "assignment to constant: a"},
+        {"const a = 1; func f() { a = 2; } f();", "assignment to constant: a"},
+        {"let a = 1; let a = 2;", "identifier already declared: a"},
+        {"let a = 1; func a() { 1; }", "identifier already declared: a"},
+        {"if (true) { let a = 1; } a;", "identifier not found: a"},
+        {"while (true) { let a = 1; return a; }", 1},
+    }
+
+    for _, test := range tests {
+        evaluated := evalTest(test.input)
+
+        switch expected := test.expected.(type) {
+        case int:
+            testIntegerObject(t, evaluated, int64(expected))
+        case string:
+            testErrorObject(t, evaluated, expected)
+        default:
+            testNullObject(t, evaluated)
+        }
+    }
+}
+
+func TestDestructuring(t *testing.T) {
+    tests := []struct {
+        input string
+        expected any
+    } {
+        {"let [a, b] = [1, 2]; a * 10 + b;", 12},
+        {"let [a, b, ...rest] = [1, 2, 3, 4]; len(rest) * 10 + rest[1];", 24},
+        {"let [a, ...rest] = [1]; len(rest);", 0},
+        {"let [a, b = 5] = [1]; b;", 5},
+        {"let [a, b = a + 1] = [1]; b;", 2},
+        {"let a = 1; let b = 2; [a, b] = [b, a]; a * 10 + b;", 21},
+        {`let {name, age} = {"name": "x", "age": 3}; age;`, 3},
+        {`let {age: years} = {"age": 3}; years;`, 3},
+        {`let {size = 10} = {}; size;`, 10},
+        {`let {1: one} = {1: 7}; one;`, 7},
+        {`let {pos: [x, y], tags: [first] = [9]} = {"pos": [1, 2]}; x + y + first;`, 12},
+        {`let [{a}, [b, c]] = [{"a": 1}, [2, 3]]; a + b + c;`, 6},
+        {`let a = 0; let b = 0; {a, b} = {"a": 1, "b": 2}; a + b;`, 3},
+        {`func f([a, b], {c} = {"c": 3}) { a + b + c; } f([1, 2]);`, 6},
+        {`let f = ([a, b]) => a * b; f([3, 4]);`, 12},
+        {"const [a, b] = [1, 2]; a = 3;", "assignment to constant: a"},
+        {"[a, b] = [1, 2];", "assignment to undeclared identifier: a"},
+        {"let [a, b] = 1;", "cannot destructure INTEGER as array: [a, b]"},
+        {"let [a, b] = [1];", "not enough elements to destructure: expected 2, got 1"},
+        {"let [a] = [1, 2];", "too many elements to destructure: expected 1, got 2"},
+        {`let {name} = [1];`, "cannot destructure ARRAY as hashmap: {name}"},
+        {`let {name} = {"age": 1};`, "missing key in destructuring: name"},
+        {`let [a = b] = [];`, "identifier not found: b"},
+        {`func f([a, b]) { a; } f([1]);`, "not enough elements to destructure: expected 2, got 1"},
+        {"let [_, b] = [1, 2]; b;", 2},
+        {"let [1, b] = [2, 2];", "2 does not match 1"},
+    }
+
+    for _, test := range tests {
+        evaluated := evalTest(test.input)
+
+        switch expected := test.expected.(type) {
+        case int:
+            testIntegerObject(t, evaluated, int64(expected))
+        case string:
+            testErrorObject(t, evaluated, expected)
+        }
+    }
+}
+
+func TestMatchExpression(t *testing.T) {
+    tests := []struct {
+        input string
+        expected any
+    } {
+        {"match (2) { 1 => 10, 2 => 20, _ => 30 };", 20},
+        {"match (5) { 1 => 10, _ => 30 };", 30},
+        {"match (-1) { -1 => 1, _ => 0 };", 1},
+        {"match (2.0) { 2 => 1, _ => 0 };", 1},
+        {`match ("b") { "a" => 1, "b" => 2 };`, 2},
+        {"match (null) { null => 1, _ => 0 };", 1},
+        {"match (false) { true => 1, false => 2 };", 2},
+        {"match (7) { n => n + 1 };", 8},
+        {"match (7) { n: Integer => n, _ => 0 };", 7},
+        {`match ("s") { n: Integer => n, _: String => 1 };`, 1},
+        {"match (1.5) { _: Number => 1, _ => 0 };", 1},
+        {"match (len) { _: Function => 1, _ => 0 };", 1},
+        {"match (-3) { n if n > 0 => 1, n if n < 0 => -1, _ => 0 };", -1},
+        {"match ([1, 2, 3]) { [] => 0, [x] => x, [x, ...rest] => len(rest) };", 2},
+        {"match ([1, 2]) { [1, x] => x, _ => 0 };", 2},
+        {"match ([2, 2]) { [1, x] => x, _ => 0 };", 0},
+        {"match ([[1, 2], 3]) { [[a, b], c] => a + b + c };", 6},
+        {`match ({"type": "circle", "r": 2}) { {"type": "square", "side": s} => s, {"type": "circle", r} => r * 3 };`, 6},
+        {`match ({"a": 1}) { {b} => 1, {a} => 2 };`, 2},
+        {"match (1) { 1 => { let y = 5; y * 2; } _ => 0 }", 10},
+        {"let x = 4; let r = match (x) { 4 => x * 2, _ => 0 }; r;", 8},
+        {"func f(x) { match (x) { 0 => { return 100; } _ => 1 } return 5; } f(0);", 100},
+        {"let n = 1; match (5) { n => n }; n;", 1},
+        {"match (3) { 1 => 1, 2 => 2 };", "no match arm for value: 3"},
+        {"match (3) { n: Widget => 1 };", "unknown type: Widget"},
+        {"match (3) { n if n + true => 1 };", "type mismatch: INTEGER + BOOLEAN"},
+    }
+
+    for _, test := range tests {
+        evaluated := evalTest(test.input)
+
+        switch expected := test.expected.(type) {
+        case int:
+            testIntegerObject(t, evaluated, int64(expected))
+        case string:
+            testErrorObject(t, evaluated, expected)
+        }
+    }
+}
+
+func TestMemberExpressions(t *testing.T) {
+    tests := []struct {
+        input string
+        expected any
+    } {
+        {`"Charm".upper();`, "CHARM"},
+        {`"  Charm ".trim().lower();`, "charm"},
+        {`"a,b,c".split(",");`, "[a, b, c]"},
+        {`"hello".replace("l", "L");`, "heLLo"},
+        {`"hello".starts_with("he");`, true},
+        {`"hello".len();`, 5},
+        {"let arr = [1, 2]; arr.push(3); arr;", "[1, 2, 3]"},
+        {"let arr = [1, 2, 3]; arr.pop(); arr.len();", 2},
+        {"[1, 2, 3].contains(2);", true},
+        {"[1, 2, 3].index_of(4);", -1},
+        {`[1, 2, 3].join("-");`, "1-2-3"},
+        {"[].first();", nil},
+        {`{"a": 1}.keys();`, "[a]"},
+        {`let m = {"a": 1}; m.set("b", 2); m.len();`, 2},
+        {`{"a": 1}.get("b", 5);`, 5},
+        {`{"a": 1}.has("a");`, true},
+        {`let person = {"name": "Ada", "age": 36}; person.age;`, 36},
+        {`{"name": "Ada"}.missing;`, nil},
+        {`{"keys": 1}.keys;`, 1},
+        {`let upper = "x".upper; upper();`, "X"},
+        {`"abc" |> func(s) { s.upper(); };`, "ABC"},
+    }
+
+    for _, test := range tests {
+        evaluated := evalTest(test.input)
+
+        switch expected := test.expected.(type) {
+        case int:
+            testIntegerObject(t, evaluated, int64(expected))
+        case bool:
+            testBooleanObject(t, evaluated, expected)
+        case string:
+            if evaluated.Inspect() != expected {
+                t.Errorf("wrong result for %q. expected=%q, got=%q", test.input, expected, evaluated.Inspect())
+            }
+        case nil:
+            testNullObject(t, evaluated)
+        }
+    }
+}
+
+func TestMemberExpressionErrors(t *testing.T) {
+    tests := []struct {
+        input string
+        expected string
+    } {
+        {"let n = 5; n.upper();", "INTEGER has no member upper"},
+        {`"x".push(1);`, "STRING has no member push"},
+        {`"x".upper(1);`, "wrong number of arguments to upper: expected 0, got 1"},
+        {`"x".split(1);`, "argument to `split` must be STRING, got INTEGER"},
+        {`{}.get([1]);`, "unusable as hashkey in `get`: ARRAY"},
+        {`"x".upper(a: 1);`, "keyword arguments not supported by builtin methods"},
+        {"missing.upper();", "identifier not found: missing"},
+    }
+
+    for _, test := range tests {
+        testErrorObject(t, evalTest(test.input), test.expected)
+    }
+}
+
+func TestStructs(t *testing.T) {
+    point := `struct Point {
+        x;
+        y = 0;
+        func norm() { return self.x * self.x + self.y * self.y; }
+        func moved(dx, dy = 0) { return Point(self.x + dx, self.y + dy); }
+    }
+    `
+    counter := `struct Counter {
+        count;
+        step = 1;
+        func init(start) { self.count = start; }
+        func tick() { self.count = self.count + self.step; return self; }
+    }
+    `
+
+    tests := []struct {
+        input string
+        expected any
+    } {
+        {point + "Point(1, 2).x;", 1},
+        {point + "Point(3).y;", 0},
+        {point + "Point(y: 4, x: 1).y;", 4},
+        {point + "Point(3, 4).norm();", 25},
+        {point + "Point(1, 1).moved(2).x;", 3},
+        {point + "let p = Point(1, 2); p.x = 10; p.x + p.y;", 12},
+        {point + "let norm = Point(1, 2).norm; norm();", 5},
+        {point + "Point(1, 2);", "Point(x: 1, y: 2)"},
+        {point + "Point;", "struct Point"},
+        {point + `Point("a", [1]);`, "Point(x: a, y: [1])"},
+        {point + "Point(1, 2) == Point(1, 2);", true},
+        {point + "Point(1, 2) != Point(2, 1);", true},
+        {point + "let p = Point(1, 2); let q = p; q.x = 5; p.x;", 5},
+        {point + "match (Point(1, 2)) { p: Point => p.y, _ => 0 };", 2},
+        {point + "match (5) { p: Point => 1, _ => 0 };", 0},
+        {counter + "Counter(5).tick().tick().count;", 7},
+        {counter + "Counter(5);", "Counter(count: 5, step: 1)"},
+        {"class Box { value; func get() { return self.value; } } Box(3).get();", 3},
+        {`"a" == "a";`, true},
+        {"[1, [2]] == [1, [2]];", true},
+        {"let arr = [1, 2, 3]; arr[1] = 5; arr;", "[1, 5, 3]"},
+        {`let m = {}; m["a"] = 1; m.b = 2; m.a + m.b;`, 3},
+    }
+
+    for _, test := range tests {
+        evaluated := evalTest(test.input)
+
+        switch expected := test.expected.(type) {
+        case int:
+            testIntegerObject(t, evaluated, int64(expected))
+        case bool:
+            testBooleanObject(t, evaluated, expected)
+        case string:
+            if evaluated.Inspect() != expected {
+                t.Errorf("wrong result. expected=%q, got=%q", expected, evaluated.Inspect())
+            }
+        }
+    }
+}
+
+func TestStructErrors(t *testing.T) {
+    point := "struct Point { x; y = 0; func norm() { return self.z; } }"
+
+    tests := []struct {
+        input string
+        expected string
+    } {
+        {point + "Point(1).z;", "Point has no field z"},
+        {point + "let p = Point(1); p.z = 3;", "Point has no field z"},
+        {point + "Point(1).norm();", "Point has no field z"},
+        {point + "Point(1, 2, 3);", "wrong number of arguments to Point(x, y = 0): expected 1 to 2, got 3"},
+        {point + "Point(1, z: 2);", "unexpected keyword argument z in call to Point(x, y = 0)"},
+        {point + "Point(1) + 1;", "type mismatch: Point + INTEGER"},
+        {point + "struct Point { }", "identifier already declared: Point"},
+        {"let arr = [1]; arr[3] = 1;", "index out of range: 3 with length 1"},
+        {"let n = 1; n.x = 1;", "cannot assign to member of INTEGER"},
+    }
+
+    for _, test := range tests {
+        testErrorObject(t, evalTest(test.input), test.expected)
+    }
+}
+
+func TestOperatorOverloading(t *testing.T) {
+    vector := `struct Vec {
+        x;
+        y;
+        func __add__(other) { return Vec(self.x + other.x, self.y + other.y); }
+        func __sub__(other) { return Vec(self.x - other.x, self.y - other.y); }
+        func __mul__(k) { return Vec(self.x * k, self.y * k); }
+        func __neg__() { return Vec(-self.x, -self.y); }
+        func __lt__(other) { return self.x * self.x + self.y * self.y < other.x * other.x + other.y * other.y; }
+        func __index__(i) { if (i == 0) { return self.x; } return self.y; }
+        func __len__() { return 2; }
+        func __call__(k) { return self.x * k; }
+    }
+    `
+    money := `struct Money {
+        cents;
+        func __eq__(other) { return self.cents / 100 == other.cents / 100; }
+    }
+    `
+    grid := `struct Grid {
+        cells = [0, 0, 0];
+        func __setindex__(i, value) { self.cells[i] = value * 10; }
+        func __index__(i) { return self.cells[i]; }
+    }
+    `
+
+    tests := []struct {
+        input string
+        expected any
+    } {
+        {vector + "Vec(1, 2) + Vec(3, 4);", "Vec(x: 4, y: 6)"},
+        {vector + "Vec(5, 5) - Vec(1, 2);", "Vec(x: 4, y: 3)"},
+        {vector + "Vec(1, 2) * 3;", "Vec(x: 3, y: 6)"},
+        {vector + "-Vec(1, 2);", "Vec(x: -1, y: -2)"},
+        {vector + "Vec(1, 2) < Vec(3, 4);", true},
+        {vector + "Vec(3, 4) > Vec(1, 2);", true},
+        {vector + "Vec(1, 2) >= Vec(3, 4);", false},
+        {vector + "Vec(1, 2) <= Vec(1, 2);", true},
+        {vector + "Vec(7, 9)[1];", 9},
+        {vector + "len(Vec(7, 9));", 2},
+        {vector + "Vec(7, 9)(2);", 14},
+        {vector + "Vec(1, 2) == Vec(1, 2);", true},
+        {money + "Money(150) == Money(199);", true},
+        {money + "Money(150) != Money(250);", true},
+        {money + "Money(150) == Money(250);", false},
+        {grid + "let g = Grid(); g[1] = 4; g[1];", 40},
+    }
+
+    for _, test := range tests {
+        evaluated := evalTest(test.input)
+
+        switch expected := test.expected.(type) {
+        case int:
+            testIntegerObject(t, evaluated, int64(expected))
+        case bool:
+            testBooleanObject(t, evaluated, expected)
+        case string:
+            if evaluated.Inspect() != expected {
+                t.Errorf("wrong result. expected=%q, got=%q", expected, evaluated.Inspect())
+            }
+        }
+    }
+}
+
+func TestOperatorOverloadingErrors(t *testing.T) {
+    point := "struct Point { x; func __add__(other) { return self.x + other; } }"
+
+    tests := []struct {
+        input string
+        expected string
+    } {
+        {point + "Point(1) * 2;", "type mismatch: Point * INTEGER"},
+        {point + "Point(1) + true;", "type mismatch: INTEGER + BOOLEAN"},
+        {point + "Point(1)[0];", "index operator not supported: Point"},
+        {point + "Point(1)();", "not a function: Point"},
+        {point + "len(Point(1));", "argument to `len` not supported, got Point"},
+        {point + "-Point(1);", "unknown operator: -Point"},
+    }
+
+    for _, test := range tests {
+        testErrorObject(t, evalTest(test.input), test.expected)
+    }
+}
+
+func TestEnums(t *testing.T) {
+    enums := `enum Color { Red, Green, Blue }
+    enum Result { Ok(value), Err(message) }
+    func check(r) {
+        return match (r) {
+            Result.Ok(v) => v,
+            Result.Err(m) => len(m),
+        };
+    }
+    `
+
+    tests := []struct {
+        input string
+        expected any
+    } {
+        {enums + "Color.Red;", "Color.Red"},
+        {enums + "Result.Ok(5);", "Result.Ok(5)"},
+        {enums + "Result.Ok;", "Result.Ok(value)"},
+        {enums + "Color;", "enum Color"},
+        {enums + "Color.Red == Color.Red;", true},
+        {enums + "Color.Red == Color.Blue;", false},
+        {enums + "Result.Ok([1]) == Result.Ok([1]);", true},
+        {enums + "Result.Ok(1) == Result.Err(1);", false},
+        {enums + "Result.Ok(7).value;", 7},
+        {enums + "check(Result.Ok(3));", 3},
+        {enums + `check(Result.Err("bad"));`, 3},
+        {enums + "match (Color.Green) { Color.Red => 1, Color.Green => 2, _ => 3 };", 2},
+        {enums + "match (Color.Green) { c: Color => 1, _ => 2 };", 1},
+        {enums + "match (Result.Ok(1)) { c: Color => 1, _ => 2 };", 2},
+        {enums + "match (Result.Ok([1, 2])) { Result.Ok([a, b]) => a + b, _ => 0 };", 3},
+        {enums + `let m = {Color.Red: "stop", Color.Green: "go"}; m[Color.Green];`, "go"},
+        {enums + `let m = {Result.Ok(1): "one"}; m[Result.Ok(1)];`, "one"},
+        {enums + "variants(Color);", "[Red, Green, Blue]"},
+        {enums + "variant(Result.Err(0));", "Err"},
+        {enums + "let ok = Result.Ok; ok(2).value;", 2},
+    }
+
+    for _, test := range tests {
+        evaluated := evalTest(test.input)
+
+        switch expected := test.expected.(type) {
+        case int:
+            testIntegerObject(t, evaluated, int64(expected))
+        case bool:
+            testBooleanObject(t, evaluated, expected)
+        case string:
+            if evaluated.Inspect() != expected {
+                t.Errorf("wrong result. expected=%q, got=%q", expected, evaluated.Inspect())
+            }
+        }
+    }
+}
+
+func TestEnumErrors(t *testing.T) {
+    enums := "enum Color { Red, Green } enum Result { Ok(value), Err(message) }"
+
+    tests := []struct {
+        input string
+        expected string
+    } {
+        {enums + "Color.Purple;", "Color has no variant Purple"},
+        {enums + "Result.Ok(1, 2);", "wrong number of arguments to Result.Ok(value): expected 1, got 2"},
+        {enums + "Result.Ok(value: 1);", "keyword arguments not supported by enum variants"},
+        {enums + "Result.Ok(1).message;", "Result.Ok has no field message"},
+        {enums + "Color.Red();", "not a function: Color"},
+        {enums + "match (Color.Red) { Result.Ok => 1 };", "wrong number of fields in pattern Result.Ok: expected 1, got 0"},
+        {enums + "match (Color.Red) { Color.Blue => 1 };", "Color has no variant Blue"},
+        {enums + "match (Color.Red) { Shape.Round => 1 };", "unknown enum: Shape"},
+        {enums + "match (Color.Red) { Color.Green => 1 };", "no match arm for value: Color.Red"},
+        {enums + "variants(Color.Red);", "argument to `variants` must be ENUM, got Color"},
+    }
+
+    for _, test := range tests {
+        testErrorObject(t, evalTest(test.input), test.expected)
+    }
+}
+
+func TestSetsAndTuples(t *testing.T) {
+    tests := []struct {
+        input string
+        expected any
+    } {
+        {"{1, 2, 2, 3, 1};", "{1, 2, 3}"},
+        {`{"a", true, 1};`, "{a, true, 1}"},
+        {"len({1, 1, 2});", 2},
+        {"{1, 2}.contains(2);", true},
+        {"{1, 2}.contains([1]);", false},
+        {"{1, 2}.union({2, 3});", "{1, 2, 3}"},
+        {"{1, 2, 3}.intersection({2, 3, 4});", "{2, 3}"},
+        {"{1, 2, 3}.difference({2});", "{1, 3}"},
+        {"let s = {1}; s.add(2); s.remove(1); s;", "{2}"},
+        {"{1, 2} == {2, 1};", true},
+        {"set([3, 3, 4]);", "{3, 4}"},
+        {"set();", "{}"},
+        {"(1, 2);", "(1, 2)"},
+        {"(1,);", "(1,)"},
+        {"();", "()"},
+        {"(1, [2]) == (1, [2]);", true},
+        {"(1, 2) == (2, 1);", false},
+        {"(4, 5)[1];", 5},
+        {"len((1, 2, 3));", 3},
+        {"let [a, b] = (1, 2); a + b;", 3},
+        {`let grid = {(0, 0): "origin", (1, 2): "point"}; grid[(1, 2)];`, "point"},
+        {"{(1, 2), (1, 2), (2, 1)}.len();", 2},
+        {"match ((1, 2)) { _: Tuple => 1, _ => 0 };", 1},
+    }
+
+    for _, test := range tests {
+        evaluated := evalTest(test.input)
+
+        switch expected := test.expected.(type) {
+        case int:
+            testIntegerObject(t, evaluated, int64(expected))
+        case bool:
+            testBooleanObject(t, evaluated, expected)
+        case string:
+            if evaluated.Inspect() != expected {
+                t.Errorf("wrong result for %q. expected=%q, got=%q", test.input, expected, evaluated.Inspect())
+            }
+        }
+    }
+}
+
+func TestSetAndTupleErrors(t *testing.T) {
+    tests := []struct {
+        input string
+        expected string
+    } {
+        {"{[1], 2};", "unusable as set element: ARRAY"},
+        {"{1}.union([2]);", "argument to `union` must be SET, got ARRAY"},
+        {"{1}.add({});", "unusable as set element in `add`: HASHMAP"},
+        {"let t = (1, 2); t[0] = 5;", "index assignment not supported: TUPLE"},
+        {"set(1);", "argument to `set` must be ARRAY, TUPLE or SET, got INTEGER"},
+        {"{1}[0];", "index operator not supported: SET"},
+        {"let arr = [1]; {(1, arr): 2};", "Object not hashable: TUPLE"},
+        {"let arr = [1]; let m = {}; m[(1, arr)] = 2;", "unusable as haskey: TUPLE"},
+        {"let m = {(1, 2): 3}; m[(1, [2])];", "unusable as haskey: TUPLE"},
+        {"{(1, ((2, {}),))};", "unusable as set element: TUPLE"},
+        {"enum Box { Of(value) } {Box.Of([1])};", "unusable as set element: Box"},
+    }
+
+    for _, test := range tests {
+        testErrorObject(t, evalTest(test.input), test.expected)
+    }
+}
+
+func TestFunctionObject(t *testing.T) {
+    test := "func(x) { x + 2; };"
+    evaluated := evalTest(test)
+
+    funcObj, ok := evaluated.(*object.Function)
+    if !ok {
+        t.Errorf("object not Function. Got type=%T(%+v)", evaluated, evaluated)
+        return
+    }
+
+    params := funcObj.Parameters
+    if len(params) != 1 {
+        t.Fatalf("Function has more than 1 parameter. Got=%d)", len(params))
+    }
+    if params[0].String() != "x" {
+        t.Fatalf("Function paramter not X. Got=%s)", params[0].String())
+    }
+
+    expectedBody := "(x + 2)"
+    if funcObj.Body.String() != expectedBody {
+        t.Fatalf("body is %q. got %q", expectedBody, funcObj.Body.String())
+    }
+}
+
+func TestFunctionStatement(t *testing.T) {
+    test := "func z(x) { x + 2; }"
+    evaluated := evalTest(test)
+
+    funcObj, ok := evaluated.(*object.Function)
+    if !ok {
+        t.Errorf("object not Function. Got type=%T(%+v)", evaluated, evaluated)
+        return
+    }
+
+    params := funcObj.Parameters
+    if len(params) != 1 {
+        t.Fatalf("Function has more than 1 parameter. Got=%d)", len(params))
+    }
+    if params[0].String() != "x" {
+        t.Fatalf("Function paramter not X. Got=%s)", params[0].String())
+    }
+
+    expectedBody := "(x + 2)"
+    if funcObj.Body.String() != expectedBody {
+        t.Fatalf("body is %q. got %q", expectedBody, funcObj.Body.String())
+    }
+
+}
+
+func TestFunctionCall(t *testing.T) {
+    tests := []struct {
+        input string
+        expected int64
+    } {
+        {"let identity = func(x) { x; }; identity(5);", 5},
+        {"let identity = func(x) { return x; }; identity(5);", 5},
+        {"let double = func(x) { x * 2; }; double(5);", 10},
+        {"let add = func(x, y) { x + y; }; add(5, 5);", 10},
+        {"let add = func(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
+        {"func(x) { x; }(5);", 5},
+    }
+
+    for _, test := range tests {
+        evaluated := evalTest(test.input)
+        testIntegerObject(t, evaluated, test.expected)
+    }
+}
+
+func TestFunctionArguments(t *testing.T) {
+    tests := []struct {
+        input string
+        expected any
+    } {
+        {"func f(a, b = 2) { a + b; } f(1);", 3},
+        {"func f(a, b = 2) { a + b; } f(1, 5);", 6},
+        {"func f(a, b = a * 10) { a + b; } f(1);", 11},
+        {"func f(first, ...rest) { len(rest); } f(1, 2, 3);", 2},
+        {"func f(first, ...rest) { len(rest); } f(1);", 0},
+        {"func f(...rest) { rest[1]; } f(1, 2, 3);", 2},
+        {"func f(a, b, c) { a * 100 + b * 10 + c; } let xs = [1, 2, 3]; f(...xs);", 123},
+        {"func f(a, b, c) { a * 100 + b * 10 + c; } f(1, ...[2, 3]);", 123},
+        {"let xs = [2, 3]; len([1, ...xs, 4]);", 4},
+        {"func f(a, b = 2, c = 3) { a * 100 + b * 10 + c; } f(1, c: 5);", 125},
+        {"func f(a, b) { a - b; } f(b: 1, a: 3);", 2},
+        {"let sub = func(a, b) { a - b; }; sub(a: 5, b: 2);", 3},
+        {
+            "func add(a, b) { a + b; } add(1, 2, 3);",
+            "wrong number of arguments to add(a, b): expected 2, got 3",
+        },
+        {
+            "func add(a, b) { a + b; } add(1);",
+            "wrong number of arguments to add(a, b): expected 2, got 1",
+        },
+        {
+            "func f(a, b = 2) { a; } f();",
+            "wrong number of arguments to f(a, b = 2): expected 1 to 2, got 0",
+        },
+        {
+            "func f(a, ...rest) { a; } f();",
+            "wrong number of arguments to f(a, ...rest): expected at least 1, got 0",
+        },
+        {
+            "func(a) { a; }(1, 2);",
+            "wrong number of arguments to func(a): expected 1, got 2",
+        },
+        {
+            "let g = func(a) { a; }; g();",
+            "wrong number of arguments to g(a): expected 1, got 0",
+        },
+        {
+            "func f(a) { a; } f(b: 1);",
+            "unexpected keyword argument b in call to f(a)",
+        },
+        {
+            "func f(a) { a; } f(1, a: 2);",
+            "multiple values for argument a in call to f(a)",
+        },
+        {
+            "func f(a) { a; } f(a: 1, a: 2);",
+            "duplicate keyword argument: a",
+        },
+        {
+            "func f(a) { a; } f(...1);",
+            "cannot spread INTEGER",
+        },
+        {
+            `len(x: "abc");`,
+            "keyword arguments not supported by builtin functions",
+        },
+    }
+
+    for _, test := range tests {
+        evaluated := evalTest(test.input)
+
+        switch expected := test.expected.(type) {
+        case int:
+            testIntegerObject(t, evaluated, int64(expected))
+        case string:
+            testErrorObject(t, evaluated, expected)
+        }
+    }
+}
+
+func TestLambdaAndPipeExpressions(t *testing.T) {
+    tests := []struct {
+        input string
+        expected any
+    } {
+        {"let double = x => x * 2; double(4);", 8},
+        {"let add = (a, b) => a + b; add(2, 3);", 5},
+        {"let add = (a, b = 10) => { return a + b; }; add(2);", 12},
+        {"(() => 7)();", 7},
+        {"let adder = x => y => x + y; adder(1)(2);", 3},
+        {"let sub = (a, b) => a - b; 10 |> sub(3);", 7},
+        {"let double = x => x * 2; 3 |> double |> double;", 12},
+        {"5 |> (x => x + 1);", 6},
+        {"[1, 2, 3] |> len;", 3},
+        {"let sub = (a, b) => a - b; 1 + 2 |> sub(b: 1);", 2},
+        {"let f = x => x; f(1, 2);", "wrong number of arguments to f(x): expected 1, got 2"},
+        {"1 |> 2;", "not a function: INTEGER"},
+    }
+
+    for _, test := range tests {
+        evaluated := evalTest(test.input)
+
+        switch expected := test.expected.(type) {
+        case int:
+            testIntegerObject(t, evaluated, int64(expected))
+        case string:
+            testErrorObject(t, evaluated, expected)
+        }
+    }
+}
+
+func TestClosures(t *testing.T) {
+    input := `
+    let newAdder = func(x) {
+        func(y) { x + y; };
+    };
+    let addTwo = newAdder(2);
+    addTwo(2);`
+
+    testIntegerObject(t, evalTest(input), 4)
+}
+
+func TestArrayLiterals(t *testing.T) {
+    input := "[1, 2 * 2, 3 + 3];"
+    evaluated := evalTest(input)
+    result, ok := evaluated.(*object.Array)
+    if !ok {
+        t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+    }
+    if len(result.Elements) != 3 {
+        t.Fatalf("array has wrong num of elements. got=%d",
+            len(result.Elements))
+    }
+    testIntegerObject(t, result.Elements[0], 1)
+    testIntegerObject(t, result.Elements[1], 4)
+    testIntegerObject(t, result.Elements[2], 6)
+}
+
+func TestArrayIndexExpressions(t *testing.T) {
+    tests := []struct {
+        input string
+        expected interface{}
+    }{
+        {
+            "[1, 2, 3][0];", 1,
+        },
+        {
+            "[1, 2, 3][1];", 2,
+        },
+        {
+            "[1, 2, 3][2];", 3,
+        },
+        {
+            "let i = 0; [1][i];", 1,
+        },
+        {
+            "[1, 2, 3][1 + 1];", 3,
+        },
+        {
+            "let myArray = [1, 2, 3]; myArray[2];", 3,
+        },
+        {
+            "let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];", 6,
+        },
+        {
+            "let myArray = [1, 2, 3]; let i = myArray[0]; myArray[i];", 2,
+        },
+        {
+            "[1, 2, 3][3];", nil,
+        },
+        {
+            "[1, 2, 3][-1];", 3,
+        },
+        {
+            "[1, 2, 3][-3];", 1,
+        },
+        {
+            "[1, 2, 3][-4];", nil,
+        },
+    }
+    for _, tt := range tests {
+        evaluated := evalTest(tt.input)
+        integer, ok := tt.expected.(int)
+        if ok {
+            testIntegerObject(t, evaluated, int64(integer))
+        } else {
+            testNullObject(t, evaluated)
+        }
+    }
+}
+
+func TestSliceAndStringIndexExpressions(t *testing.T) {
+    tests := []struct {
+        input string
+        expected any
+    } {
+        {"[1, 2, 3, 4, 5][1:3];", "[2, 3]"},
+        {"[1, 2, 3, 4, 5][:2];", "[1, 2]"},
+        {"[1, 2, 3, 4, 5][3:];", "[4, 5]"},
+        {"[1, 2, 3, 4, 5][:];", "[1, 2, 3, 4, 5]"},
+        {"[1, 2, 3, 4, 5][::2];", "[1, 3, 5]"},
+        {"[1, 2, 3, 4, 5][::-1];", "[5, 4, 3, 2, 1]"},
+        {"[1, 2, 3, 4, 5][-2:];", "[4, 5]"},
+        {"[1, 2, 3, 4, 5][1:-1];", "[2, 3, 4]"},
+        {"[1, 2, 3, 4, 5][3:0:-1];", "[4, 3, 2]"},
+        {"[1, 2, 3][1:100];", "[2, 3]"},
+        {"[1, 2, 3][5:1];", "[]"},
+        {"[1, 2, 3][null:2];", "[1, 2]"},
+        {"(1, 2, 3)[1:];", "(2, 3)"},
+        {`"hello"[1:4];`, "ell"},
+        {`"hello"[::-1];`, "olleh"},
+        {`"hello"[0];`, "h"},
+        {`"hello"[-1];`, "o"},
+        {`"héllo"[1];`, "é"},
+        {`"日本語"[1:];`, "本語"},
+        {`"abc"[5];`, nil},
+    }
+
+    for _, test := range tests {
+        evaluated := evalTest(test.input)
+
+        switch expected := test.expected.(type) {
+        case string:
+            if evaluated.Inspect() != expected {
+                t.Errorf("wrong result for %q. expected=%q, got=%q", test.input, expected, evaluated.Inspect())
+            }
+        case nil:
+            testNullObject(t, evaluated)
+        }
+    }
+}
+
+func TestSliceErrors(t *testing.T) {
+    tests := []struct {
+        input string
+        expected string
+    } {
+        {"[1, 2][::0];", "slice step cannot be zero"},
+        {`[1, 2]["a":];`, "slice bound must be INTEGER, got STRING"},
+        {"{1}[1:];", "slice operator not supported: SET"},
+        {`"abc"["a"];`, "not an integer: STRING"},
+    }
+
+    for _, test := range tests {
+        testErrorObject(t, evalTest(test.input), test.expected)
+    }
+}
+
+func TestStrictMode(t *testing.T) {
+    tests := []struct {
+        input string
+        expected any
+    } {
+        {"[1, 2, 3][3];", "index out of range: 3 with length 3"},
+        {"[1, 2, 3][-4];", "index out of range: -4 with length 3"},
+        {`"abc"[3];`, "index out of range: 3 with length 3"},
+        {"func f(a) { a[5]; } f([1]);", "index out of range: 5 with length 1"},
+        {"[1, 2, 3][1:10];", "[2, 3]"},
+        {"[1, 2, 3][-1];", "3"},
+    }
+
+    for _, test := range tests {
+        program := parser.New(lexer.New(test.input)).ParseProgram()
+        env := object.NewEnvironment()
+        env.Runtime().Strict = true
+
+        evaluated := Eval(program, env)
+
+        if errObj, ok := evaluated.(*object.Error); ok {
+            if errObj.Message != test.expected {
+                t.Errorf("wrong error for %q. expected=%q, got=%q", test.input, test.expected, errObj.Message)
+            }
+        } else if evaluated.Inspect() != test.expected {
+            t.Errorf("wrong result for %q. expected=%q, got=%q", test.input, test.expected, evaluated.Inspect())
+        }
+    }
+}
+
+func TestMembershipAndNullSafeOperators(t *testing.T) {
+    tests := []struct {
+        input string
+        expected any
+    } {
+        {"2 in [1, 2, 3];", true},
+        {"4 in [1, 2, 3];", false},
+        {"4 not in [1, 2, 3];", true},
+        {"[1] in [[1], 2];", true},
+        {"1 in (1, 2);", true},
+        {`"ell" in "hello";`, true},
+        {`"z" not in "hello";`, true},
+        {`"a" in {"a": null};`, true},
+        {`"b" in {"a": null};`, false},
+        {"3 in {1, 2};", false},
+        {"[3] in {1, 2};", false},
+        {"struct Even { func __contains__(x) { return x / 2 * 2 == x; } } 4 in Even();", true},
+        {"null ?? 5;", 5},
+        {"3 ?? 5;", 3},
+        {"false ?? 5;", false},
+        {"null ?? null ?? 7;", 7},
+        {"let hits = 0; func f() { hits = hits + 1; return 1; } 2 ?? f(); hits;", 0},
+        {"let m = null; m?.name;", nil},
+        {`let m = {"name": "x"}; m?.name;`, "x"},
+        {"let a = null; a?[0];", nil},
+        {"let a = [[1, 2]]; a?[0]?[1];", 2},
+        {"let a = null; a?[1:];", nil},
+        {`let user = {"address": null}; user?.address?.city ?? "unknown";`, "unknown"},
+        {`let user = {"tags": ["a"]}; user?.tags?[0] ?? "none";`, "a"},
+        {"let a = null; a?.b.c;", nil},
+        {"let a = null; a?.b[0].c;", nil},
+        {"let a = null; a?[0].b;", nil},
+        {"let a = null; a?.b[1:].c;", nil},
+        {"let a = null; a?.b();", nil},
+        {"let a = null; let hits = 0; func f() { hits = hits + 1; return 1; } a?.b(f()); hits;", 0},
+        {`let a = null; a?.b.c ?? "none";`, "none"},
+        {`let m = {"f": func() { return {"x": 1}; }}; m?.f().x;`, 1},
+    }
+
+    for _, test := range tests {
+        evaluated := evalTest(test.input)
+
+        switch expected := test.expected.(type) {
+        case int:
+            testIntegerObject(t, evaluated, int64(expected))
+        case bool:
+            testBooleanObject(t, evaluated, expected)
+        case string:
+            if evaluated.Inspect() != expected {
+                t.Errorf("wrong result for %q. expected=%q, got=%q", test.input, expected, evaluated.Inspect())
+            }
+        case nil:
+            testNullObject(t, evaluated)
+        }
+    }
+}
+
+func TestMembershipErrors(t *testing.T) {
+    tests := []struct {
+        input string
+        expected string
+    } {
+        {`1 in "abc";`, "'in <string>' requires STRING as left operand, got INTEGER"},
+        {`[1] in {"a": 1};`, "unusable as haskey: ARRAY"},
+        {"1 in 5;", "'in' not supported: INTEGER"},
+        {"let a = null; a.b;", "NULL has no member b"},
+        {"let a = null; a[0];", "index operator not supported: NULL"},
+        {`let a = {"b": null}; a?.b.c;`, "NULL has no member c"},
+        {"missing ?? 1;", "identifier not found: missing"},
+    }
+
+    for _, test := range tests {
+        testErrorObject(t, evalTest(test.input), test.expected)
+    }
+}
+
+func writeModules(t *testing.T, files map[string]string) string {
+    dir := t.TempDir()
+    for name, source := range files {
+        path := filepath.Join(dir, name)
+        if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+            t.Fatal(err)
+        }
+        if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+            t.Fatal(err)
+        }
+    }
+    return dir
+}
+
+func TestModules(t *testing.T) {
+    dir := writeModules(t, map[string]string{
+        "lib/utils.ch": `
+            import { square } from "./shapes/math";
+            export func double(x) { return x * 2; }
+            export const name = "utils";
+            export let [first, second] = [1, 2];
+            export func area(r) { return square(r) * 3; }
+            let hidden = 1;
+        `,
+        "lib/shapes/math.ch": "export func square(x) { return x * x; }",
+        "lib/counter.ch": `
+            let count = 0;
+            export func next() { count = count + 1; return count; }
+        `,
+        "a.ch": `import "b"; export let a = 1;`,
+        "b.ch": `import "a"; export let b = 2;`,
+        "nested.ch": "func f() { export let x = 1; } f();",
+        "broken.ch": "let = 1;",
+        "local.ch": "export let where = 1;",
+    })
+
+    tests := []struct {
+        input string
+        expected any
+    } {
+        {`import "utils"; utils.double(21);`, 42},
+        {`import { double, name } from "utils"; double(2) + len(name);`, 9},
+        {`import { first, second } from "utils"; first + second;`, 3},
+        {`import { area } from "utils"; area(2);`, 12},
+        {`import "utils"; utils;`, "module utils"},
+        {`import "lib/utils.ch"; utils.name;`, "utils"},
+        {`import "utils"; let d = utils.double; import { double } from "utils"; d == double;`, "true"},
+        {`import "counter"; import { next } from "counter"; next(); counter.next();`, 2},
+        {`import "local"; local.where;`, 1},
+        {`import "utils"; utils.hidden;`, "module utils has no export hidden"},
+        {`import { hidden } from "utils";`, "module utils has no export hidden"},
+        {`import "missing";`, "module not found: missing"},
+        {`import "./utils";`, "module not found: ./utils"},
+        {`import "a";`, "circular import: a.ch -> b.ch -> a.ch"},
+        {`import "nested";`, "export is only allowed at the top level of a module: x"},
+        {`import "broken";`, "parse error in module broken: expected next token to be IDENT, got = instead"},
+        {`let utils = 1; import "utils";`, "identifier already declared: utils"},
+    }
+
+    for _, test := range tests {
+        runtime := object.NewRuntime()
+        runtime.SearchPath = []string{filepath.Join(dir, "lib")}
+        env := object.NewModuleEnvironment(runtime, dir)
+
+        evaluated := Eval(parser.New(lexer.New(test.input)).ParseProgram(), env)
+
+        switch expected := test.expected.(type) {
+        case int:
+            testIntegerObject(t, evaluated, int64(expected))
+        case string:
+            if evaluated.Inspect() != expected && evaluated.Inspect() != "ERROR:" + expected {
+                t.Errorf("wrong result for %q. expected=%q, got=%q", test.input, expected, evaluated.Inspect())
+            }
+        }
+    }
+}
+
+func TestStringsLibrary(t *testing.T) {
+    tests := []struct {
+        input string
+        expected any
+    } {
+        {`len("héllo");`, 5},
+        {`"héllo".len();`, 5},
+        {`"héllo".byte_len();`, 6},
+        {`"日本".chars();`, "[日, 本]"},
+        {`"héllo".find("l");`, 2},
+        {`"hello".find("z");`, -1},
+        {`"héllo".index("o");`, 4},
+        {`"banana".count("a");`, 3},
+        {`"a b  c".split();`, "[a, b, c]"},
+        {`"a,b,c".split(",", 1);`, "[a, b,c]"},
+        {`"xxhixx".trim("x");`, "hi"},
+        {`"  hi  ".trim_start();`, "hi  "},
+        {`"  hi  ".trim_end();`, "  hi"},
+        {`"aaa".replace("a", "b", 2);`, "bba"},
+        {`"ab".repeat(3);`, "ababab"},
+        {`"".repeat(9223372036854775807);`, ""},
+        {`"7".pad_left(3, "0");`, "007"},
+        {`"é".pad_right(3);`, "é  "},
+        {`"long".pad_left(2);`, "long"},
+        {`"émile".upper();`, "ÉMILE"},
+        {`import "strings"; strings.split("a-b", "-");`, "[a, b]"},
+        {`import "strings"; strings.join(["a", 1, true], ", ");`, "a, 1, true"},
+        {`import "strings"; strings.join(("a", "b"), "");`, "ab"},
+        {`import { upper, ends_with } from "strings"; ends_with(upper("abc"), "C");`, true},
+    }
+
+    for _, test := range tests {
+        evaluated := evalTest(test.input)
+
+        switch expected := test.expected.(type) {
+        case int:
+            testIntegerObject(t, evaluated, int64(expected))
+        case bool:
+            testBooleanObject(t, evaluated, expected)
+        case string:
+            if evaluated.Inspect() != expected {
+                t.Errorf("wrong result for %q. expected=%q, got=%q", test.input, expected, evaluated.Inspect())
+            }
+        }
+    }
+}
+
+func TestStringsLibraryErrors(t *testing.T) {
+    tests := []struct {
+        input string
+        expected string
+    } {
+        {`"abc".index("z");`, `substring not found: "z"`},
+        {`"abc".repeat(-1);`, "negative count in `repeat`: -1"},
+        {`"ab".repeat(9223372036854775807);`, "result of `repeat` would exceed 1073741824 bytes"},
+        {`"ab".pad_left(9223372036854775807);`, "result of `pad_left` would exceed 1073741824 bytes"},
+        {`"ab".pad_right(9223372036854775807, "é");`, "result of `pad_right` would exceed 1073741824 bytes"},
+        {`"abc".pad_left(5, "ab");`, "fill argument to `pad_left` must be a single character, got \"ab\""},
+        {`"abc".pad_left("5");`, "argument to `pad_left` must be INTEGER, got STRING"},
+        {`"abc".split(",", 1, 2);`, "wrong number of arguments to split: expected 0 to 2, got 3"},
+        {`import "strings"; strings.upper();`, "missing string argument to `upper`"},
+        {`import "strings"; strings.upper(1);`, "argument to `upper` must be STRING, got INTEGER"},
+        {`import "strings"; strings.join("ab", "");`, "argument to `join` must be ARRAY or TUPLE, got STRING"},
+        {`import { missing } from "strings";`, "module strings has no export missing"},
+    }
+
+    for _, test := range tests {
+        testErrorObject(t, evalTest(test.input), test.expected)
+    }
+}
+
+func TestMathLibrary(t *testing.T) {
+    tests := []struct {
+        input string
+        expected any
+    } {
+        {"math.abs(-3);", 3},
+        {"math.abs(-2.5);", 2.5},
+        {"math.floor(2.7);", 2},
+        {"math.floor(-2.5);", -3},
+        {"math.ceil(2.1);", 3},
+        {"math.trunc(-2.7);", -2},
+        {"math.floor(4);", 4},
+        {"math.round(2.5);", 3},
+        {"math.round(-2.5);", -3},
+        {`math.round(2.5, 0, "half_even");`, 2.0},
+        {`math.round(2.5, 0, "half_down");`, 2.0},
+        {`math.round(2.41, 1, "ceil");`, 2.5},
+        {"math.round(3.14159, 2);", 3.14},
+        {"math.round(1250, -2);", 1300},
+        {"math.round(7, 2);", 7},
+        {"math.pow(2, 10);", 1024},
+        {"math.pow(2, 62);", 4611686018427387904},
+        {"math.pow(-2, 63);", -9223372036854775808},
+        {"math.pow(2, 64);", 18446744073709551616.0},
+        {"math.pow(10, 19);", 1e19},
+        {"math.pow(-1, 1000001);", -1},
+        {"math.abs(math.min_int);", 9223372036854775808.0},
+        {"math.abs(-math.max_int);", 9223372036854775807},
+        {"math.pow(2, -1);", 0.5},
+        {"math.pow(4, 0.5);", 2.0},
+        {"math.sqrt(16);", 4.0},
+        {"math.log(8, 2);", 3.0},
+        {"math.log10(1000);", 3.0},
+        {"math.log(math.e);", 1.0},
+        {"math.atan2(0, 1);", 0.0},
+        {"math.hypot(3, 4);", 5.0},
+        {"math.gcd(12, 18);", 6},
+        {"math.gcd(-4, 6);", 2},
+        {"math.lcm(4, 6);", 12},
+        {"math.lcm(0, 6);", 0},
+        {"math.clamp(15, 0, 10);", 10},
+        {"math.clamp(-1, 0, 10);", 0},
+        {"math.clamp(2.5, 0, 10);", 2.5},
+        {"math.min(3, 1.5, 2);", 1.5},
+        {"math.max([1, 7, 3]);", 7},
+        {"math.max(1, 1.0);", 1},
+        {"math.is_nan(math.nan);", true},
+        {"math.is_inf(-math.inf);", true},
+        {"math.max_int;", 9223372036854775807},
+        {"math.pi;", 3.141592653589793},
+    }
+
+    for _, test := range tests {
+        evaluated := evalTest(`import "math"; ` + test.input)
+
+        switch expected := test.expected.(type) {
+        case int:
+            testIntegerObject(t, evaluated, int64(expected))
+        case float64:
+            testFloatObject(t, evaluated, expected)
+        case bool:
+            testBooleanObject(t, evaluated, expected)
+        }
+    }
+}
+
+func TestMathLibraryErrors(t *testing.T) {
+    tests := []struct {
+        input string
+        expected string
+    } {
+        {"math.sqrt(-1);", "math domain error: sqrt(-1)"},
+        {"math.log(0);", "math domain error: log(0)"},
+        {"math.log(8, 1);", "math domain error: log(8, 1)"},
+        {"math.asin(2);", "math domain error: asin(2)"},
+        {"math.pow(-8, 0.5);", "math domain error: pow(-8, 0.5)"},
+        {`math.sqrt("4");`, "argument to `sqrt` must be INTEGER or FLOAT, got STRING"},
+        {"math.gcd(1.5, 2);", "argument to `gcd` must be INTEGER, got FLOAT"},
+        {"math.floor(math.inf);", "cannot convert +Inf to INTEGER"},
+        {"math.floor(100000000000000000000.0);", "cannot convert 1e+20 to INTEGER"},
+        {"math.ceil(-100000000000000000000.0);", "cannot convert -1e+20 to INTEGER"},
+        {"math.round(100000000000000000000.0);", "cannot convert 1e+20 to INTEGER"},
+        {"math.round(9000000000000000000, -19);", "cannot convert 1e+19 to INTEGER"},
+        {"math.lcm(4611686018427387904, 3);", "integer overflow: lcm(4611686018427387904, 3)"},
+        {"math.lcm(math.min_int, -1);", "integer overflow: lcm(-9223372036854775808, -1)"},
+        {"math.gcd(math.min_int, 0);", "integer overflow: gcd(-9223372036854775808, 0)"},
+        {`math.clamp(1, 0, "10");`, "argument to `clamp` must be INTEGER or FLOAT, got STRING"},
+        {`math.round(1.5, 0, "sideways");`, "unknown rounding mode: sideways"},
+        {"math.clamp(1, 10, 0);", "lower bound of `clamp` is greater than upper bound: 10 > 0"},
+        {"math.min([]);", "`min` of an empty sequence"},
+        {`math.max(1, "a");`, "argument to `max` must be INTEGER or FLOAT, got STRING"},
+        {"math.sqrt();", "wrong number of arguments. got=0, want=1"},
+    }
+
+    for _, test := range tests {
+        testErrorObject(t, evalTest(`import "math"; ` + test.input), test.expected)
+    }
+}
+
+func TestTimeLibrary(t *testing.T) {
     tests := []struct {
         input string
-        expected any
+        expected string
     } {
-        {"if (true) { 10; }", 10},
-        {"if (false) { 10; }", nil},
-        {"if (1) { 10; }", 10},
-        {"if (1 < 2) { 10; }", 10},
-        {"if (1 > 2) { 10; }", nil},
-        {"if (1 > 2) { 10; } else { 20; }", 20},
-        {"if (1 < 2) { 10; } else { 20; }", 10},
-        {"if (1 < 2) { 10; 20; 30; } else { 20; }", 30},
+        {"time.datetime(2024, 1, 31);", "2024-01-31T00:00:00Z"},
+        {`time.datetime(2024, 1, 31, 10, 30, tz: "Europe/Paris");`, "2024-01-31T10:30:00+01:00"},
+        {`time.datetime(2024, 1, 31, 10, 30, tz: "Europe/Paris").utc();`, "2024-01-31T09:30:00Z"},
+        {`time.datetime(2024, 7, 1, 12).in_tz("America/New_York");`, "2024-07-01T08:00:00-04:00"},
+        {`time.datetime(2024, 7, 1, tz: "Asia/Tokyo").zone();`, "Asia/Tokyo"},
+        {"time.datetime(2024, 2, 29).weekday();", "Thursday"},
+        {"let d = time.datetime(2024, 3, 5, 6, 7, 8); [d.year(), d.month(), d.day(), d.hour(), d.minute(), d.second()];",
+            "[2024, 3, 5, 6, 7, 8]"},
+        {"time.datetime(2024, 12, 31).year_day();", "366"},
+        {"time.datetime(2024, 1, 31) + 36 * time.hour;", "2024-02-01T12:00:00Z"},
+        {"time.hour + time.datetime(2024, 1, 1);", "2024-01-01T01:00:00Z"},
+        {"time.datetime(2024, 1, 31) - time.day;", "2024-01-30T00:00:00Z"},
+        {"time.datetime(2024, 3, 1) - time.datetime(2024, 2, 1);", "696h0m0s"},
+        {"time.datetime(2024, 1, 31).add_date(0, 1, 0);", "2024-03-02T00:00:00Z"},
+        {"time.datetime(2024, 1, 1, 10, 45).truncate(time.hour);", "2024-01-01T10:00:00Z"},
+        {"time.datetime(2024, 1, 1) < time.datetime(2024, 1, 2);", "true"},
+        {`time.datetime(2024, 1, 1, 1, tz: "Europe/Paris") == time.datetime(2024, 1, 1);`, "true"},
+        {"time.datetime(2024, 1, 1) in {time.datetime(2024, 1, 1)};", "true"},
+        {"time.from_unix(86400);", "1970-01-02T00:00:00Z"},
+        {"time.from_unix(1.5);", "1970-01-01T00:00:01.5Z"},
+        {"time.datetime(2024, 1, 1).unix();", "1704067200"},
+        {`time.parse("2024-03-01 08:00", time.date_time[:16]);`, "2024-03-01T08:00:00Z"},
+        {`time.parse("2024-03-01", time.date_only, tz: "Asia/Tokyo");`, "2024-03-01T00:00:00+09:00"},
+        {`time.parse("2024-03-01T08:00:00+02:00", time.rfc3339).hour();`, "8"},
+        {`time.datetime(2024, 3, 1, 8, 5).format("02/01/2006 15:04");`, "01/03/2024 08:05"},
+        {"time.duration(hours: 1, minutes: 30);", "1h30m0s"},
+        {"time.duration(seconds: 1.5);", "1.5s"},
+        {"time.duration();", "0s"},
+        {`time.parse_duration("1h15m");`, "1h15m0s"},
+        {"time.hour - time.minute;", "59m0s"},
+        {"time.minute * 1.5;", "1m30s"},
+        {"time.hour / 4;", "15m0s"},
+        {"time.hour / time.minute;", "60.0"},
+        {"-time.second;", "-1s"},
+        {"(-time.second).abs();", "1s"},
+        {"time.minute > time.second;", "true"},
+        {"time.duration(minutes: 90).hours();", "1.5"},
+        {"time.duration(seconds: 90).minutes();", "1.5"},
+        {"time.duration(seconds: 1.5).milliseconds();", "1500"},
+        {"time.duration(seconds: 95).round(time.minute);", "2m0s"},
+        {"sort([time.hour, time.second, time.minute]);", "[1s, 1m0s, 1h0m0s]"},
+        {"sort([time.datetime(2024, 2, 1), time.datetime(2024, 1, 1)])[0].month();", "1"},
     }
 
     for _, test := range tests {
-        evalulated := evalTest(test.input)
-        integer, ok := test.expected.(int)
-        if ok {
-            testIntegerObject(t, evalulated, int64(integer))
-        } else {
-            testNullObject(t, evalulated)
+        evaluated := evalTest(`import "time"; ` + test.input)
+        if evaluated.Inspect() != test.expected {
+            t.Errorf("wrong result for %q. expected=%q, got=%q", test.input, test.expected, evaluated.Inspect())
         }
     }
 }
 
-func TestReturnStatements(t *testing.T) {
+func TestTimeLibraryErrors(t *testing.T) {
     tests := []struct {
         input string
-        expected int64
+        expected string
     } {
-        {"return 10;", 10},
-        {"return 10; 11;", 10},
-        {"return 2 * 5; 9;", 10},
-        {"9; return 2 * 5; 9;", 10},
-        {`if (10 > 1) {
-            if (10 > 1) {
-                return 10;
-            }
-            return 1;
-            }`, 10,
-        },
+        {"time.datetime(2024, 13, 1);", "month out of range in `datetime`: 13"},
+        {"time.datetime(2023, 2, 29);", "day out of range in `datetime`: 2023-02-29"},
+        {"time.datetime(2024, 1, 1, 24);", "hour out of range in `datetime`: 24"},
+        {`time.datetime(2024, 1, 1, tz: "Mars/Base");`, "unknown time zone Mars/Base"},
+        {`time.datetime(2024, 1, 1).in_tz("Nowhere");`, "unknown time zone Nowhere"},
+        {`time.parse("2024-13-01", time.date_only);`,
+            `parsing time "2024-13-01": month out of range`},
+        {`time.parse_duration("soon");`, `invalid duration: "soon"`},
+        {"time.duration(weeks: 1);", "unexpected keyword argument weeks in call to duration"},
+        {`time.duration(hours: "1");`, "argument to `duration` must be INTEGER or FLOAT, got STRING"},
+        {"time.datetime(2024, 1, 1) + time.datetime(2024, 1, 1);", "unknown operator: DATETIME + DATETIME"},
+        {"time.datetime(2024, 1, 1) + 1;", "type mismatch: DATETIME + INTEGER"},
+        {"time.hour / 0;", "division by zero"},
+        {"time.hour / (time.hour - time.hour);", "division by zero"},
+        {`time.sleep("1s");`, "argument to `sleep` must be DURATION, INTEGER or FLOAT, got STRING"},
+        {"time.since(1);", "argument to `since` must be DATETIME, got INTEGER"},
     }
 
     for _, test := range tests {
-        evaluated := evalTest(test.input)
-        testIntegerObject(t, evaluated, test.expected)
+        testErrorObject(t, evalTest(`import "time"; ` + test.input), test.expected)
     }
 }
 
-func TestWhileStatement(t *testing.T) {
-    input := `
-    x = 10;
-    while (x > 0) { x = x - 1; }
-    `
+// fakeClock advances only when scripts sleep
+type fakeClock struct {
+    now time.Time
+}
 
-    evaluated := evalTest(input)
+func (c *fakeClock) Now() time.Time { return c.now }
+func (c *fakeClock) Sleep(d time.Duration) { c.now = c.now.Add(d) }
 
-    int, ok := evaluated.(*object.Integer)
-    if !ok {
-        t.Fatalf("Expected=%s. Got=%s", object.INTEGER_OBJ, evaluated.Type())
-    }
+func TestTimeLibraryClock(t *testing.T) {
+    runtime := object.NewRuntime()
+    runtime.Clock = &fakeClock{now: time.Date(2024, 5, 17, 9, 0, 0, 0, time.UTC)}
 
-    testIntegerObject(t, int, 0)
-}
+    evaluated := evalWithRuntime(`
+        import "time";
+        let start = time.now();
+        time.sleep(time.minute);
+        time.sleep(1.5);
+        [time.now(tz: "Europe/Paris"), time.since(start), time.monotonic()];
+    `, runtime)
 
-func TestReturnInWhileLoop(t *testing.T) {
-    input := `
-    x = 10;
-    while (x > 0) { 
-        x = x - 1;
-        return x;
+    expected := "[2024-05-17T11:01:01.5+02:00, 1m1.5s, 1m1.5s]"
+    if evaluated.Inspect() != expected {
+        t.Errorf("wrong result. expected=%q, got=%q", expected, evaluated.Inspect())
     }
-    `
-    evaluated := evalTest(input)
+}
 
-    int, ok := evaluated.(*object.Integer)
-    if !ok {
-        t.Fatalf("Expected=%s. Got=%s", object.INTEGER_OBJ, evaluated.Type())
+func TestRandomLibrary(t *testing.T) {
+    tests := []struct {
+        input string
+        expected string
+    } {
+        {"type(random.generator(1));", "RANDOM"},
+        {"let a = random.generator(5); let b = random.generator(5); map([1, 2, 3], func(x) { a.int(0, 1000) == b.int(0, 1000); });",
+            "[true, true, true]"},
+        {"let g = random.generator(3); all(map(\"x\".repeat(200).chars(), func(x) { let n = g.int(-2, 2); n >= -2 && n <= 2; }), func(x) { x; });", "true"},
+        {"let g = random.generator(3); unique(map(\"x\".repeat(200).chars(), func(x) { g.int(1, 3); })) |> sort;", "[1, 2, 3]"},
+        {"random.generator(3).int(7, 7);", "7"},
+        {"let g = random.generator(3); all(map(\"x\".repeat(100).chars(), func(x) { let f = g.float(); f >= 0 && f < 1; }), func(x) { x; });", "true"},
+        {"let g = random.generator(3); all(map(\"x\".repeat(100).chars(), func(x) { let f = g.float(2, 4); f >= 2 && f < 4; }), func(x) { x; });", "true"},
+        {`random.generator(3).choice(["only"]);`, "only"},
+        {"sort(random.generator(3).shuffle([3, 1, 2]));", "[1, 2, 3]"},
+        {"let xs = [1, 2, 3]; random.generator(3).shuffle(xs); xs;", "[1, 2, 3]"},
+        {"let s = random.generator(3).sample((1, 2, 3, 4, 5), 3); [len(s), len(unique(s))];", "[3, 3]"},
+        {"random.generator(3).sample([1, 2], 0);", "[]"},
+        {`random.generator(3).weighted(["a", "b", "c"], [0, 1.5, 0]);`, "b"},
+        {`let g = random.generator(3); unique(map("x".repeat(100).chars(), func(x) { g.weighted(["a", "b"], [1, 1]); })) |> sort;`, "[a, b]"},
+        {"random.generator(3).normal(5, 0);", "5.0"},
+        {"let g = random.generator(3); let xs = map(\"x\".repeat(2000).chars(), func(x) { g.normal(); }); math.abs(reduce(xs, func(a, b) { a + b; }) / 2000) < 0.1;", "true"},
+        {"random.seed(9); let a = random.int(0, 1000000); random.seed(9); a == random.int(0, 1000000);", "true"},
+        {"random.seed(9); let a = random.generator().float(); random.seed(9); a == random.generator().float();", "true"},
     }
 
-    testIntegerObject(t, int, 9)
+    for _, test := range tests {
+        evaluated := evalTest(`import "random"; import "math"; ` + test.input)
+        if evaluated.Inspect() != test.expected {
+            t.Errorf("wrong result for %q. expected=%q, got=%q", test.input, test.expected, evaluated.Inspect())
+        }
+    }
 }
 
-func TestWhileNoEval(t *testing.T) {
-    input := `
-    x = 10;
-    while (x > 120) {
-        x = x - 1;
-        return x;
+func TestRandomLibraryErrors(t *testing.T) {
+    tests := []struct {
+        input string
+        expected string
+    } {
+        {"random.int(5, 1);", "empty range for `int`: 5 > 1"},
+        {"random.int(1.5, 2);", "argument to `int` must be INTEGER, got FLOAT"},
+        {"random.float(1);", "wrong number of arguments to float: expected 0 or 2, got 1"},
+        {"random.choice([]);", "`choice` from an empty sequence"},
+        {`random.choice("abc");`, "argument to `choice` must be ARRAY or TUPLE, got STRING"},
+        {"random.sample([1, 2], 3);", "sample larger than the population or negative: 3 of 2"},
+        {"random.weighted([1, 2], [1]);", "`weighted` needs a weight for every element: 2 elements, 1 weights"},
+        {"random.weighted([1, 2], [1, -1]);", "weights of `weighted` must be finite and not negative, got -1"},
+        {"random.weighted([1], [0]);", "weights of `weighted` must not all be zero"},
+        {"random.normal(0, -1);", "standard deviation of `normal` must not be negative, got -1"},
+        {`random.generator("x");`, "argument to `generator` must be INTEGER, got STRING"},
+        {"random.int(1, 2, seed: 3);", "unexpected keyword argument seed in call to int"},
     }
-    `
-    evaluated := evalTest(input)
 
-    testNullObject(t, evaluated)
+    for _, test := range tests {
+        testErrorObject(t, evalTest(`import "random"; ` + test.input), test.expected)
+    }
 }
 
-func TestAssignmentStatement(t *testing.T) {
+// the global generator can be seeded by the embedder, like --seed does
+func TestRandomLibrarySeededRuntime(t *testing.T) {
+    results := []string{}
+    for i := 0; i < 2; i++ {
+        runtime := object.NewRuntime()
+        runtime.Random = object.NewRand(42)
+        evaluated := evalWithRuntime(`import "random"; [random.int(0, 1000000), random.float(), random.shuffle([1, 2, 3, 4, 5])];`, runtime)
+        results = append(results, evaluated.Inspect())
+    }
+    if results[0] != results[1] {
+        t.Errorf("runs with the same seed differ: %q and %q", results[0], results[1])
+    }
+}
+
+func TestFileBuiltins(t *testing.T) {
+    dir := writeModules(t, map[string]string{
+        "notes.txt": "first\r\nsecond\n",
+        "sub/inner.txt": "",
+    })
+
     tests := []struct {
         input string
-        expected int64
+        expected any
     } {
-        {"a = 5; a;", 5},
-        {"a = 5 * 5; a;", 25},
-        {"a = 5; b = a; b;", 5},
-        {"a = 5; b = a; c = a + b + 5; c;", 15},
+        {`read_file("${dir}/notes.txt").len();`, 14},
+        {`read_lines("${dir}/notes.txt");`, "[first, second]"},
+        {`read_lines("${dir}/sub/inner.txt");`, "[]"},
+        {`write_file("${dir}/out.txt", "a"); append_file("${dir}/out.txt", "b"); read_file("${dir}/out.txt");`, "ab"},
+        {`write_file("${dir}/out.txt", "c"); read_file("${dir}/out.txt");`, "c"},
+        {`list_dir(dir);`, "[notes.txt, out.txt, sub]"},
+        {`exists("${dir}/sub");`, true},
+        {`exists("${dir}/missing");`, false},
+        {`write_file("${dir}/gone.txt", ""); remove("${dir}/gone.txt"); exists("${dir}/gone.txt");`, false},
     }
 
     for _, test := range tests {
-        testIntegerObject(t, evalTest(test.input), test.expected)
+        runtime := object.NewRuntime()
+        evaluated := evalWithRuntime(`let dir = "` + dir + `"; ` + test.input, runtime)
+
+        switch expected := test.expected.(type) {
+        case int:
+            testIntegerObject(t, evaluated, int64(expected))
+        case bool:
+            testBooleanObject(t, evaluated, expected)
+        case string:
+            if evaluated.Inspect() != expected {
+                t.Errorf("wrong result for %q. expected=%q, got=%q", test.input, expected, evaluated.Inspect())
+            }
+        }
     }
 }
 
-func TestFunctionObject(t *testing.T) {
-    test := "func(x) { x + 2; };"
-    evaluated := evalTest(test)
+func TestFilePolicy(t *testing.T) {
+    dir := writeModules(t, map[string]string{
+        "public/data.txt": "data",
+        "public/shared.ch": `export const shared = "shared";`,
+        "private/secret.txt": "secret",
+        "private/priv.ch": `export const secret = "secret";`,
+    })
+    public := filepath.Join(dir, "public")
+    if err := os.Symlink(filepath.Join(dir, "private"), filepath.Join(public, "link")); err != nil {
+        t.Fatal(err)
+    }
 
-    funcObj, ok := evaluated.(*object.Function)
-    if !ok {
-        t.Errorf("object not Function. Got type=%T(%+v)", evaluated, evaluated)
-        return
+    tests := []struct {
+        policy object.FilePolicy
+        input string
+        expected string
+    } {
+        {object.FilePolicy{ReadRoots: []string{public}}, `read_file("${dir}/public/data.txt");`, "data"},
+        {object.FilePolicy{ReadRoots: []string{public}}, `read_file("${dir}/public/../private/secret.txt");`,
+            "permission denied: " + dir + "/public/../private/secret.txt is outside the readable directories"},
+        {object.FilePolicy{ReadRoots: []string{public}}, `read_file("${dir}/public/link/secret.txt");`,
+            "permission denied: " + dir + "/public/link/secret.txt is outside the readable directories"},
+        {object.FilePolicy{NoRead: true}, `exists(dir);`, "permission denied: reading files is disabled"},
+        {object.FilePolicy{NoWrite: true}, `write_file("${dir}/x.txt", "");`, "permission denied: writing files is disabled"},
+        {object.FilePolicy{NoWrite: true}, `remove("${dir}/public/data.txt");`, "permission denied: writing files is disabled"},
+        {object.FilePolicy{WriteRoots: []string{public}}, `write_file("${dir}/public/new.txt", "n"); read_file("${dir}/public/new.txt");`, "n"},
+        {object.FilePolicy{WriteRoots: []string{public}}, `append_file("${dir}/private/secret.txt", "!");`,
+            "permission denied: " + dir + "/private/secret.txt is outside the writable directories"},
     }
 
-    params := funcObj.Parameters
-    if len(params) != 1 {
-        t.Fatalf("Function has more than 1 parameter. Got=%d)", len(params))
+    for _, test := range tests {
+        runtime := object.NewRuntime()
+        runtime.Files = test.policy
+        evaluated := evalWithRuntime(`let dir = "` + dir + `"; ` + test.input, runtime)
+
+        result := evaluated.Inspect()
+        if err, ok := evaluated.(*object.Error); ok {
+            result = err.Message
+        }
+        if result != test.expected {
+            t.Errorf("wrong result for %q. expected=%q, got=%q", test.input, test.expected, result)
+        }
     }
-    if params[0].String() != "x" {
-        t.Fatalf("Function paramter not X. Got=%s)", params[0].String())
+
+    imports := []struct {
+        input string
+        expected string
+    } {
+        {`import { shared } from "./shared"; shared;`, "shared"},
+        {`import { secret } from "../private/priv"; secret;`,
+            "permission denied: " + dir + "/private/priv.ch is outside the readable directories"},
+        {`import { secret } from "./link/priv"; secret;`,
+            "permission denied: " + public + "/link/priv.ch is outside the readable directories"},
     }
 
-    expectedBody := "(x + 2)"
-    if funcObj.Body.String() != expectedBody {
-        t.Fatalf("body is %q. got %q", expectedBody, funcObj.Body.String())
+    for _, test := range imports {
+        runtime := object.NewRuntime()
+        runtime.Files = object.FilePolicy{ReadRoots: []string{public}}
+        program := parser.New(lexer.New(test.input)).ParseProgram()
+        evaluated := Eval(program, object.NewModuleEnvironment(runtime, public))
+
+        result := evaluated.Inspect()
+        if err, ok := evaluated.(*object.Error); ok {
+            result = err.Message
+        }
+        if result != test.expected {
+            t.Errorf("wrong result for %q. expected=%q, got=%q", test.input, test.expected, result)
+        }
     }
 }
 
-func TestFunctionStatement(t *testing.T) {
-    test := "func z(x) { x + 2; }"
-    evaluated := evalTest(test)
+func TestFileBuiltinErrors(t *testing.T) {
+    dir := t.TempDir()
 
-    funcObj, ok := evaluated.(*object.Function)
-    if !ok {
-        t.Errorf("object not Function. Got type=%T(%+v)", evaluated, evaluated)
-        return
+    tests := []struct {
+        input string
+        expected string
+    } {
+        {`read_file("${dir}/missing.txt");`, "cannot read " + dir + "/missing.txt: no such file or directory"},
+        {`list_dir("${dir}/missing");`, "cannot list " + dir + "/missing: no such file or directory"},
+        {`remove("${dir}/missing");`, "cannot remove " + dir + "/missing: no such file or directory"},
+        {`write_file("${dir}/x.txt", 1);`, "argument to `write_file` must be STRING, got INTEGER"},
+        {`read_file(1);`, "argument to `read_file` must be STRING, got INTEGER"},
+        {`read_file();`, "wrong number of arguments. got=0, want=1"},
     }
 
-    params := funcObj.Parameters
-    if len(params) != 1 {
-        t.Fatalf("Function has more than 1 parameter. Got=%d)", len(params))
-    }
-    if params[0].String() != "x" {
-        t.Fatalf("Function paramter not X. Got=%s)", params[0].String())
+    for _, test := range tests {
+        testErrorObject(t, evalTest(`let dir = "` + dir + `"; ` + test.input), test.expected)
     }
+}
 
-    expectedBody := "(x + 2)"
-    if funcObj.Body.String() != expectedBody {
-        t.Fatalf("body is %q. got %q", expectedBody, funcObj.Body.String())
-    }
+func TestInputAndOutput(t *testing.T) {
+    var out bytes.Buffer
+    runtime := object.NewRuntime()
+    runtime.In = strings.NewReader("Ada\r\nlast")
+    runtime.Out = &out
 
+    evaluated := evalWithRuntime(`let name = input("name? "); print("hi", name); [input(), input()];`, runtime)
+
+    if evaluated.Inspect() != "[last, null]" {
+        t.Errorf("wrong result. expected=%q, got=%q", "[last, null]", evaluated.Inspect())
+    }
+    if out.String() != "name? hi Ada\n" {
+        t.Errorf("wrong output. expected=%q, got=%q", "name? hi Ada\n", out.String())
+    }
 }
 
-func TestFunctionCall(t *testing.T) {
+func TestProcessBuiltins(t *testing.T) {
+    t.Setenv("CHARM_TEST_VAR", "set")
+
     tests := []struct {
         input string
-        expected int64
+        expected string
     } {
-        {"identity = func(x) { x; }; identity(5);", 5},
-        {"identity = func(x) { return x; }; identity(5);", 5},
-        {"double = func(x) { x * 2; }; double(5);", 10},
-        {"add = func(x, y) { x + y; }; add(5, 5);", 10},
-        {"add = func(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
-        {"func(x) { x; }(5);", 5},
+        {"args;", "[one, --two]"},
+        {"args.push(1); args;", "[one, --two]"},
+        {`env_get("CHARM_TEST_VAR");`, "set"},
+        {`env_get("CHARM_TEST_MISSING");`, "null"},
+        {`env_get("CHARM_TEST_MISSING", "default");`, "default"},
+        {`env_set("CHARM_TEST_VAR", "changed"); env_get("CHARM_TEST_VAR");`, "changed"},
+        {`env_set("CHARM_TEST_VAR", null); env_get("CHARM_TEST_VAR", "unset");`, "unset"},
+        {`env_get(1);`, "argument to `env_get` must be STRING, got INTEGER"},
+        {`env_set("CHARM_TEST_VAR", 1);`, "value of `env_set` must be STRING or NULL, got INTEGER"},
+        {`exit("1");`, "argument to `exit` must be INTEGER, got STRING"},
+        {`run("ls");`, "argument to `run` must be ARRAY or TUPLE, got STRING"},
+        {`run([]);`, "command of `run` is empty"},
+        {`run(["charm-test-missing-program"]);`,
+            `cannot run charm-test-missing-program: executable file not found in $PATH`},
     }
 
     for _, test := range tests {
-        evaluated := evalTest(test.input)
-        testIntegerObject(t, evaluated, test.expected)
+        runtime := object.NewRuntime()
+        runtime.Args = []string{"one", "--two"}
+        evaluated := evalWithRuntime(test.input, runtime)
+
+        result := evaluated.Inspect()
+        if err, ok := evaluated.(*object.Error); ok {
+            result = err.Message
+        }
+        if result != test.expected {
+            t.Errorf("wrong result for %q. expected=%q, got=%q", test.input, test.expected, result)
+        }
     }
 }
 
-func TestClosures(t *testing.T) {
-    input := `
-    newAdder = func(x) {
-        func(y) { x + y; };
-    };
-    addTwo = newAdder(2);
-    addTwo(2);`
+func TestExit(t *testing.T) {
+    var out bytes.Buffer
+    runtime := object.NewRuntime()
+    runtime.Out = &out
+
+    evaluated := evalWithRuntime(`
+        let stop = func() { map([1, 2], func(x) { exit(3); }); };
+        print("before");
+        stop();
+        print("after");
+    `, runtime)
+
+    err, ok := evaluated.(*object.Error)
+    if !ok || !err.Exit || err.Code != 3 {
+        t.Fatalf("expected exit with status 3, got=%#v", evaluated)
+    }
+    if out.String() != "before\n" {
+        t.Errorf("wrong output. expected=%q, got=%q", "before\n", out.String())
+    }
 
-    testIntegerObject(t, evalTest(input), 4)
+    err, ok = evalTest("exit();").(*object.Error)
+    if !ok || !err.Exit || err.Code != 0 {
+        t.Errorf("expected exit with status 0, got=%#v", err)
+    }
+
+    stops := []struct {
+        input string
+        code int
+    } {
+        {"let i = 0; while (i < 2) { exit(7); i = i + 1; }", 7},
+        {"let a = [1, 2][exit(6)];", 6},
+        {`let m = {"a": 1}; m[exit(5)];`, 5},
+    }
+    for _, test := range stops {
+        err, ok := evalTest(test.input).(*object.Error)
+        if !ok || !err.Exit || err.Code != test.code {
+            t.Errorf("%q: expected exit with status %d, got=%#v", test.input, test.code, err)
+        }
+    }
 }
 
-func TestArrayLiterals(t *testing.T) {
-    input := "[1, 2 * 2, 3 + 3];"
-    evaluated := evalTest(input)
-    result, ok := evaluated.(*object.Array)
-    if !ok {
-        t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+func TestRunBuiltin(t *testing.T) {
+    if _, err := exec.LookPath("sh"); err != nil {
+        t.Skip("no sh to run")
     }
-    if len(result.Elements) != 3 {
-        t.Fatalf("array has wrong num of elements. got=%d",
-            len(result.Elements))
+    dir := t.TempDir()
+
+    tests := []struct {
+        input string
+        expected string
+    } {
+        {`run(["sh", "-c", "echo out; echo err >&2; exit 3"]);`, "{status: 3, stdout: out\n, stderr: err\n}"},
+        {`run(["sh", "-c", "cat"], input: "piped")["stdout"];`, "piped"},
+        {`run(("sh", "-c", "pwd"), dir: dir)["stdout"].trim();`, dir},
+        {`env_set("CHARM_TEST_RUN", "inherited"); run(["sh", "-c", "echo $CHARM_TEST_RUN"])["stdout"].trim();`, "inherited"},
+        {`run(["sh", "-c", "exit 0"], dir: dir + "/missing");`, "cannot run sh: no such file or directory"},
+    }
+
+    for _, test := range tests {
+        evaluated := evalTest(`let dir = "` + dir + `"; ` + test.input)
+
+        result := evaluated.Inspect()
+        if err, ok := evaluated.(*object.Error); ok {
+            result = err.Message
+        }
+        if result != test.expected {
+            t.Errorf("wrong result for %q. expected=%q, got=%q", test.input, test.expected, result)
+        }
     }
-    testIntegerObject(t, result.Elements[0], 1)
-    testIntegerObject(t, result.Elements[1], 4)
-    testIntegerObject(t, result.Elements[2], 6)
 }
 
-func TestArrayIndexExpressions(t *testing.T) {
+func TestProcessPolicy(t *testing.T) {
     tests := []struct {
+        policy object.ProcessPolicy
         input string
-        expected interface{}
-    }{
-        {
-            "[1, 2, 3][0];", 1,
-        },
-        {
-            "[1, 2, 3][1];", 2,
-        },
-        {
-            "[1, 2, 3][2];", 3,
-        },
-        {
-            "i = 0; [1][i];", 1,
-        },
-        {
-            "[1, 2, 3][1 + 1];", 3,
-        },
-        {
-            "myArray = [1, 2, 3]; myArray[2];", 3,
-        },
-        {
-            "myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];", 6,
-        },
-        {
-            "myArray = [1, 2, 3]; i = myArray[0]; myArray[i];", 2,
-        },
-        {
-            "[1, 2, 3][3];", nil,
-        },
-        {
-            "[1, 2, 3][-1];", nil,
-        },
+        expected string
+    } {
+        {object.ProcessPolicy{NoArgs: true}, "args;", "permission denied: command-line arguments are disabled"},
+        {object.ProcessPolicy{NoEnv: true}, `env_get("HOME");`, "permission denied: environment variables are disabled"},
+        {object.ProcessPolicy{NoEnv: true}, `env_set("HOME", "");`, "permission denied: environment variables are disabled"},
+        {object.ProcessPolicy{NoExit: true}, "exit(1);", "permission denied: exit is disabled"},
+        {object.ProcessPolicy{NoRun: true}, `run(["true"]);`, "permission denied: running subprocesses is disabled"},
+        {object.ProcessPolicy{NoRun: true}, "args;", "[]"},
     }
-    for _, tt := range tests {
-        evaluated := evalTest(tt.input)
-        integer, ok := tt.expected.(int)
-        if ok {
-            testIntegerObject(t, evaluated, int64(integer))
-        } else {
-            testNullObject(t, evaluated)
+
+    for _, test := range tests {
+        runtime := object.NewRuntime()
+        runtime.Process = test.policy
+        evaluated := evalWithRuntime(test.input, runtime)
+
+        result := evaluated.Inspect()
+        if err, ok := evaluated.(*object.Error); ok {
+            if err.Exit {
+                t.Errorf("%q exited although exit is disabled", test.input)
+            }
+            result = err.Message
+        }
+        if result != test.expected {
+            t.Errorf("wrong result for %q. expected=%q, got=%q", test.input, test.expected, result)
         }
     }
 }
@@ -447,6 +1981,17 @@ func TestHashableObjects(t *testing.T) {
         {&object.Integer{Value: 1234}, &object.Integer{Value: 43321}, false},
         {&object.Boolean{Value: true}, &object.Boolean{Value: false}, false},
         {&object.Boolean{Value: true}, &object.Boolean{Value: true}, true},
+        {&object.Boolean{Value: true}, &object.Integer{Value: 1}, false},
+        {
+            &object.Tuple{Elements: []object.Object{&object.Integer{Value: 1}, &object.String{Value: "a"}}},
+            &object.Tuple{Elements: []object.Object{&object.Integer{Value: 1}, &object.String{Value: "a"}}},
+            true,
+        },
+        {
+            &object.Tuple{Elements: []object.Object{&object.Integer{Value: 1}, &object.Integer{Value: 2}}},
+            &object.Tuple{Elements: []object.Object{&object.Integer{Value: 2}, &object.Integer{Value: 1}}},
+            false,
+        },
     }
 
     for _, test := range tests {
@@ -466,7 +2011,7 @@ func TestHashableObjects(t *testing.T) {
 }
 
 func TestHashMapLiteral(t *testing.T) {
-    input := `two = "two";
+    input := `let two = "two";
     {
     "one": 10 - 9,
     two: 1 + 1,
@@ -552,7 +2097,7 @@ func TestHashIndexExpressions(t *testing.T) {
             nil,
         },
         {
-            `key = "foo"; {"foo": 5}[key];`,
+            `let key = "foo"; {"foo": 5}[key];`,
             5,
         },
         {
@@ -586,10 +2131,14 @@ func TestHashIndexExpressions(t *testing.T) {
 
 // helpers
 func evalTest(input string) object.Object {
+    return evalWithRuntime(input, object.NewRuntime())
+}
+
+func evalWithRuntime(input string, runtime *object.Runtime) object.Object {
     lexer := lexer.New(input)
     parser := parser.New(lexer)
     program := parser.ParseProgram()
-    environment := object.NewEnvironment()
+    environment := object.NewEnvironmentWithRuntime(runtime)
 
     return Eval(program, environment)
 }
@@ -656,6 +2205,21 @@ func testBooleanObject(t *testing.T, evaluated object.Object, expected bool) boo
     return true
 }
 
+func testErrorObject(t *testing.T, evaluated object.Object, expected string) bool {
+    errObj, ok := evaluated.(*object.Error)
+    if !ok {
+        t.Errorf("object is not Error. Got=%T (%+v)", evaluated, evaluated)
+        return false
+    }
+
+    if errObj.Message != expected {
+        t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+        return false
+    }
+
+    return true
+}
+
 func testNullObject(t *testing.T, evaluated object.Object) bool {
     if evaluated != NULL {
         t.Errorf("object is not NULL. got=%T (%+v)", evaluated, evaluated)
@@ -0,0 +1,187 @@
+package evaluator
+
+import (
+	"charm/object"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+)
+
+// The file builtins go through the runtime's FilePolicy, which is how an
+// embedder confines untrusted scripts
+var fileBuiltins = map[string]*object.Builtin{
+    "input": {ContextFn: builtinInput},
+    "read_file": {ContextFn: builtinReadFile},
+    "read_lines": {ContextFn: builtinReadLines},
+    "write_file": {ContextFn: builtinWriteFile},
+    "append_file": {ContextFn: builtinAppendFile},
+    "list_dir": {ContextFn: builtinListDir},
+    "exists": {ContextFn: builtinExists},
+    "remove": {ContextFn: builtinRemove},
+}
+
+// fileError reports a failed file operation without repeating the path that
+// the error of the os package already contains
+func fileError(action string, path string, err error) *object.Error {
+    var pathErr *fs.PathError
+    if errors.As(err, &pathErr) {
+        err = pathErr.Err
+    }
+    return newError("cannot %s %s: %s", action, path, err)
+}
+
+// pathArgument checks the arguments of a builtin taking a path and count - 1
+// further arguments, and that the policy allows the access
+func pathArgument(name string, ctx *object.CallContext, args []object.Object, count int, write bool) (string, *object.Error) {
+    if err := checkCall(name, ctx, args, count, count); err != nil {
+        return "", err
+    }
+    path, ok := args[0].(*object.String)
+    if !ok {
+        return "", newError("argument to `%s` must be STRING, got %s", name, args[0].Type())
+    }
+
+    check := ctx.Runtime.Files.CheckRead
+    if write {
+        check = ctx.Runtime.Files.CheckWrite
+    }
+    if err := check(path.Value); err != nil {
+        return "", newError("%s", err)
+    }
+    return path.Value, nil
+}
+
+// input reads a line of standard input after writing the optional prompt. It
+// returns null at the end of input.
+func builtinInput(ctx *object.CallContext, args ...object.Object) object.Object {
+    if err := checkCall("input", ctx, args, 0, 1); err != nil {
+        return err
+    }
+    if len(args) == 1 {
+        io.WriteString(ctx.Runtime.Out, toString(args[0]))
+    }
+
+    line, err := ctx.Runtime.ReadLine()
+    if err == io.EOF {
+        return NULL
+    }
+    if err != nil {
+        return newError("cannot read input: %s", err)
+    }
+    return &object.String{Value: line}
+}
+
+func builtinReadFile(ctx *object.CallContext, args ...object.Object) object.Object {
+    path, err := pathArgument("read_file", ctx, args, 1, false)
+    if err != nil {
+        return err
+    }
+    content, readErr := os.ReadFile(path)
+    if readErr != nil {
+        return fileError("read", path, readErr)
+    }
+    return &object.String{Value: string(content)}
+}
+
+// read_lines returns the lines of a file without their line endings
+func builtinReadLines(ctx *object.CallContext, args ...object.Object) object.Object {
+    path, err := pathArgument("read_lines", ctx, args, 1, false)
+    if err != nil {
+        return err
+    }
+    content, readErr := os.ReadFile(path)
+    if readErr != nil {
+        return fileError("read", path, readErr)
+    }
+
+    lines := []object.Object{}
+    text := strings.TrimSuffix(string(content), "\n")
+    if text == "" {
+        return &object.Array{Elements: lines}
+    }
+    for _, line := range strings.Split(text, "\n") {
+        lines = append(lines, &object.String{Value: strings.TrimSuffix(line, "\r")})
+    }
+    return &object.Array{Elements: lines}
+}
+
+func writeFile(name string, ctx *object.CallContext, args []object.Object, flag int) object.Object {
+    path, err := pathArgument(name, ctx, args, 2, true)
+    if err != nil {
+        return err
+    }
+    content, ok := args[1].(*object.String)
+    if !ok {
+        return newError("argument to `%s` must be STRING, got %s", name, args[1].Type())
+    }
+
+    file, openErr := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|flag, 0o644)
+    if openErr != nil {
+        return fileError("write", path, openErr)
+    }
+    defer file.Close()
+
+    if _, writeErr := file.WriteString(content.Value); writeErr != nil {
+        return fileError("write", path, writeErr)
+    }
+    return NULL
+}
+
+func builtinWriteFile(ctx *object.CallContext, args ...object.Object) object.Object {
+    return writeFile("write_file", ctx, args, os.O_TRUNC)
+}
+
+func builtinAppendFile(ctx *object.CallContext, args ...object.Object) object.Object {
+    return writeFile("append_file", ctx, args, os.O_APPEND)
+}
+
+// list_dir returns the sorted names in a directory, the current one by default
+func builtinListDir(ctx *object.CallContext, args ...object.Object) object.Object {
+    if len(args) == 0 {
+        args = []object.Object{&object.String{Value: "."}}
+    }
+    path, err := pathArgument("list_dir", ctx, args, 1, false)
+    if err != nil {
+        return err
+    }
+
+    entries, readErr := os.ReadDir(path)
+    if readErr != nil {
+        return fileError("list", path, readErr)
+    }
+    names := []string{}
+    for _, entry := range entries {
+        names = append(names, entry.Name())
+    }
+    sort.Strings(names)
+
+    elements := []object.Object{}
+    for _, name := range names {
+        elements = append(elements, &object.String{Value: name})
+    }
+    return &object.Array{Elements: elements}
+}
+
+func builtinExists(ctx *object.CallContext, args ...object.Object) object.Object {
+    path, err := pathArgument("exists", ctx, args, 1, false)
+    if err != nil {
+        return err
+    }
+    _, statErr := os.Stat(path)
+    return nativeBooltoBoolObject(statErr == nil)
+}
+
+// remove deletes a file or an empty directory
+func builtinRemove(ctx *object.CallContext, args ...object.Object) object.Object {
+    path, err := pathArgument("remove", ctx, args, 1, true)
+    if err != nil {
+        return err
+    }
+    if removeErr := os.Remove(path); removeErr != nil {
+        return fileError("remove", path, removeErr)
+    }
+    return NULL
+}
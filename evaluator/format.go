@@ -0,0 +1,176 @@
+package evaluator
+
+import (
+	"charm/object"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var formatBuiltins = map[string]*object.Builtin{
+    "format": {Fn: builtinFormat},
+    "printf": {ContextFn: builtinPrintf},
+}
+
+// stringKeyword reads an optional STRING keyword argument
+func stringKeyword(name string, ctx *object.CallContext, keyword string, fallback string) (string, *object.Error) {
+    value, ok := ctx.Keywords[keyword]
+    if !ok {
+        return fallback, nil
+    }
+    str, ok := value.(*object.String)
+    if !ok {
+        return "", newError("%s of `%s` must be STRING, got %s", keyword, name, value.Type())
+    }
+    return str.Value, nil
+}
+
+// formatVerb is a directive like "%-8.2f" of a format string
+type formatVerb struct {
+    // spec is the directive without its verb, e.g. "%-8.2"
+    spec string
+    verb rune
+}
+
+// parseFormat splits a format string into literal text and verbs. parts
+// alternates between the two, starting and ending with text.
+func parseFormat(name string, format string) ([]string, []formatVerb, *object.Error) {
+    parts := []string{}
+    verbs := []formatVerb{}
+    var text strings.Builder
+
+    runes := []rune(format)
+    for i := 0; i < len(runes); i++ {
+        if runes[i] != '%' {
+            text.WriteRune(runes[i])
+            continue
+        }
+
+        start := i
+        i++
+        if i < len(runes) && runes[i] == '%' {
+            text.WriteRune('%')
+            continue
+        }
+        for i < len(runes) && strings.ContainsRune("-+# 0", runes[i]) {
+            i++
+        }
+        for i < len(runes) && runes[i] >= '0' && runes[i] <= '9' {
+            i++
+        }
+        if i < len(runes) && runes[i] == '.' {
+            i++
+            for i < len(runes) && runes[i] >= '0' && runes[i] <= '9' {
+                i++
+            }
+        }
+        if i == len(runes) {
+            return nil, nil, newError("incomplete verb %s at the end of the format string of `%s`", string(runes[start:]), name)
+        }
+        if !strings.ContainsRune("vsqdbocxXfFeEgGt", runes[i]) {
+            return nil, nil, newError("unknown verb %s in the format string of `%s`", string(runes[start:i+1]), name)
+        }
+
+        parts = append(parts, text.String())
+        text.Reset()
+        verbs = append(verbs, formatVerb{spec: string(runes[start:i]), verb: runes[i]})
+    }
+
+    parts = append(parts, text.String())
+    return parts, verbs, nil
+}
+
+// formatValue converts arg to the Go value that verb formats the way
+// printf in C or Go would
+func formatValue(name string, verb formatVerb, arg object.Object) (any, *object.Error) {
+    directive := verb.spec + string(verb.verb)
+    switch verb.verb {
+    case 'v', 's':
+        return toString(arg), nil
+    case 'q':
+        if str, ok := arg.(*object.String); ok {
+            return str.Value, nil
+        }
+        return toString(arg), nil
+    case 'd', 'b', 'o', 'c':
+        if integer, ok := arg.(*object.Integer); ok {
+            if verb.verb == 'c' {
+                return rune(integer.Value), nil
+            }
+            return integer.Value, nil
+        }
+        return nil, newError("%s in `%s` requires INTEGER, got %s", directive, name, arg.Type())
+    case 'x', 'X':
+        switch arg := arg.(type) {
+        case *object.Integer:
+            return arg.Value, nil
+        case *object.String:
+            return arg.Value, nil
+        }
+        return nil, newError("%s in `%s` requires INTEGER or STRING, got %s", directive, name, arg.Type())
+    case 't':
+        if boolean, ok := arg.(*object.Boolean); ok {
+            return boolean.Value, nil
+        }
+        return nil, newError("%s in `%s` requires BOOLEAN, got %s", directive, name, arg.Type())
+    default:
+        if isNumber(arg) {
+            return toFloat(arg).(*object.Float).Value, nil
+        }
+        return nil, newError("%s in `%s` requires INTEGER or FLOAT, got %s", directive, name, arg.Type())
+    }
+}
+
+// formatString applies the printf-style format to args. Widths and
+// precisions of %s count characters, and %v and %s show values like print.
+func formatString(name string, args []object.Object) (string, *object.Error) {
+    if len(args) == 0 {
+        return "", newError("wrong number of arguments. got=0, want at least 1")
+    }
+    format, ok := args[0].(*object.String)
+    if !ok {
+        return "", newError("format string of `%s` must be STRING, got %s", name, args[0].Type())
+    }
+    args = args[1:]
+
+    parts, verbs, err := parseFormat(name, format.Value)
+    if err != nil {
+        return "", err
+    }
+    if len(args) != len(verbs) {
+        return "", newError("wrong number of arguments to `%s`: the format string uses %d, got %d", name, len(verbs), len(args))
+    }
+
+    var out strings.Builder
+    for i, verb := range verbs {
+        out.WriteString(parts[i])
+        value, err := formatValue(name, verb, args[i])
+        if err != nil {
+            return "", err
+        }
+        fmt.Fprintf(&out, verb.spec + string(verb.verb), value)
+    }
+    out.WriteString(parts[len(parts) - 1])
+    return out.String(), nil
+}
+
+func builtinFormat(args ...object.Object) object.Object {
+    text, err := formatString("format", args)
+    if err != nil {
+        return err
+    }
+    return &object.String{Value: text}
+}
+
+// printf writes formatted text without adding a newline
+func builtinPrintf(ctx *object.CallContext, args ...object.Object) object.Object {
+    if err := checkCall("printf", ctx, args, 0, len(args)); err != nil {
+        return err
+    }
+    text, err := formatString("printf", args)
+    if err != nil {
+        return err
+    }
+    io.WriteString(ctx.Runtime.Out, text)
+    return NULL
+}
@@ -0,0 +1,288 @@
+package evaluator
+
+import (
+	"bytes"
+	"charm/object"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var jsonBuiltins = map[string]*object.Builtin{
+    "json_parse": {ContextFn: builtinJsonParse},
+    "json_stringify": {ContextFn: builtinJsonStringify},
+}
+
+// json_parse decodes JSON text. Objects become hashmaps keeping the order of
+// their keys, and numbers written with a fraction or exponent become floats
+// while all others become integers.
+func builtinJsonParse(ctx *object.CallContext, args ...object.Object) object.Object {
+    if err := checkCall("json_parse", ctx, args, 1, 1); err != nil {
+        return err
+    }
+    text, ok := args[0].(*object.String)
+    if !ok {
+        return newError("argument to `json_parse` must be STRING, got %s", args[0].Type())
+    }
+
+    decoder := json.NewDecoder(strings.NewReader(text.Value))
+    decoder.UseNumber()
+
+    value, err := decodeJson(decoder)
+    if err != nil {
+        return jsonError(decoder, err)
+    }
+    if _, err := decoder.Token(); err != io.EOF {
+        return newError("invalid JSON at offset %d: unexpected data after value", decoder.InputOffset())
+    }
+    return value
+}
+
+func jsonError(decoder *json.Decoder, err error) *object.Error {
+    var syntaxErr *json.SyntaxError
+    isSyntaxErr := errors.As(err, &syntaxErr)
+    if err == io.EOF || err == io.ErrUnexpectedEOF || isSyntaxErr && syntaxErr.Error() == "unexpected end of JSON input" {
+        return newError("invalid JSON: unexpected end of input")
+    }
+    if isSyntaxErr {
+        return newError("invalid JSON at offset %d: %s", syntaxErr.Offset, syntaxErr)
+    }
+    return newError("invalid JSON at offset %d: %s", decoder.InputOffset(), err)
+}
+
+func decodeJson(decoder *json.Decoder) (object.Object, error) {
+    token, err := decoder.Token()
+    if err != nil {
+        return nil, err
+    }
+
+    switch token := token.(type) {
+    case json.Delim:
+        if token == '[' {
+            array := &object.Array{Elements: []object.Object{}}
+            for decoder.More() {
+                element, err := decodeJson(decoder)
+                if err != nil {
+                    return nil, err
+                }
+                array.Elements = append(array.Elements, element)
+            }
+            _, err := decoder.Token()
+            return array, err
+        }
+
+        hashMap := object.NewHashMap()
+        for decoder.More() {
+            key, err := decoder.Token()
+            if err != nil {
+                return nil, err
+            }
+            value, err := decodeJson(decoder)
+            if err != nil {
+                return nil, err
+            }
+            hashMap.Put(&object.String{Value: key.(string)}, value)
+        }
+        _, err := decoder.Token()
+        return hashMap, err
+    case json.Number:
+        return decodeJsonNumber(token)
+    case string:
+        return &object.String{Value: token}, nil
+    case bool:
+        return nativeBooltoBoolObject(token), nil
+    default:
+        return NULL, nil
+    }
+}
+
+func decodeJsonNumber(number json.Number) (object.Object, error) {
+    literal := number.String()
+    if !strings.ContainsAny(literal, ".eE") {
+        value, err := strconv.ParseInt(literal, 10, 64)
+        if err != nil {
+            return nil, errors.New("integer out of range: " + literal)
+        }
+        return &object.Integer{Value: value}, nil
+    }
+
+    value, err := strconv.ParseFloat(literal, 64)
+    if err != nil {
+        return nil, errors.New("number out of range: " + literal)
+    }
+    return &object.Float{Value: value}, nil
+}
+
+// maxJsonIndent bounds a numeric indent, which would otherwise let a huge
+// number exhaust memory before anything is encoded
+const maxJsonIndent = 100
+
+// json_stringify encodes a value as JSON. The keyword argument indent, a
+// number of spaces or a STRING, spreads the output over indented lines, and
+// sort_keys orders the keys of hashmaps instead of keeping insertion order.
+func builtinJsonStringify(ctx *object.CallContext, args ...object.Object) object.Object {
+    if err := checkCall("json_stringify", ctx, args, 1, 1, "indent", "sort_keys"); err != nil {
+        return err
+    }
+
+    encoder := &jsonEncoder{visiting: map[object.Object]bool{}}
+    if indent, ok := ctx.Keywords["indent"]; ok {
+        switch indent := indent.(type) {
+        case *object.Integer:
+            if indent.Value > maxJsonIndent {
+                return newError("indent of `json_stringify` must be at most %d, got %d", maxJsonIndent, indent.Value)
+            }
+            encoder.indent = strings.Repeat(" ", int(max(indent.Value, 0)))
+        case *object.String:
+            encoder.indent = indent.Value
+        default:
+            return newError("indent of `json_stringify` must be INTEGER or STRING, got %s", indent.Type())
+        }
+    }
+    if sortKeys, ok := ctx.Keywords["sort_keys"]; ok {
+        encoder.sortKeys = isTruthy(sortKeys)
+    }
+
+    if err := encoder.encode(args[0], 0); err != nil {
+        return err
+    }
+    return &object.String{Value: encoder.out.String()}
+}
+
+type jsonEncoder struct {
+    out bytes.Buffer
+    indent string
+    sortKeys bool
+    // visiting holds the arrays and hashmaps being encoded, to detect cycles
+    visiting map[object.Object]bool
+}
+
+func (e *jsonEncoder) newline(depth int) {
+    if e.indent != "" {
+        e.out.WriteString("\n" + strings.Repeat(e.indent, depth))
+    }
+}
+
+func (e *jsonEncoder) encode(obj object.Object, depth int) *object.Error {
+    switch obj := obj.(type) {
+    case *object.Null:
+        e.out.WriteString("null")
+    case *object.Boolean:
+        e.out.WriteString(strconv.FormatBool(obj.Value))
+    case *object.Integer:
+        e.out.WriteString(strconv.FormatInt(obj.Value, 10))
+    case *object.Float:
+        if math.IsNaN(obj.Value) || math.IsInf(obj.Value, 0) {
+            return newError("cannot encode %s as JSON", obj.Inspect())
+        }
+        // keep a fraction so that the number is read back as a float
+        literal := strconv.FormatFloat(obj.Value, 'g', -1, 64)
+        if !strings.ContainsAny(literal, ".eE") {
+            literal += ".0"
+        }
+        e.out.WriteString(literal)
+    case *object.String:
+        e.encodeString(obj.Value)
+    case *object.Array:
+        return e.encodeSequence(obj, obj.Elements, depth)
+    case *object.Tuple:
+        return e.encodeSequence(obj, obj.Elements, depth)
+    case *object.HashMap:
+        return e.encodeHashMap(obj, depth)
+    default:
+        return newError("cannot encode %s as JSON", obj.Type())
+    }
+    return nil
+}
+
+func (e *jsonEncoder) encodeString(value string) {
+    var quoted bytes.Buffer
+    encoder := json.NewEncoder(&quoted)
+    encoder.SetEscapeHTML(false)
+    encoder.Encode(value)
+    e.out.Write(bytes.TrimSuffix(quoted.Bytes(), []byte("\n")))
+}
+
+func (e *jsonEncoder) enter(container object.Object) *object.Error {
+    if e.visiting[container] {
+        return newError("cannot encode cyclic %s as JSON", container.Type())
+    }
+    e.visiting[container] = true
+    return nil
+}
+
+func (e *jsonEncoder) encodeSequence(container object.Object, elements []object.Object, depth int) *object.Error {
+    if err := e.enter(container); err != nil {
+        return err
+    }
+    defer delete(e.visiting, container)
+
+    if len(elements) == 0 {
+        e.out.WriteString("[]")
+        return nil
+    }
+
+    e.out.WriteString("[")
+    for i, element := range elements {
+        if i > 0 {
+            e.out.WriteString(",")
+        }
+        e.newline(depth + 1)
+        if err := e.encode(element, depth + 1); err != nil {
+            return err
+        }
+    }
+    e.newline(depth)
+    e.out.WriteString("]")
+    return nil
+}
+
+func (e *jsonEncoder) encodeHashMap(hashMap *object.HashMap, depth int) *object.Error {
+    if err := e.enter(hashMap); err != nil {
+        return err
+    }
+    defer delete(e.visiting, hashMap)
+
+    pairs := hashMap.Pairs()
+    for _, pair := range pairs {
+        if pair.Key.Type() != object.STRING_OBJ {
+            return newError("cannot encode hashmap key %s as JSON: keys must be STRING, got %s",
+                pair.Key.Inspect(), pair.Key.Type())
+        }
+    }
+    if e.sortKeys {
+        sort.SliceStable(pairs, func(i, j int) bool {
+            return pairs[i].Key.(*object.String).Value < pairs[j].Key.(*object.String).Value
+        })
+    }
+
+    if len(pairs) == 0 {
+        e.out.WriteString("{}")
+        return nil
+    }
+
+    separator := ":"
+    if e.indent != "" {
+        separator = ": "
+    }
+
+    e.out.WriteString("{")
+    for i, pair := range pairs {
+        if i > 0 {
+            e.out.WriteString(",")
+        }
+        e.newline(depth + 1)
+        e.encodeString(pair.Key.(*object.String).Value)
+        e.out.WriteString(separator)
+        if err := e.encode(pair.Value, depth + 1); err != nil {
+            return err
+        }
+    }
+    e.newline(depth)
+    e.out.WriteString("}")
+    return nil
+}
@@ -0,0 +1,488 @@
+package evaluator
+
+import (
+	"charm/object"
+	"math"
+)
+
+// mathFunction is a float function of one argument. domain reports whether
+// an argument is valid; outside of it the call is a math domain error.
+type mathFunction struct {
+    fn func(float64) float64
+    domain func(float64) bool
+}
+
+func nonNegative(x float64) bool { return x >= 0 }
+func positive(x float64) bool { return x > 0 }
+func unitInterval(x float64) bool { return x >= -1 && x <= 1 }
+
+var mathFunctions = map[string]mathFunction{
+    "sqrt": {math.Sqrt, nonNegative},
+    "cbrt": {math.Cbrt, nil},
+    "exp": {math.Exp, nil},
+    "exp2": {math.Exp2, nil},
+    "log2": {math.Log2, positive},
+    "log10": {math.Log10, positive},
+    "log1p": {math.Log1p, func(x float64) bool { return x > -1 }},
+    "sin": {math.Sin, nil},
+    "cos": {math.Cos, nil},
+    "tan": {math.Tan, nil},
+    "asin": {math.Asin, unitInterval},
+    "acos": {math.Acos, unitInterval},
+    "atan": {math.Atan, nil},
+    "sinh": {math.Sinh, nil},
+    "cosh": {math.Cosh, nil},
+    "tanh": {math.Tanh, nil},
+    "asinh": {math.Asinh, nil},
+    "acosh": {math.Acosh, func(x float64) bool { return x >= 1 }},
+    "atanh": {math.Atanh, func(x float64) bool { return x > -1 && x < 1 }},
+}
+
+// roundingModes round a float to a whole number. half_up rounds ties away
+// from zero and half_even rounds them to the even neighbour.
+var roundingModes = map[string]func(float64) float64{
+    "half_up": math.Round,
+    "half_even": math.RoundToEven,
+    "half_down": func(x float64) float64 {
+        if math.Abs(x - math.Trunc(x)) == 0.5 {
+            return math.Trunc(x)
+        }
+        return math.Round(x)
+    },
+    "ceil": math.Ceil,
+    "floor": math.Floor,
+    "trunc": math.Trunc,
+}
+
+// mathModule follows the promotion rules of the arithmetic operators: results
+// stay INTEGER when every argument is an INTEGER and the result is exact, and
+// are FLOAT otherwise
+func mathModule(runtime *object.Runtime) map[string]object.Object {
+    members := map[string]object.Object{
+        "pi": &object.Float{Value: math.Pi},
+        "e": &object.Float{Value: math.E},
+        "tau": &object.Float{Value: 2 * math.Pi},
+        "inf": &object.Float{Value: math.Inf(1)},
+        "nan": &object.Float{Value: math.NaN()},
+        "max_int": &object.Integer{Value: math.MaxInt64},
+        "min_int": &object.Integer{Value: math.MinInt64},
+
+        "abs": &object.Builtin{Fn: mathAbs},
+        "floor": roundingFunction("floor", math.Floor),
+        "ceil": roundingFunction("ceil", math.Ceil),
+        "trunc": roundingFunction("trunc", math.Trunc),
+        "round": &object.Builtin{Fn: mathRound},
+        "pow": &object.Builtin{Fn: mathPow},
+        "log": &object.Builtin{Fn: mathLog},
+        "atan2": binaryMathFunction("atan2", math.Atan2),
+        "hypot": binaryMathFunction("hypot", math.Hypot),
+        "min": extremumFunction("min", -1),
+        "max": extremumFunction("max", 1),
+        "clamp": &object.Builtin{Fn: mathClamp},
+        "gcd": &object.Builtin{Fn: mathGcd},
+        "lcm": &object.Builtin{Fn: mathLcm},
+        "is_nan": floatPredicate("is_nan", math.IsNaN),
+        "is_inf": floatPredicate("is_inf", func(x float64) bool { return math.IsInf(x, 0) }),
+    }
+
+    for name, function := range mathFunctions {
+        members[name] = unaryMathFunction(name, function)
+    }
+
+    return members
+}
+
+func numberArgument(name string, arg object.Object) (float64, *object.Error) {
+    if !isNumber(arg) {
+        return 0, newError("argument to `%s` must be INTEGER or FLOAT, got %s", name, arg.Type())
+    }
+    return toFloat(arg).(*object.Float).Value, nil
+}
+
+func integerArgument(name string, arg object.Object) (int64, *object.Error) {
+    integer, ok := arg.(*object.Integer)
+    if !ok {
+        return 0, newError("argument to `%s` must be INTEGER, got %s", name, arg.Type())
+    }
+    return integer.Value, nil
+}
+
+func domainError(name string, args ...object.Object) *object.Error {
+    inspected := ""
+    for i, arg := range args {
+        if i > 0 {
+            inspected += ", "
+        }
+        inspected += arg.Inspect()
+    }
+    return newError("math domain error: %s(%s)", name, inspected)
+}
+
+func unaryMathFunction(name string, function mathFunction) *object.Builtin {
+    return &object.Builtin{
+        Fn: func(args ...object.Object) object.Object {
+            if len(args) != 1 {
+                return newError("wrong number of arguments. got=%d, want=1", len(args))
+            }
+            x, err := numberArgument(name, args[0])
+            if err != nil {
+                return err
+            }
+            if function.domain != nil && !math.IsNaN(x) && !function.domain(x) {
+                return domainError(name, args[0])
+            }
+            return &object.Float{Value: function.fn(x)}
+        },
+    }
+}
+
+func binaryMathFunction(name string, fn func(float64, float64) float64) *object.Builtin {
+    return &object.Builtin{
+        Fn: func(args ...object.Object) object.Object {
+            if len(args) != 2 {
+                return newError("wrong number of arguments. got=%d, want=2", len(args))
+            }
+            x, err := numberArgument(name, args[0])
+            if err != nil {
+                return err
+            }
+            y, err := numberArgument(name, args[1])
+            if err != nil {
+                return err
+            }
+            return &object.Float{Value: fn(x, y)}
+        },
+    }
+}
+
+func floatPredicate(name string, predicate func(float64) bool) *object.Builtin {
+    return &object.Builtin{
+        Fn: func(args ...object.Object) object.Object {
+            if len(args) != 1 {
+                return newError("wrong number of arguments. got=%d, want=1", len(args))
+            }
+            x, err := numberArgument(name, args[0])
+            if err != nil {
+                return err
+            }
+            return nativeBooltoBoolObject(predicate(x))
+        },
+    }
+}
+
+// multiplyExact multiplies integers, reporting whether the product fits
+func multiplyExact(a, b int64) (int64, bool) {
+    if a == 0 || b == 0 {
+        return 0, true
+    }
+    product := a * b
+    if product / b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+        return 0, false
+    }
+    return product, true
+}
+
+// roundingFunction builds floor, ceil and trunc, which return INTEGER. Results
+// outside the INTEGER range are an error, as for int().
+func roundingFunction(name string, round func(float64) float64) *object.Builtin {
+    return &object.Builtin{
+        Fn: func(args ...object.Object) object.Object {
+            if len(args) != 1 {
+                return newError("wrong number of arguments. got=%d, want=1", len(args))
+            }
+            if args[0].Type() == object.INTEGER_OBJ {
+                return args[0]
+            }
+            x, err := numberArgument(name, args[0])
+            if err != nil {
+                return err
+            }
+            return toInteger(&object.Float{Value: round(x)}, 10)
+        },
+    }
+}
+
+func mathAbs(args ...object.Object) object.Object {
+    if len(args) != 1 {
+        return newError("wrong number of arguments. got=%d, want=1", len(args))
+    }
+    // the absolute value of min_int is not an INTEGER, so it becomes a FLOAT
+    if integer, ok := args[0].(*object.Integer); ok && integer.Value != math.MinInt64 {
+        if integer.Value < 0 {
+            return &object.Integer{Value: -integer.Value}
+        }
+        return integer
+    }
+    x, err := numberArgument("abs", args[0])
+    if err != nil {
+        return err
+    }
+    return &object.Float{Value: math.Abs(x)}
+}
+
+// mathRound rounds to a whole INTEGER, or to a FLOAT with the given number of
+// decimal digits. The optional mode is one of roundingModes, half_up by default.
+func mathRound(args ...object.Object) object.Object {
+    if len(args) < 1 || len(args) > 3 {
+        return newError("wrong number of arguments. got=%d, want=1 to 3", len(args))
+    }
+    x, err := numberArgument("round", args[0])
+    if err != nil {
+        return err
+    }
+
+    var digits int64
+    if len(args) >= 2 {
+        if digits, err = integerArgument("round", args[1]); err != nil {
+            return err
+        }
+    }
+
+    round := roundingModes["half_up"]
+    if len(args) == 3 {
+        mode, ok := args[2].(*object.String)
+        if !ok {
+            return newError("rounding mode must be STRING, got %s", args[2].Type())
+        }
+        if round, ok = roundingModes[mode.Value]; !ok {
+            return newError("unknown rounding mode: %s", mode.Value)
+        }
+    }
+
+    if args[0].Type() == object.INTEGER_OBJ && digits >= 0 {
+        return args[0]
+    }
+
+    scale := math.Pow(10, float64(digits))
+    rounded := round(x * scale) / scale
+    if len(args) == 1 || args[0].Type() == object.INTEGER_OBJ {
+        return toInteger(&object.Float{Value: rounded}, 10)
+    }
+    return &object.Float{Value: rounded}
+}
+
+// mathPow is exact for an INTEGER base and non-negative INTEGER exponent as
+// long as the result fits in an INTEGER. Larger results are FLOAT.
+func mathPow(args ...object.Object) object.Object {
+    if len(args) != 2 {
+        return newError("wrong number of arguments. got=%d, want=2", len(args))
+    }
+    base, baseIsInt := args[0].(*object.Integer)
+    exponent, exponentIsInt := args[1].(*object.Integer)
+    if baseIsInt && exponentIsInt && exponent.Value >= 0 {
+        if result, ok := integerPow(base.Value, exponent.Value); ok {
+            return &object.Integer{Value: result}
+        }
+    }
+
+    x, err := numberArgument("pow", args[0])
+    if err != nil {
+        return err
+    }
+    y, err := numberArgument("pow", args[1])
+    if err != nil {
+        return err
+    }
+    result := math.Pow(x, y)
+    if math.IsNaN(result) && !math.IsNaN(x) && !math.IsNaN(y) {
+        return domainError("pow", args...)
+    }
+    return &object.Float{Value: result}
+}
+
+// integerPow raises b to the power e by squaring, reporting whether the
+// result fits in an INTEGER
+func integerPow(b, e int64) (int64, bool) {
+    result := int64(1)
+    for {
+        var ok bool
+        if e & 1 == 1 {
+            if result, ok = multiplyExact(result, b); !ok {
+                return 0, false
+            }
+        }
+        e >>= 1
+        if e == 0 {
+            return result, true
+        }
+        if b, ok = multiplyExact(b, b); !ok {
+            return 0, false
+        }
+    }
+}
+
+// mathLog is the natural logarithm, or the logarithm in the optional base
+func mathLog(args ...object.Object) object.Object {
+    if len(args) != 1 && len(args) != 2 {
+        return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+    }
+    x, err := numberArgument("log", args[0])
+    if err != nil {
+        return err
+    }
+    if x <= 0 {
+        return domainError("log", args...)
+    }
+    if len(args) == 1 {
+        return &object.Float{Value: math.Log(x)}
+    }
+
+    base, err := numberArgument("log", args[1])
+    if err != nil {
+        return err
+    }
+    if base <= 0 || base == 1 {
+        return domainError("log", args...)
+    }
+    return &object.Float{Value: math.Log(x) / math.Log(base)}
+}
+
+// extremumFunction builds min and max, which take either several numbers or a
+// single array of them. sign is -1 for min and 1 for max.
+func extremumFunction(name string, sign int) *object.Builtin {
+    return &object.Builtin{
+        Fn: func(args ...object.Object) object.Object {
+            values := args
+            if len(args) == 1 {
+                array, ok := args[0].(*object.Array)
+                if !ok {
+                    return newError("argument to `%s` must be ARRAY, got %s", name, args[0].Type())
+                }
+                values = array.Elements
+            }
+            if len(values) == 0 {
+                return newError("`%s` of an empty sequence", name)
+            }
+
+            best := values[0]
+            for _, value := range values {
+                compared, err := compareNumbers(name, value, best)
+                if err != nil {
+                    return err
+                }
+                if compared == sign {
+                    best = value
+                }
+            }
+            return best
+        },
+    }
+}
+
+// compareNumbers returns -1, 0 or 1 as left is less than, equal to or greater
+// than right
+func compareNumbers(name string, left, right object.Object) (int, *object.Error) {
+    leftInt, leftIsInt := left.(*object.Integer)
+    rightInt, rightIsInt := right.(*object.Integer)
+    if leftIsInt && rightIsInt {
+        switch {
+        case leftInt.Value < rightInt.Value:
+            return -1, nil
+        case leftInt.Value > rightInt.Value:
+            return 1, nil
+        }
+        return 0, nil
+    }
+
+    x, err := numberArgument(name, left)
+    if err != nil {
+        return 0, err
+    }
+    y, err := numberArgument(name, right)
+    if err != nil {
+        return 0, err
+    }
+    switch {
+    case x < y:
+        return -1, nil
+    case x > y:
+        return 1, nil
+    }
+    return 0, nil
+}
+
+func mathClamp(args ...object.Object) object.Object {
+    if len(args) != 3 {
+        return newError("wrong number of arguments. got=%d, want=3", len(args))
+    }
+    value, lower, upper := args[0], args[1], args[2]
+
+    compared, err := compareNumbers("clamp", lower, upper)
+    if err != nil {
+        return err
+    }
+    if compared > 0 {
+        return newError("lower bound of `clamp` is greater than upper bound: %s > %s",
+            lower.Inspect(), upper.Inspect())
+    }
+
+    if compared, err = compareNumbers("clamp", value, lower); err != nil {
+        return err
+    }
+    if compared < 0 {
+        return lower
+    }
+    if compared, err = compareNumbers("clamp", value, upper); err != nil {
+        return err
+    }
+    if compared > 0 {
+        return upper
+    }
+    return value
+}
+
+func gcd(a, b int64) int64 {
+    for b != 0 {
+        a, b = b, a % b
+    }
+    if a < 0 {
+        return -a
+    }
+    return a
+}
+
+func integerPair(name string, args []object.Object) (int64, int64, *object.Error) {
+    if len(args) != 2 {
+        return 0, 0, newError("wrong number of arguments. got=%d, want=2", len(args))
+    }
+    a, err := integerArgument(name, args[0])
+    if err != nil {
+        return 0, 0, err
+    }
+    b, err := integerArgument(name, args[1])
+    if err != nil {
+        return 0, 0, err
+    }
+    return a, b, nil
+}
+
+func mathGcd(args ...object.Object) object.Object {
+    a, b, err := integerPair("gcd", args)
+    if err != nil {
+        return err
+    }
+    // only gcd(min_int, 0) and gcd(min_int, min_int) do not fit
+    result := gcd(a, b)
+    if result < 0 {
+        return newError("integer overflow: gcd(%d, %d)", a, b)
+    }
+    return &object.Integer{Value: result}
+}
+
+func mathLcm(args ...object.Object) object.Object {
+    a, b, err := integerPair("lcm", args)
+    if err != nil {
+        return err
+    }
+    if a == 0 || b == 0 {
+        return &object.Integer{Value: 0}
+    }
+    lcm, ok := multiplyExact(a / gcd(a, b), b)
+    if !ok || lcm == math.MinInt64 {
+        return newError("integer overflow: lcm(%d, %d)", a, b)
+    }
+    if lcm < 0 {
+        lcm = -lcm
+    }
+    return &object.Integer{Value: lcm}
+}
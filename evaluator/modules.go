@@ -0,0 +1,209 @@
+package evaluator
+
+import (
+	"charm/ast"
+	"charm/lexer"
+	"charm/object"
+	"charm/parser"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const moduleExtension = ".ch"
+
+// nativeModule returns the members of a module implemented in Go. It is
+// called once per runtime, on the first import of the module.
+type nativeModule func(runtime *object.Runtime) map[string]object.Object
+
+// nativeModules are imported by name and take precedence over files with
+// the same name
+var nativeModules = map[string]nativeModule{
+    "strings": stringsModule,
+    "math": mathModule,
+    "time": timeModule,
+    "random": randomModule,
+}
+
+func evalImportStatement(stmt *ast.ImportStatement, env *object.Environment) object.Object {
+    imported := importModule(stmt.Path.Value, env)
+    if isError(imported) {
+        return imported
+    }
+    module := imported.(*object.Module)
+
+    if stmt.Names == nil {
+        if err := env.Declare(module.Name, module, false); err != nil {
+            return newError("%s: %s", err, module.Name)
+        }
+        return module
+    }
+
+    for _, name := range stmt.Names {
+        value := getModuleExport(module, name.Value)
+        if isError(value) {
+            return value
+        }
+        if err := env.Declare(name.Value, value, false); err != nil {
+            return newError("%s: %s", err, name.Value)
+        }
+    }
+
+    return module
+}
+
+// importModule evaluates the module at importPath into its own environment.
+// Every module is evaluated once per runtime and cached afterwards.
+func importModule(importPath string, env *object.Environment) object.Object {
+    if load, ok := nativeModules[importPath]; ok {
+        return importNativeModule(importPath, load, env.Runtime())
+    }
+
+    path, err := resolveModule(importPath, env)
+    if err != nil {
+        return err
+    }
+
+    runtime := env.Runtime()
+    if module, ok := runtime.Module(path); ok {
+        return module
+    }
+
+    if chain, ok := runtime.StartLoading(path); !ok {
+        names := []string{}
+        for _, loading := range chain {
+            names = append(names, filepath.Base(loading))
+        }
+        return newError("circular import: %s", strings.Join(names, " -> "))
+    }
+    defer runtime.FinishLoading(path)
+
+    if err := runtime.Files.CheckRead(path); err != nil {
+        return newError("%s", err)
+    }
+    source, readErr := os.ReadFile(path)
+    if readErr != nil {
+        return newError("cannot read module %s: %s", importPath, readErr)
+    }
+
+    parser := parser.New(lexer.New(string(source)))
+    program := parser.ParseProgram()
+    if errors := parser.GetErrors(); len(errors) != 0 {
+        return newError("parse error in module %s: %s", importPath, errors[0])
+    }
+
+    moduleEnv := object.NewModuleEnvironment(runtime, filepath.Dir(path))
+    if result := evalStatements(program.Statements, moduleEnv); isError(result) {
+        return result
+    }
+
+    name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+    module := &object.Module{Name: name, Path: path, Env: moduleEnv}
+    runtime.AddModule(module)
+
+    return module
+}
+
+func importNativeModule(name string, load nativeModule, runtime *object.Runtime) object.Object {
+    if module, ok := runtime.Module(name); ok {
+        return module
+    }
+
+    moduleEnv := object.NewModuleEnvironment(runtime, "")
+    for member, value := range load(runtime) {
+        moduleEnv.Declare(member, value, true)
+        moduleEnv.Export(member)
+    }
+
+    module := &object.Module{Name: name, Path: name, Env: moduleEnv}
+    runtime.AddModule(module)
+
+    return module
+}
+
+// resolveModule finds the file of an import. Paths starting with "./" or
+// "../" are relative to the importing module. Other paths are looked up in
+// the importing module's directory and then in the search path. The ".ch"
+// extension may be left out.
+func resolveModule(importPath string, env *object.Environment) (string, *object.Error) {
+    name := importPath
+    if filepath.Ext(name) == "" {
+        name += moduleExtension
+    }
+
+    var dirs []string
+    switch {
+    case filepath.IsAbs(name):
+        dirs = []string{""}
+    case strings.HasPrefix(importPath, "./") || strings.HasPrefix(importPath, "../"):
+        dirs = []string{env.Dir()}
+    default:
+        dirs = append([]string{env.Dir()}, env.Runtime().SearchPath...)
+    }
+
+    // candidates the file policy forbids are not probed, so that a denied
+    // import does not tell whether the file exists
+    var denied error
+    for _, dir := range dirs {
+        candidate := filepath.Join(dir, name)
+        if err := env.Runtime().Files.CheckRead(candidate); err != nil {
+            if denied == nil {
+                denied = err
+            }
+            continue
+        }
+        if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+            if abs, err := filepath.Abs(candidate); err == nil {
+                return abs, nil
+            }
+            return candidate, nil
+        }
+    }
+
+    if denied != nil {
+        return "", newError("%s", denied)
+    }
+    return "", newError("module not found: %s", importPath)
+}
+
+func getModuleExport(module *object.Module, name string) object.Object {
+    if !module.Env.Exported(name) {
+        return newError("module %s has no export %s", module.Name, name)
+    }
+
+    value, _ := module.Env.Get(name)
+    return value
+}
+
+func evalExportStatement(stmt *ast.ExportStatement, env *object.Environment) object.Object {
+    result := Eval(stmt.Declaration, env)
+    if isError(result) {
+        return result
+    }
+
+    for _, name := range declaredNames(stmt.Declaration) {
+        if !env.Export(name) {
+            return newError("export is only allowed at the top level of a module: %s", name)
+        }
+    }
+
+    return result
+}
+
+func declaredNames(declaration ast.Statement) []string {
+    switch declaration := declaration.(type) {
+    case *ast.LetStatement:
+        if declaration.Pattern != nil {
+            return patternNames(declaration.Pattern)
+        }
+        return []string{declaration.Identifier.Value}
+    case *ast.FunctionStatement:
+        return []string{declaration.Identifier.Value}
+    case *ast.StructStatement:
+        return []string{declaration.Name.Value}
+    case *ast.EnumStatement:
+        return []string{declaration.Name.Value}
+    default:
+        return nil
+    }
+}
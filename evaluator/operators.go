@@ -0,0 +1,132 @@
+package evaluator
+
+import (
+	"charm/ast"
+	"charm/object"
+	"strings"
+)
+
+// operatorMethods maps operators to the methods a struct defines to overload them.
+// The left operand's method is called with the right operand.
+var operatorMethods = map[string]string{
+    "+": "__add__",
+    "-": "__sub__",
+    "*": "__mul__",
+    "/": "__div__",
+    "==": "__eq__",
+    "<": "__lt__",
+    "<=": "__le__",
+    ">": "__gt__",
+    ">=": "__ge__",
+}
+
+// callSpecialMethod calls the method name on obj if obj is an instance whose
+// struct defines it. ok is false when there is no such method.
+func callSpecialMethod(obj object.Object, name string, arguments ...object.Object) (result object.Object, ok bool) {
+    instance, ok := obj.(*object.Instance)
+    if !ok {
+        return nil, false
+    }
+
+    method, ok := instance.Struct.Methods[name]
+    if !ok {
+        return nil, false
+    }
+
+    return applyFunction(bindMethod(instance, method), arguments, nil, instance.Struct.Env.Runtime()), true
+}
+
+// evalOverloadedInfixExpression dispatches an operator to the left operand's
+// special method. "!=" negates __eq__, and comparisons without a method of
+// their own are derived from __lt__ and equality.
+func evalOverloadedInfixExpression(left object.Object, operator string, right object.Object) (object.Object, bool) {
+    if operator == "!=" {
+        equal, ok := callSpecialMethod(left, "__eq__", right)
+        if !ok || isError(equal) {
+            return equal, ok
+        }
+        return nativeBooltoBoolObject(!isTruthy(equal)), true
+    }
+
+    if result, ok := callSpecialMethod(left, operatorMethods[operator], right); ok {
+        return result, true
+    }
+
+    if operator != ">" && operator != "<=" && operator != ">=" {
+        return nil, false
+    }
+
+    less, ok := callSpecialMethod(left, "__lt__", right)
+    if !ok || isError(less) {
+        return less, ok
+    }
+    if operator == ">=" {
+        return nativeBooltoBoolObject(!isTruthy(less)), true
+    }
+
+    equal, ok := callSpecialMethod(left, "__eq__", right)
+    if !ok {
+        equal = nativeBooltoBoolObject(object.Equal(left, right))
+    } else if isError(equal) {
+        return equal, true
+    }
+
+    if operator == "<=" {
+        return nativeBooltoBoolObject(isTruthy(less) || isTruthy(equal)), true
+    }
+    return nativeBooltoBoolObject(!isTruthy(less) && !isTruthy(equal)), true
+}
+
+// "a ?? b" is a unless a is null. b is only evaluated when it is needed.
+func evalCoalesceExpression(exp *ast.InfixExpression, env *object.Environment) object.Object {
+    left := Eval(exp.Left, env)
+    if isError(left) || left != NULL {
+        return left
+    }
+
+    return Eval(exp.Right, env)
+}
+
+// evalMembershipExpression evaluates "element in container". Hashmaps are
+// searched by key, strings by substring and structs through __contains__.
+func evalMembershipExpression(element object.Object, container object.Object) object.Object {
+    switch container := container.(type) {
+    case *object.Array:
+        return nativeBooltoBoolObject(containsElement(container.Elements, element))
+    case *object.Tuple:
+        return nativeBooltoBoolObject(containsElement(container.Elements, element))
+    case *object.String:
+        substring, ok := element.(*object.String)
+        if !ok {
+            return newError("'in <string>' requires STRING as left operand, got %s", element.Type())
+        }
+        return nativeBooltoBoolObject(strings.Contains(container.Value, substring.Value))
+    case *object.HashMap:
+        key, ok := object.AsHashable(element)
+        if !ok {
+            return newError("unusable as haskey: %s", element.Type())
+        }
+        _, ok = container.Map[key.HashCode()]
+        return nativeBooltoBoolObject(ok)
+    case *object.Set:
+        key, ok := object.AsHashable(element)
+        return nativeBooltoBoolObject(ok && container.Has(key))
+    default:
+        if result, ok := callSpecialMethod(container, "__contains__", element); ok {
+            if isError(result) {
+                return result
+            }
+            return nativeBooltoBoolObject(isTruthy(result))
+        }
+        return newError("'in' not supported: %s", container.Type())
+    }
+}
+
+func containsElement(elements []object.Object, element object.Object) bool {
+    for _, candidate := range elements {
+        if object.Equal(candidate, element) {
+            return true
+        }
+    }
+    return false
+}
@@ -0,0 +1,321 @@
+package evaluator
+
+import (
+	"charm/ast"
+	"charm/object"
+	"fmt"
+)
+
+// binder introduces a single name produced by a pattern. Declarations, assignments,
+// parameters and match arms each bind names differently.
+type binder func(name string, value object.Object) *object.Error
+
+// type names usable in type patterns such as "n: Integer"
+var patternTypes = map[string][]object.ObjectType{
+    "Integer": {object.INTEGER_OBJ},
+    "Float": {object.FLOAT_OBJ},
+    "Number": {object.INTEGER_OBJ, object.FLOAT_OBJ},
+    "String": {object.STRING_OBJ},
+    "Boolean": {object.BOOLEAN_OBJ},
+    "Null": {object.NULL_OBJ},
+    "Array": {object.ARRAY_OBJ},
+    "HashMap": {object.HASHMAP_OBJ},
+    "Set": {object.SET_OBJ},
+    "Tuple": {object.TUPLE_OBJ},
+    "Function": {object.FUNCTION_OBJ, object.BUILTIN_OBJ, object.METHOD_OBJ, object.STRUCT_OBJ, object.VARIANT_OBJ},
+}
+
+// bindPattern destructures value according to pattern, calling bind for every
+// name in the pattern. A value whose shape does not fit the pattern is an error.
+func bindPattern(pattern ast.Pattern, value object.Object, env *object.Environment, bind binder) *object.Error {
+    mismatch, err := matchPattern(pattern, value, env, bind)
+    if err != nil {
+        return err
+    }
+    if mismatch != "" {
+        return newError("%s", mismatch)
+    }
+    return nil
+}
+
+// matchPattern binds the names of pattern if value fits it. Otherwise the
+// returned mismatch explains why it does not fit. Errors raised while matching,
+// such as a failing default value, are returned separately.
+func matchPattern(pattern ast.Pattern, value object.Object, env *object.Environment, bind binder) (string, *object.Error) {
+    switch pattern := pattern.(type) {
+    case *ast.Identifier:
+        return "", bind(pattern.Value, value)
+    case *ast.WildcardPattern:
+        return "", nil
+    case *ast.LiteralPattern:
+        literal := Eval(pattern.Value, env)
+        if isError(literal) {
+            return "", literal.(*object.Error)
+        }
+        if !object.Equal(literal, value) {
+            return fmt.Sprintf("%s does not match %s", value.Inspect(), pattern.String()), nil
+        }
+        return "", nil
+    case *ast.TypePattern:
+        return matchTypePattern(pattern, value, env, bind)
+    case *ast.EnumPattern:
+        return matchEnumPattern(pattern, value, env, bind)
+    case *ast.ArrayPattern:
+        return matchArrayPattern(pattern, value, env, bind)
+    case *ast.HashMapPattern:
+        return matchHashMapPattern(pattern, value, env, bind)
+    case *ast.DefaultPattern:
+        return matchPattern(pattern.Target, value, env, bind)
+    default:
+        return "", newError("unsupported pattern: %s", pattern.String())
+    }
+}
+
+func matchTypePattern(pattern *ast.TypePattern, value object.Object, env *object.Environment, bind binder) (string, *object.Error) {
+    types, ok := patternTypes[pattern.TypeName.Value]
+    if !ok {
+        return matchUserTypePattern(pattern, value, env, bind)
+    }
+
+    for _, objectType := range types {
+        if value.Type() == objectType {
+            return matchPattern(pattern.Target, value, env, bind)
+        }
+    }
+
+    return fmt.Sprintf("expected %s, got %s", pattern.TypeName.Value, value.Type()), nil
+}
+
+// matches struct instances and enum values of the type named in the
+// pattern, e.g. "p: Point" or "r: Result"
+func matchUserTypePattern(pattern *ast.TypePattern, value object.Object, env *object.Environment, bind binder) (string, *object.Error) {
+    obj, _ := env.Get(pattern.TypeName.Value)
+
+    var matches bool
+    switch userType := obj.(type) {
+    case *object.StructType:
+        instance, ok := value.(*object.Instance)
+        matches = ok && instance.Struct == userType
+    case *object.EnumType:
+        enumValue, ok := value.(*object.EnumValue)
+        matches = ok && enumValue.Variant.Enum == userType
+    default:
+        return "", newError("unknown type: %s", pattern.TypeName.Value)
+    }
+
+    if matches {
+        return matchPattern(pattern.Target, value, env, bind)
+    }
+
+    return fmt.Sprintf("expected %s, got %s", pattern.TypeName.Value, value.Type()), nil
+}
+
+// matchEnumPattern matches a variant and then its payload element by element
+func matchEnumPattern(pattern *ast.EnumPattern, value object.Object, env *object.Environment, bind binder) (string, *object.Error) {
+    obj, _ := env.Get(pattern.Enum.Value)
+    enumType, ok := obj.(*object.EnumType)
+    if !ok {
+        return "", newError("unknown enum: %s", pattern.Enum.Value)
+    }
+
+    variant, ok := enumType.Variant(pattern.Variant.Value)
+    if !ok {
+        return "", newError("%s has no variant %s", enumType.Name, pattern.Variant.Value)
+    }
+
+    if (pattern.Payload == nil) != (variant.Fields == nil) || len(pattern.Payload) != len(variant.Fields) {
+        return "", newError("wrong number of fields in pattern %s: expected %d, got %d",
+            pattern.String(), len(variant.Fields), len(pattern.Payload))
+    }
+
+    enumValue, ok := value.(*object.EnumValue)
+    if !ok || enumValue.Variant != variant {
+        return fmt.Sprintf("%s does not match %s", value.Inspect(), pattern.String()), nil
+    }
+
+    for i, element := range pattern.Payload {
+        mismatch, err := matchPattern(element, enumValue.Payload[i], env, bind)
+        if mismatch != "" || err != nil {
+            return mismatch, err
+        }
+    }
+
+    return "", nil
+}
+
+func matchArrayPattern(pattern *ast.ArrayPattern, value object.Object, env *object.Environment, bind binder) (string, *object.Error) {
+    var elements []object.Object
+    switch value := value.(type) {
+    case *object.Array:
+        elements = value.Elements
+    case *object.Tuple:
+        elements = value.Elements
+    default:
+        return fmt.Sprintf("cannot destructure %s as array: %s", value.Type(), pattern.String()), nil
+    }
+
+    if len(elements) > len(pattern.Elements) && pattern.Rest == nil {
+        return fmt.Sprintf("too many elements to destructure: expected %d, got %d",
+            len(pattern.Elements), len(elements)), nil
+    }
+
+    for i, element := range pattern.Elements {
+        if i < len(elements) {
+            mismatch, err := matchPattern(element, elements[i], env, bind)
+            if mismatch != "" || err != nil {
+                return mismatch, err
+            }
+            continue
+        }
+
+        hasDefault, err := bindDefault(element, env, bind)
+        if !hasDefault {
+            return fmt.Sprintf("not enough elements to destructure: expected %d, got %d",
+                len(pattern.Elements), len(elements)), nil
+        }
+        if err != nil {
+            return "", err
+        }
+    }
+
+    if pattern.Rest != nil {
+        rest := []object.Object{}
+        if len(elements) > len(pattern.Elements) {
+            rest = append(rest, elements[len(pattern.Elements):]...)
+        }
+        return "", bind(pattern.Rest.Value, &object.Array{Elements: rest})
+    }
+
+    return "", nil
+}
+
+func matchHashMapPattern(pattern *ast.HashMapPattern, value object.Object, env *object.Environment, bind binder) (string, *object.Error) {
+    hashMap, ok := value.(*object.HashMap)
+    if !ok {
+        return fmt.Sprintf("cannot destructure %s as hashmap: %s", value.Type(), pattern.String()), nil
+    }
+
+    for _, entry := range pattern.Entries {
+        key := Eval(entry.Key, env)
+        if isError(key) {
+            return "", key.(*object.Error)
+        }
+
+        hashable, ok := object.AsHashable(key)
+        if !ok {
+            return "", newError("unusable as haskey: %s", key.Type())
+        }
+        pair, ok := hashMap.Map[hashable.HashCode()]
+        if ok {
+            mismatch, err := matchPattern(entry.Value, pair.Value, env, bind)
+            if mismatch != "" || err != nil {
+                return mismatch, err
+            }
+            continue
+        }
+
+        hasDefault, err := bindDefault(entry.Value, env, bind)
+        if !hasDefault {
+            return fmt.Sprintf("missing key in destructuring: %s", key.Inspect()), nil
+        }
+        if err != nil {
+            return "", err
+        }
+    }
+
+    return "", nil
+}
+
+// binds the default value of a pattern whose element is missing. Reports false
+// if the pattern has no default.
+func bindDefault(pattern ast.Pattern, env *object.Environment, bind binder) (bool, *object.Error) {
+    defaultPattern, ok := pattern.(*ast.DefaultPattern)
+    if !ok {
+        return false, nil
+    }
+
+    value := Eval(defaultPattern.Default, env)
+    if isError(value) {
+        return true, value.(*object.Error)
+    }
+
+    return true, bindPattern(defaultPattern.Target, value, env, bind)
+}
+
+// evaluates the body of the first arm whose pattern matches and whose guard
+// holds. Each arm binds its names in a scope of its own.
+func evalMatchExpression(node *ast.MatchExpression, env *object.Environment) object.Object {
+    subject := Eval(node.Subject, env)
+    if isError(subject) {
+        return subject
+    }
+
+    for _, arm := range node.Arms {
+        armEnv := object.NewEnclosedEnvironment(env)
+        bind := func(name string, value object.Object) *object.Error {
+            armEnv.Set(name, value)
+            return nil
+        }
+
+        mismatch, err := matchPattern(arm.Pattern, subject, armEnv, bind)
+        if err != nil {
+            return err
+        }
+        if mismatch != "" {
+            continue
+        }
+
+        if arm.Guard != nil {
+            guard := Eval(arm.Guard, armEnv)
+            if isError(guard) {
+                return guard
+            }
+            if !isTruthy(guard) {
+                continue
+            }
+        }
+
+        result := Eval(arm.Body, armEnv)
+        if result == nil {
+            return NULL
+        }
+        return result
+    }
+
+    return newError("no match arm for value: %s", subject.Inspect())
+}
+
+// patternNames lists the names a pattern binds
+func patternNames(pattern ast.Pattern) []string {
+    switch pattern := pattern.(type) {
+    case *ast.Identifier:
+        return []string{pattern.Value}
+    case *ast.TypePattern:
+        return patternNames(pattern.Target)
+    case *ast.DefaultPattern:
+        return patternNames(pattern.Target)
+    case *ast.ArrayPattern:
+        names := []string{}
+        for _, element := range pattern.Elements {
+            names = append(names, patternNames(element)...)
+        }
+        if pattern.Rest != nil {
+            names = append(names, pattern.Rest.Value)
+        }
+        return names
+    case *ast.HashMapPattern:
+        names := []string{}
+        for _, entry := range pattern.Entries {
+            names = append(names, patternNames(entry.Value)...)
+        }
+        return names
+    case *ast.EnumPattern:
+        names := []string{}
+        for _, element := range pattern.Payload {
+            names = append(names, patternNames(element)...)
+        }
+        return names
+    default:
+        return nil
+    }
+}
@@ -0,0 +1,167 @@
+package evaluator
+
+import (
+	"bytes"
+	"charm/object"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// The process builtins check the runtime's ProcessPolicy, so that an
+// embedder can take each capability away
+var processBuiltins = map[string]*object.Builtin{
+    "env_get": {ContextFn: builtinEnvGet},
+    "env_set": {ContextFn: builtinEnvSet},
+    "exit": {ContextFn: builtinExit},
+    "run": {ContextFn: builtinRun},
+}
+
+// scriptArguments is the value of `args`, a new array on every access so that
+// scripts cannot change what other modules see
+func scriptArguments(runtime *object.Runtime) object.Object {
+    if runtime.Process.NoArgs {
+        return newError("permission denied: command-line arguments are disabled")
+    }
+    elements := []object.Object{}
+    for _, arg := range runtime.Args {
+        elements = append(elements, &object.String{Value: arg})
+    }
+    return &object.Array{Elements: elements}
+}
+
+// env_get returns the value of an environment variable, or the optional
+// default when it is not set
+func builtinEnvGet(ctx *object.CallContext, args ...object.Object) object.Object {
+    if err := checkCall("env_get", ctx, args, 1, 2); err != nil {
+        return err
+    }
+    if ctx.Runtime.Process.NoEnv {
+        return newError("permission denied: environment variables are disabled")
+    }
+    name, ok := args[0].(*object.String)
+    if !ok {
+        return newError("argument to `env_get` must be STRING, got %s", args[0].Type())
+    }
+
+    if value, ok := os.LookupEnv(name.Value); ok {
+        return &object.String{Value: value}
+    }
+    if len(args) == 2 {
+        return args[1]
+    }
+    return NULL
+}
+
+// env_set sets an environment variable, or unsets it when the value is null.
+// Subprocesses started with `run` inherit the environment.
+func builtinEnvSet(ctx *object.CallContext, args ...object.Object) object.Object {
+    if err := checkCall("env_set", ctx, args, 2, 2); err != nil {
+        return err
+    }
+    if ctx.Runtime.Process.NoEnv {
+        return newError("permission denied: environment variables are disabled")
+    }
+    name, ok := args[0].(*object.String)
+    if !ok {
+        return newError("argument to `env_set` must be STRING, got %s", args[0].Type())
+    }
+
+    var err error
+    switch value := args[1].(type) {
+    case *object.String:
+        err = os.Setenv(name.Value, value.Value)
+    case *object.Null:
+        err = os.Unsetenv(name.Value)
+    default:
+        return newError("value of `env_set` must be STRING or NULL, got %s", args[1].Type())
+    }
+    if err != nil {
+        return newError("cannot set %s: %s", name.Value, err)
+    }
+    return NULL
+}
+
+// exit ends the program with a status, 0 by default. It unwinds like an error
+// so that the embedder decides what ending the program means.
+func builtinExit(ctx *object.CallContext, args ...object.Object) object.Object {
+    if err := checkCall("exit", ctx, args, 0, 1); err != nil {
+        return err
+    }
+    if ctx.Runtime.Process.NoExit {
+        return newError("permission denied: exit is disabled")
+    }
+
+    code := int64(0)
+    if len(args) == 1 {
+        status, err := integerArgument("exit", args[0])
+        if err != nil {
+            return err
+        }
+        code = status
+    }
+    exit := newError("exit %d", code)
+    exit.Exit = true
+    exit.Code = int(code)
+    return exit
+}
+
+// run starts a program, given as an array of the program and its arguments,
+// and waits for it. It returns a hashmap of its status, stdout and stderr. The
+// keyword arguments input and dir set the standard input and the working
+// directory. No shell is involved, so arguments are passed as they are.
+func builtinRun(ctx *object.CallContext, args ...object.Object) object.Object {
+    if err := checkCall("run", ctx, args, 1, 1, "input", "dir"); err != nil {
+        return err
+    }
+    if ctx.Runtime.Process.NoRun {
+        return newError("permission denied: running subprocesses is disabled")
+    }
+
+    command := []string{}
+    elements, ok := sequenceElements(args[0])
+    if !ok {
+        return newError("argument to `run` must be ARRAY or TUPLE, got %s", args[0].Type())
+    }
+    for _, element := range elements {
+        str, ok := element.(*object.String)
+        if !ok {
+            return newError("command of `run` must consist of STRING, got %s", element.Type())
+        }
+        command = append(command, str.Value)
+    }
+    if len(command) == 0 {
+        return newError("command of `run` is empty")
+    }
+
+    cmd := exec.Command(command[0], command[1:]...)
+    var stdout, stderr bytes.Buffer
+    cmd.Stdout = &stdout
+    cmd.Stderr = &stderr
+
+    input, err := stringKeyword("run", ctx, "input", "")
+    if err != nil {
+        return err
+    }
+    cmd.Stdin = strings.NewReader(input)
+    if cmd.Dir, err = stringKeyword("run", ctx, "dir", ""); err != nil {
+        return err
+    }
+
+    runErr := cmd.Run()
+    var exitErr *exec.ExitError
+    var execErr *exec.Error
+    if errors.As(runErr, &execErr) {
+        return newError("cannot run %s: %s", command[0], execErr.Err)
+    }
+    if runErr != nil && !errors.As(runErr, &exitErr) {
+        return fileError("run", command[0], runErr)
+    }
+
+    result := object.NewHashMap()
+    result.Put(&object.String{Value: "status"}, &object.Integer{Value: int64(cmd.ProcessState.ExitCode())})
+    result.Put(&object.String{Value: "stdout"}, &object.String{Value: stdout.String()})
+    result.Put(&object.String{Value: "stderr"}, &object.String{Value: stderr.String()})
+    return result
+}
@@ -0,0 +1,60 @@
+package evaluator
+
+import (
+	"charm/object"
+)
+
+// randomModule has every method of a generator as a function drawing from
+// the runtime's global generator, so that "random.int(1, 6)" is
+// "random.generator().int(1, 6)" without creating a generator
+func randomModule(runtime *object.Runtime) map[string]object.Object {
+    members := map[string]object.Object{
+        "generator": &object.Builtin{ContextFn: randomGenerator},
+        "seed": &object.Builtin{ContextFn: randomSeed},
+    }
+    for _, name := range object.MethodNames(object.RANDOM_OBJ) {
+        members[name] = randomFunction(name)
+    }
+    return members
+}
+
+func randomFunction(name string) *object.Builtin {
+    return &object.Builtin{
+        ContextFn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+            if err := checkCall(name, ctx, args, 0, len(args)); err != nil {
+                return err
+            }
+            method, _ := object.LookupMethod(&object.Random{Rand: ctx.Runtime.Random}, name)
+            return method.Call(args...)
+        },
+    }
+}
+
+// generator creates a generator of its own. Without a seed it is seeded from
+// the global generator, so a run seeded with --seed stays reproducible.
+func randomGenerator(ctx *object.CallContext, args ...object.Object) object.Object {
+    if err := checkCall("generator", ctx, args, 0, 1); err != nil {
+        return err
+    }
+    if len(args) == 0 {
+        return &object.Random{Rand: object.NewRand(ctx.Runtime.Random.Int64())}
+    }
+    seed, err := integerArgument("generator", args[0])
+    if err != nil {
+        return err
+    }
+    return &object.Random{Rand: object.NewRand(seed)}
+}
+
+// seed restarts the global generator from a seed
+func randomSeed(ctx *object.CallContext, args ...object.Object) object.Object {
+    if err := checkCall("seed", ctx, args, 1, 1); err != nil {
+        return err
+    }
+    seed, err := integerArgument("seed", args[0])
+    if err != nil {
+        return err
+    }
+    ctx.Runtime.Random = object.NewRand(seed)
+    return NULL
+}
@@ -0,0 +1,45 @@
+package evaluator
+
+import (
+	"charm/object"
+	"errors"
+	"regexp"
+	"regexp/syntax"
+)
+
+var regexBuiltins = map[string]*object.Builtin{
+    "regex": {ContextFn: builtinRegex},
+}
+
+func compileRegex(pattern string) object.Object {
+    compiled, err := regexp.Compile(pattern)
+    if err != nil {
+        var syntaxErr *syntax.Error
+        if errors.As(err, &syntaxErr) {
+            return newError("invalid regex %q: %s: `%s`", pattern, syntaxErr.Code, syntaxErr.Expr)
+        }
+        return newError("invalid regex %q: %s", pattern, err)
+    }
+    return &object.Regex{Regexp: compiled}
+}
+
+// regex compiles a pattern in the RE2 syntax of Go's regexp package. The
+// runtime keeps recently compiled patterns, so calling regex in a loop is cheap.
+func builtinRegex(ctx *object.CallContext, args ...object.Object) object.Object {
+    if err := checkCall("regex", ctx, args, 1, 1); err != nil {
+        return err
+    }
+    pattern, ok := args[0].(*object.String)
+    if !ok {
+        return newError("argument to `regex` must be STRING, got %s", args[0].Type())
+    }
+
+    if cached, ok := ctx.Runtime.CachedRegex(pattern.Value); ok {
+        return cached
+    }
+    re := compileRegex(pattern.Value)
+    if re, ok := re.(*object.Regex); ok {
+        ctx.Runtime.CacheRegex(pattern.Value, re)
+    }
+    return re
+}
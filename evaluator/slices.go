@@ -0,0 +1,124 @@
+package evaluator
+
+import (
+	"charm/ast"
+	"charm/object"
+)
+
+// evalSliceExpression slices arrays, tuples and strings. Like Python, bounds
+// are clamped to the sequence rather than being out of range, even in strict mode.
+func evalSliceExpression(slice *ast.SliceExpression, env *object.Environment) object.Object {
+    obj := evalChainLink(slice.Left, env)
+    if isError(obj) {
+        return obj
+    }
+    if skipChain(obj, slice.Optional) {
+        return skipped
+    }
+
+    bounds := []*int64{}
+    for _, exp := range []ast.Expression{slice.Start, slice.End, slice.Step} {
+        bound, err := evalSliceBound(exp, env)
+        if err != nil {
+            return err
+        }
+        bounds = append(bounds, bound)
+    }
+
+    var length int
+    switch obj := obj.(type) {
+    case *object.Array:
+        length = len(obj.Elements)
+    case *object.Tuple:
+        length = len(obj.Elements)
+    case *object.String:
+        length = len([]rune(obj.Value))
+    default:
+        return newError("slice operator not supported: %s", obj.Type())
+    }
+
+    indices, err := sliceIndices(length, bounds[0], bounds[1], bounds[2])
+    if err != nil {
+        return err
+    }
+
+    switch obj := obj.(type) {
+    case *object.Array:
+        return &object.Array{Elements: selectElements(obj.Elements, indices)}
+    case *object.Tuple:
+        return &object.Tuple{Elements: selectElements(obj.Elements, indices)}
+    default:
+        runes := []rune(obj.(*object.String).Value)
+        sliced := []rune{}
+        for _, i := range indices {
+            sliced = append(sliced, runes[i])
+        }
+        return &object.String{Value: string(sliced)}
+    }
+}
+
+// an omitted or null bound is nil
+func evalSliceBound(exp ast.Expression, env *object.Environment) (*int64, *object.Error) {
+    if exp == nil {
+        return nil, nil
+    }
+
+    bound := Eval(exp, env)
+    switch bound := bound.(type) {
+    case *object.Error:
+        return nil, bound
+    case *object.Null:
+        return nil, nil
+    case *object.Integer:
+        return &bound.Value, nil
+    default:
+        return nil, newError("slice bound must be INTEGER, got %s", bound.Type())
+    }
+}
+
+// sliceIndices lists the positions selected by start:end:step in a sequence
+// of the given length
+func sliceIndices(length int, start *int64, end *int64, step *int64) ([]int, *object.Error) {
+    by := int64(1)
+    if step != nil {
+        by = *step
+    }
+    if by == 0 {
+        return nil, newError("slice step cannot be zero")
+    }
+
+    n := int64(length)
+    clamp := func(bound *int64, fallback int64, low int64, high int64) int64 {
+        if bound == nil {
+            return fallback
+        }
+        value := *bound
+        if value < 0 {
+            value += n
+        }
+        return max(low, min(value, high))
+    }
+
+    indices := []int{}
+    if by > 0 {
+        from, to := clamp(start, 0, 0, n), clamp(end, n, 0, n)
+        for i := from; i < to; i += by {
+            indices = append(indices, int(i))
+        }
+    } else {
+        from, to := clamp(start, n-1, -1, n-1), clamp(end, -1, -1, n-1)
+        for i := from; i > to; i += by {
+            indices = append(indices, int(i))
+        }
+    }
+
+    return indices, nil
+}
+
+func selectElements(elements []object.Object, indices []int) []object.Object {
+    selected := []object.Object{}
+    for _, i := range indices {
+        selected = append(selected, elements[i])
+    }
+    return selected
+}
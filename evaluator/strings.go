@@ -0,0 +1,53 @@
+package evaluator
+
+import (
+	"charm/object"
+)
+
+// stringsModule exposes the string methods as functions taking the string
+// first, so that "strings.split(s, sep)" is "s.split(sep)"
+func stringsModule(runtime *object.Runtime) map[string]object.Object {
+    members := map[string]object.Object{}
+    for _, name := range object.MethodNames(object.STRING_OBJ) {
+        members[name] = stringFunction(name)
+    }
+
+    members["join"] = &object.Builtin{
+        Fn: func(args ...object.Object) object.Object {
+            if len(args) != 2 {
+                return newError("wrong number of arguments. got=%d, want=2", len(args))
+            }
+
+            var items *object.Array
+            switch arg := args[0].(type) {
+            case *object.Array:
+                items = arg
+            case *object.Tuple:
+                items = &object.Array{Elements: arg.Elements}
+            default:
+                return newError("argument to `join` must be ARRAY or TUPLE, got %s", args[0].Type())
+            }
+
+            join, _ := object.LookupMethod(items, "join")
+            return join.Call(args[1])
+        },
+    }
+
+    return members
+}
+
+func stringFunction(name string) *object.Builtin {
+    return &object.Builtin{
+        Fn: func(args ...object.Object) object.Object {
+            if len(args) == 0 {
+                return newError("missing string argument to `%s`", name)
+            }
+            if args[0].Type() != object.STRING_OBJ {
+                return newError("argument to `%s` must be STRING, got %s", name, args[0].Type())
+            }
+
+            method, _ := object.LookupMethod(args[0], name)
+            return method.Call(args[1:]...)
+        },
+    }
+}
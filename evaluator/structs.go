@@ -0,0 +1,103 @@
+package evaluator
+
+import (
+	"charm/ast"
+	"charm/object"
+)
+
+// evalStructStatement declares a struct type. Its methods and field defaults
+// close over the environment the struct is declared in.
+func evalStructStatement(stmt *ast.StructStatement, env *object.Environment) object.Object {
+    structType := &object.StructType{
+        Name: stmt.Name.Value,
+        Fields: stmt.Fields,
+        Methods: map[string]*object.Function{},
+        Env: env,
+    }
+
+    for _, method := range stmt.Methods {
+        structType.Methods[method.Identifier.Value] = &object.Function{
+            Name: method.Identifier.Value,
+            Parameters: method.FunctionLiteral.Parameters,
+            Body: method.FunctionLiteral.Body,
+            Env: env,
+        }
+    }
+
+    if err := env.Declare(stmt.Name.Value, structType, false); err != nil {
+        return newError("%s: %s", err, stmt.Name.Value)
+    }
+
+    return structType
+}
+
+// constructInstance calls the struct's init method when it has one. Otherwise
+// the arguments are bound to the fields the same way parameters are bound.
+func constructInstance(structType *object.StructType, arguments []object.Object, keywords []keywordArgument) object.Object {
+    instance := &object.Instance{Struct: structType, Fields: map[string]object.Object{}}
+
+    if init, ok := structType.Methods["init"]; ok {
+        for _, field := range structType.Fields {
+            var value object.Object = NULL
+            if field.Default != nil {
+                value = Eval(field.Default, structType.Env)
+                if isError(value) {
+                    return value
+                }
+            }
+            instance.Fields[field.Name.Value] = value
+        }
+
+        result := applyFunction(bindMethod(instance, init), arguments, keywords, structType.Env.Runtime())
+        if isError(result) {
+            return result
+        }
+        return instance
+    }
+
+    constructor := &object.Function{Name: structType.Name, Parameters: structType.Fields, Env: structType.Env}
+    fieldEnv := object.NewEnclosedEnvironment(structType.Env)
+    if err := bindArguments(constructor, arguments, keywords, fieldEnv); err != nil {
+        return err
+    }
+
+    for _, field := range structType.Fields {
+        instance.Fields[field.Name.Value], _ = fieldEnv.Get(field.Name.Value)
+    }
+
+    return instance
+}
+
+// bindMethod returns a copy of method in which self refers to instance
+func bindMethod(instance *object.Instance, method *object.Function) *object.Function {
+    methodEnv := object.NewEnclosedEnvironment(method.Env)
+    methodEnv.Set("self", instance)
+
+    return &object.Function{
+        Name: method.Name,
+        Parameters: method.Parameters,
+        Body: method.Body,
+        Env: methodEnv,
+    }
+}
+
+func getInstanceMember(instance *object.Instance, name string) object.Object {
+    if value, ok := instance.Fields[name]; ok {
+        return value
+    }
+
+    if method, ok := instance.Struct.Methods[name]; ok {
+        return bindMethod(instance, method)
+    }
+
+    return newError("%s has no field %s", instance.Struct.Name, name)
+}
+
+func setInstanceField(instance *object.Instance, name string, value object.Object) *object.Error {
+    if _, ok := instance.Fields[name]; !ok {
+        return newError("%s has no field %s", instance.Struct.Name, name)
+    }
+
+    instance.Fields[name] = value
+    return nil
+}
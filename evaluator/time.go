@@ -0,0 +1,352 @@
+package evaluator
+
+import (
+	"charm/object"
+	"cmp"
+	"math"
+	"time"
+	// zones are looked up in the embedded database, so that conversions work
+	// the same on systems without zoneinfo files
+	_ "time/tzdata"
+)
+
+// timeModule reads the time from the runtime's clock, so that an embedder
+// can make scripts deterministic by replacing it
+func timeModule(runtime *object.Runtime) map[string]object.Object {
+    // monotonic measures from the first import, a reading of the clock that
+    // is not affected by changes of the wall time
+    origin := runtime.Clock.Now()
+
+    return map[string]object.Object{
+        "nanosecond": &object.Duration{Value: time.Nanosecond},
+        "microsecond": &object.Duration{Value: time.Microsecond},
+        "millisecond": &object.Duration{Value: time.Millisecond},
+        "second": &object.Duration{Value: time.Second},
+        "minute": &object.Duration{Value: time.Minute},
+        "hour": &object.Duration{Value: time.Hour},
+        "day": &object.Duration{Value: 24 * time.Hour},
+
+        "rfc3339": &object.String{Value: time.RFC3339},
+        "rfc1123": &object.String{Value: time.RFC1123},
+        "date_time": &object.String{Value: time.DateTime},
+        "date_only": &object.String{Value: time.DateOnly},
+        "time_only": &object.String{Value: time.TimeOnly},
+
+        "now": &object.Builtin{ContextFn: timeNow},
+        "since": &object.Builtin{ContextFn: timeSince},
+        "monotonic": &object.Builtin{ContextFn: func(ctx *object.CallContext, args ...object.Object) object.Object {
+            if err := checkCall("monotonic", ctx, args, 0, 0); err != nil {
+                return err
+            }
+            return &object.Duration{Value: ctx.Runtime.Clock.Now().Sub(origin)}
+        }},
+        "sleep": &object.Builtin{ContextFn: timeSleep},
+        "datetime": &object.Builtin{ContextFn: timeDatetime},
+        "from_unix": &object.Builtin{ContextFn: timeFromUnix},
+        "parse": &object.Builtin{ContextFn: timeParse},
+        "duration": &object.Builtin{ContextFn: timeDuration},
+        "parse_duration": &object.Builtin{Fn: timeParseDuration},
+    }
+}
+
+// locationKeyword reads the tz keyword argument, UTC by default
+func locationKeyword(ctx *object.CallContext) (*time.Location, *object.Error) {
+    tz, ok := ctx.Keywords["tz"]
+    if !ok {
+        return time.UTC, nil
+    }
+    name, ok := tz.(*object.String)
+    if !ok {
+        return nil, newError("tz must be STRING, got %s", tz.Type())
+    }
+    location, err := time.LoadLocation(name.Value)
+    if err != nil {
+        return nil, newError("%s", err)
+    }
+    return location, nil
+}
+
+// now is in the local zone unless the keyword argument tz names another
+func timeNow(ctx *object.CallContext, args ...object.Object) object.Object {
+    if err := checkCall("now", ctx, args, 0, 0, "tz"); err != nil {
+        return err
+    }
+    now := ctx.Runtime.Clock.Now()
+    if _, ok := ctx.Keywords["tz"]; !ok {
+        return &object.DateTime{Time: now}
+    }
+    location, err := locationKeyword(ctx)
+    if err != nil {
+        return err
+    }
+    return &object.DateTime{Time: now.In(location)}
+}
+
+// since is the time elapsed since a datetime. For a datetime from now() it
+// is measured on the monotonic clock.
+func timeSince(ctx *object.CallContext, args ...object.Object) object.Object {
+    if err := checkCall("since", ctx, args, 1, 1); err != nil {
+        return err
+    }
+    start, ok := args[0].(*object.DateTime)
+    if !ok {
+        return newError("argument to `since` must be DATETIME, got %s", args[0].Type())
+    }
+    return &object.Duration{Value: ctx.Runtime.Clock.Now().Sub(start.Time)}
+}
+
+// durationOrSeconds accepts a duration or a number of seconds
+func durationOrSeconds(name string, arg object.Object) (time.Duration, *object.Error) {
+    if duration, ok := arg.(*object.Duration); ok {
+        return duration.Value, nil
+    }
+    if !isNumber(arg) {
+        return 0, newError("argument to `%s` must be DURATION, INTEGER or FLOAT, got %s", name, arg.Type())
+    }
+    seconds := toFloat(arg).(*object.Float).Value
+    return time.Duration(math.Round(seconds * float64(time.Second))), nil
+}
+
+func timeSleep(ctx *object.CallContext, args ...object.Object) object.Object {
+    if err := checkCall("sleep", ctx, args, 1, 1); err != nil {
+        return err
+    }
+    duration, err := durationOrSeconds("sleep", args[0])
+    if err != nil {
+        return err
+    }
+    if duration > 0 {
+        ctx.Runtime.Clock.Sleep(duration)
+    }
+    return NULL
+}
+
+// datetime(year, month, day[, hour, minute, second, nanosecond]) builds a
+// datetime in UTC, or in the zone given by the keyword argument tz
+func timeDatetime(ctx *object.CallContext, args ...object.Object) object.Object {
+    if err := checkCall("datetime", ctx, args, 3, 7, "tz"); err != nil {
+        return err
+    }
+    fields := []struct {
+        name string
+        min, max int64
+    } {
+        {"year", math.MinInt32, math.MaxInt32}, {"month", 1, 12}, {"day", 1, 31},
+        {"hour", 0, 23}, {"minute", 0, 59}, {"second", 0, 59}, {"nanosecond", 0, 999999999},
+    }
+    values := make([]int, len(fields))
+    for i, arg := range args {
+        value, err := integerArgument("datetime", arg)
+        if err != nil {
+            return err
+        }
+        if value < fields[i].min || value > fields[i].max {
+            return newError("%s out of range in `datetime`: %d", fields[i].name, value)
+        }
+        values[i] = int(value)
+    }
+
+    location, err := locationKeyword(ctx)
+    if err != nil {
+        return err
+    }
+    t := time.Date(values[0], time.Month(values[1]), values[2], values[3], values[4], values[5], values[6], location)
+    if t.Day() != values[2] {
+        return newError("day out of range in `datetime`: %04d-%02d-%02d", values[0], values[1], values[2])
+    }
+    return &object.DateTime{Time: t}
+}
+
+// from_unix converts seconds since the Unix epoch, which may have a fraction
+func timeFromUnix(ctx *object.CallContext, args ...object.Object) object.Object {
+    if err := checkCall("from_unix", ctx, args, 1, 1, "tz"); err != nil {
+        return err
+    }
+    location, err := locationKeyword(ctx)
+    if err != nil {
+        return err
+    }
+    if seconds, ok := args[0].(*object.Integer); ok {
+        return &object.DateTime{Time: time.Unix(seconds.Value, 0).In(location)}
+    }
+    seconds, err := numberArgument("from_unix", args[0])
+    if err != nil {
+        return err
+    }
+    whole, fraction := math.Modf(seconds)
+    return &object.DateTime{Time: time.Unix(int64(whole), int64(fraction * 1e9)).In(location)}
+}
+
+// parse reads text in a layout of Go's time package. Text without a zone
+// offset is taken to be in UTC, or in the zone given by the keyword tz.
+func timeParse(ctx *object.CallContext, args ...object.Object) object.Object {
+    if err := checkCall("parse", ctx, args, 2, 2, "tz"); err != nil {
+        return err
+    }
+    for _, arg := range args {
+        if arg.Type() != object.STRING_OBJ {
+            return newError("argument to `parse` must be STRING, got %s", arg.Type())
+        }
+    }
+    location, err := locationKeyword(ctx)
+    if err != nil {
+        return err
+    }
+
+    text, layout := args[0].(*object.String).Value, args[1].(*object.String).Value
+    t, parseErr := time.ParseInLocation(layout, text, location)
+    if parseErr != nil {
+        return newError("%s", parseErr)
+    }
+    return &object.DateTime{Time: t}
+}
+
+var durationUnits = []struct {
+    name string
+    unit time.Duration
+} {
+    {"days", 24 * time.Hour}, {"hours", time.Hour}, {"minutes", time.Minute}, {"seconds", time.Second},
+    {"milliseconds", time.Millisecond}, {"microseconds", time.Microsecond}, {"nanoseconds", time.Nanosecond},
+}
+
+// duration sums its keyword arguments, e.g. duration(hours: 1, minutes: 30)
+func timeDuration(ctx *object.CallContext, args ...object.Object) object.Object {
+    names := []string{}
+    for _, unit := range durationUnits {
+        names = append(names, unit.name)
+    }
+    if err := checkCall("duration", ctx, args, 0, 0, names...); err != nil {
+        return err
+    }
+
+    var total time.Duration
+    for _, unit := range durationUnits {
+        arg, ok := ctx.Keywords[unit.name]
+        if !ok {
+            continue
+        }
+        if integer, ok := arg.(*object.Integer); ok {
+            total += time.Duration(integer.Value) * unit.unit
+            continue
+        }
+        value, err := numberArgument("duration", arg)
+        if err != nil {
+            return err
+        }
+        total += time.Duration(math.Round(value * float64(unit.unit)))
+    }
+    return &object.Duration{Value: total}
+}
+
+// parse_duration reads durations like "1h30m" or "250ms"
+func timeParseDuration(args ...object.Object) object.Object {
+    if len(args) != 1 {
+        return newError("wrong number of arguments. got=%d, want=1", len(args))
+    }
+    text, ok := args[0].(*object.String)
+    if !ok {
+        return newError("argument to `parse_duration` must be STRING, got %s", args[0].Type())
+    }
+    duration, err := time.ParseDuration(text.Value)
+    if err != nil {
+        return newError("invalid duration: %q", text.Value)
+    }
+    return &object.Duration{Value: duration}
+}
+
+func isTimeValue(obj object.Object) bool {
+    return obj.Type() == object.DATETIME_OBJ || obj.Type() == object.DURATION_OBJ
+}
+
+func compareResult(operator string, compared int) object.Object {
+    switch operator {
+    case "<":
+        return nativeBooltoBoolObject(compared < 0)
+    case "<=":
+        return nativeBooltoBoolObject(compared <= 0)
+    case ">":
+        return nativeBooltoBoolObject(compared > 0)
+    case ">=":
+        return nativeBooltoBoolObject(compared >= 0)
+    default:
+        return nil
+    }
+}
+
+// scaleDuration multiplies a duration by a number, rounding to nanoseconds
+func scaleDuration(duration time.Duration, factor object.Object) time.Duration {
+    if integer, ok := factor.(*object.Integer); ok {
+        return duration * time.Duration(integer.Value)
+    }
+    return time.Duration(math.Round(float64(duration) * toFloat(factor).(*object.Float).Value))
+}
+
+// evalTimeInfixExpression implements the arithmetic of datetimes and
+// durations. A datetime moves by a duration, the difference of two
+// datetimes is a duration, and durations scale by numbers.
+func evalTimeInfixExpression(left object.Object, operator string, right object.Object) object.Object {
+    switch left := left.(type) {
+    case *object.DateTime:
+        switch right := right.(type) {
+        case *object.Duration:
+            switch operator {
+            case "+":
+                return &object.DateTime{Time: left.Time.Add(right.Value)}
+            case "-":
+                return &object.DateTime{Time: left.Time.Add(-right.Value)}
+            }
+        case *object.DateTime:
+            if operator == "-" {
+                return &object.Duration{Value: left.Time.Sub(right.Time)}
+            }
+            if result := compareResult(operator, left.Time.Compare(right.Time)); result != nil {
+                return result
+            }
+        }
+    case *object.Duration:
+        switch right := right.(type) {
+        case *object.Duration:
+            switch operator {
+            case "+":
+                return &object.Duration{Value: left.Value + right.Value}
+            case "-":
+                return &object.Duration{Value: left.Value - right.Value}
+            case "/":
+                if right.Value == 0 {
+                    return newError("division by zero")
+                }
+                return &object.Float{Value: float64(left.Value) / float64(right.Value)}
+            }
+            if result := compareResult(operator, cmp.Compare(left.Value, right.Value)); result != nil {
+                return result
+            }
+        case *object.DateTime:
+            if operator == "+" {
+                return &object.DateTime{Time: right.Time.Add(left.Value)}
+            }
+        case *object.Integer, *object.Float:
+            switch operator {
+            case "*":
+                return &object.Duration{Value: scaleDuration(left.Value, right)}
+            case "/":
+                divisor := toFloat(right).(*object.Float).Value
+                if divisor == 0 {
+                    return newError("division by zero")
+                }
+                if integer, ok := right.(*object.Integer); ok {
+                    return &object.Duration{Value: left.Value / time.Duration(integer.Value)}
+                }
+                return &object.Duration{Value: time.Duration(math.Round(float64(left.Value) / divisor))}
+            }
+        }
+    case *object.Integer, *object.Float:
+        if right, ok := right.(*object.Duration); ok && operator == "*" {
+            return &object.Duration{Value: scaleDuration(right.Value, left)}
+        }
+    }
+
+    if left.Type() != right.Type() {
+        return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+    }
+    return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+}
@@ -11,6 +11,9 @@ type Lexer struct {
     position int
     readPosition int
     ch rune
+    // templates holds the count of open braces inside each ${ } currently
+    // being lexed, innermost last
+    templates []int
 }
 
 func New(input string) *Lexer {
@@ -20,6 +23,13 @@ func New(input string) *Lexer {
     return lexer
 }
 
+// Copy returns a snapshot of the lexer that does not share state with it
+func (lexer *Lexer) Copy() Lexer {
+    snapshot := *lexer
+    snapshot.templates = append([]int(nil), lexer.templates...)
+    return snapshot
+}
+
 func (lexer *Lexer) readChar() {
     if lexer.readPosition >= len(lexer.input) {
         lexer.ch = 0
@@ -39,6 +49,15 @@ func (lexer *Lexer) peekChar() rune {
     }
 }
 
+// peekCharAt looks offset characters ahead of the current one
+func (lexer *Lexer) peekCharAt(offset int) rune {
+    position := lexer.position + offset
+    if position >= len(lexer.input) {
+        return 0
+    }
+    return lexer.input[position]
+}
+
 func (lexer *Lexer) NextToken() token.Token {
     var tok token.Token
 
@@ -50,6 +69,9 @@ func (lexer *Lexer) NextToken() token.Token {
                 lexer.readChar()
                 tok.Type = token.EQ
                 tok.Literal = "=="
+            } else if lexer.peekChar() == '>' {
+                lexer.readChar()
+                tok = token.Token{Type: token.ARROW, Literal: "=>"}
             } else {
                 tok = newToken(token.ASSIGN, lexer.ch)
             }
@@ -60,8 +82,18 @@ func (lexer *Lexer) NextToken() token.Token {
         case ')':
             tok = newToken(token.RPAREN, lexer.ch)
         case '{':
+            if depth := len(lexer.templates); depth > 0 {
+                lexer.templates[depth - 1]++
+            }
             tok = newToken(token.LBRACE, lexer.ch)
         case '}':
+            depth := len(lexer.templates)
+            if depth > 0 && lexer.templates[depth - 1] == 0 {
+                return lexer.readTemplatePart()
+            }
+            if depth > 0 {
+                lexer.templates[depth - 1]--
+            }
             tok = newToken(token.RBRACE, lexer.ch)
         case '[':
             tok = newToken(token.LBRACKET, lexer.ch)
@@ -79,6 +111,35 @@ func (lexer *Lexer) NextToken() token.Token {
             tok = newToken(token.ASTERISK, lexer.ch)
         case ':':
             tok = newToken(token.COLON, lexer.ch)
+        case '.':
+            if lexer.peekChar() == '.' && lexer.peekCharAt(2) == '.' {
+                lexer.readChar()
+                lexer.readChar()
+                tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+            } else {
+                tok = newToken(token.DOT, lexer.ch)
+            }
+        case '?':
+            switch lexer.peekChar() {
+            case '?':
+                lexer.readChar()
+                tok = token.Token{Type: token.NULLISH, Literal: "??"}
+            case '.':
+                lexer.readChar()
+                tok = token.Token{Type: token.OPTIONAL_DOT, Literal: "?."}
+            case '[':
+                lexer.readChar()
+                tok = token.Token{Type: token.OPTIONAL_BRACKET, Literal: "?["}
+            default:
+                tok = newToken(token.ILLEGAL, lexer.ch)
+            }
+        case '|':
+            if lexer.peekChar() == '>' {
+                lexer.readChar()
+                tok = token.Token{Type: token.PIPE, Literal: "|>"}
+            } else {
+                tok = newToken(token.ILLEGAL, lexer.ch)
+            }
         case '!':
             if lexer.peekChar() == '=' {
                 lexer.readChar()
@@ -101,7 +162,11 @@ func (lexer *Lexer) NextToken() token.Token {
                 tok = newToken(token.GT, lexer.ch)
             }
         case '"':
-            stringLiteral := lexer.readString()
+            stringLiteral, interpolated := lexer.readString()
+            if interpolated {
+                lexer.templates = append(lexer.templates, 0)
+                return token.Token{Type: token.TEMPLATE_HEAD, Literal: stringLiteral}
+            }
             tok = token.Token{Type: token.STRING, Literal: stringLiteral }
             lexer.readChar()
             return tok
@@ -160,14 +225,35 @@ func (lexer *Lexer) readIdentifier() string {
 }
 
 // Challenge: add support for escape characters such as \t \n
-func (lexer *Lexer) readString() string {
+// readString reads up to the closing quote or the next "${". When it stops at
+// "${" both characters are consumed and interpolated is true
+func (lexer *Lexer) readString() (string, bool) {
     lexer.readChar()
     startPosition := lexer.position
 
     for  lexer.ch != 0 && lexer.ch != '"' {
+        if lexer.ch == '$' && lexer.peekChar() == '{' {
+            literal := string(lexer.input[startPosition: lexer.position])
+            lexer.readChar()
+            lexer.readChar()
+            return literal, true
+        }
         lexer.readChar()
     }
-    return string(lexer.input[startPosition: lexer.position])
+    return string(lexer.input[startPosition: lexer.position]), false
+}
+
+// readTemplatePart continues a template string after the "}" that closes
+// one of its embedded expressions
+func (lexer *Lexer) readTemplatePart() token.Token {
+    literal, interpolated := lexer.readString()
+    if interpolated {
+        return token.Token{Type: token.TEMPLATE_MIDDLE, Literal: literal}
+    }
+
+    lexer.templates = lexer.templates[:len(lexer.templates) - 1]
+    lexer.readChar()
+    return token.Token{Type: token.TEMPLATE_TAIL, Literal: literal}
 }
 
 func (lexer *Lexer) tokenizeNumber() token.Token {
@@ -32,6 +32,16 @@ func TestNextToken(t *testing.T) {
     while (true)
     foo123
     1234.1234
+    let x = 1;
+    const y = 2;
+    func(...rest) {}
+    x => x |> f
+    match (null)
+    s.upper()
+    struct Point {}
+    enum
+    a?.b?[0] ?? x not in y
+    "hi ${name}, ${ {1: 2}[1] } ${"a${b}"}"
     `   
 
     tests := []struct {
@@ -132,6 +142,69 @@ func TestNextToken(t *testing.T) {
         {token.RPAREN, ")"},
         {token.IDENT, "foo123"},
         {token.FLOAT, "1234.1234"},
+        {token.LET, "let"},
+        {token.IDENT, "x"},
+        {token.ASSIGN, "="},
+        {token.INT, "1"},
+        {token.SEMICOLON, ";"},
+        {token.CONST, "const"},
+        {token.IDENT, "y"},
+        {token.ASSIGN, "="},
+        {token.INT, "2"},
+        {token.SEMICOLON, ";"},
+        {token.FUNCTION, "func"},
+        {token.LPAREN, "("},
+        {token.ELLIPSIS, "..."},
+        {token.IDENT, "rest"},
+        {token.RPAREN, ")"},
+        {token.LBRACE, "{"},
+        {token.RBRACE, "}"},
+        {token.IDENT, "x"},
+        {token.ARROW, "=>"},
+        {token.IDENT, "x"},
+        {token.PIPE, "|>"},
+        {token.IDENT, "f"},
+        {token.MATCH, "match"},
+        {token.LPAREN, "("},
+        {token.NULL, "null"},
+        {token.RPAREN, ")"},
+        {token.IDENT, "s"},
+        {token.DOT, "."},
+        {token.IDENT, "upper"},
+        {token.LPAREN, "("},
+        {token.RPAREN, ")"},
+        {token.STRUCT, "struct"},
+        {token.IDENT, "Point"},
+        {token.LBRACE, "{"},
+        {token.RBRACE, "}"},
+        {token.ENUM, "enum"},
+        {token.IDENT, "a"},
+        {token.OPTIONAL_DOT, "?."},
+        {token.IDENT, "b"},
+        {token.OPTIONAL_BRACKET, "?["},
+        {token.INT, "0"},
+        {token.RBRACKET, "]"},
+        {token.NULLISH, "??"},
+        {token.IDENT, "x"},
+        {token.NOT, "not"},
+        {token.IN, "in"},
+        {token.IDENT, "y"},
+        {token.TEMPLATE_HEAD, "hi "},
+        {token.IDENT, "name"},
+        {token.TEMPLATE_MIDDLE, ", "},
+        {token.LBRACE, "{"},
+        {token.INT, "1"},
+        {token.COLON, ":"},
+        {token.INT, "2"},
+        {token.RBRACE, "}"},
+        {token.LBRACKET, "["},
+        {token.INT, "1"},
+        {token.RBRACKET, "]"},
+        {token.TEMPLATE_MIDDLE, " "},
+        {token.TEMPLATE_HEAD, "a"},
+        {token.IDENT, "b"},
+        {token.TEMPLATE_TAIL, ""},
+        {token.TEMPLATE_TAIL, ""},
         {token.EOF, ""},
     }
 
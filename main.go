@@ -6,18 +6,59 @@ import (
 	"charm/object"
 	"charm/parser"
 	"charm/repl"
+	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 )
 
 func main() {
-    args := os.Args
+    strict := flag.Bool("strict", false, "make out of range indexing an error instead of null")
+    separator := "separated by '" + string(os.PathListSeparator) + "'"
+    searchPath := flag.String("path", "", "directories to search for imported modules, " + separator)
+    allowRead := flag.String("allow-read", "", "confine reading files to these directories, " + separator)
+    allowWrite := flag.String("allow-write", "", "confine writing files to these directories, " + separator)
+    noWrite := flag.Bool("no-write", false, "forbid scripts to write or remove files")
+    noRun := flag.Bool("no-run", false, "forbid scripts to start subprocesses, which the file flags do not confine")
+    noEnv := flag.Bool("no-env", false, "forbid scripts to read or set environment variables")
+    noExit := flag.Bool("no-exit", false, "forbid scripts to end the process with exit")
+    seed := flag.Int64("seed", 0, "seed the global generator of the random module to make runs reproducible")
+    flag.Usage = func() {
+        fmt.Fprintf(flag.CommandLine.Output(), "usage: charm [flags] [script [args...]]\n")
+        flag.PrintDefaults()
+    }
+    flag.Parse()
+
+    runtime := object.NewRuntime()
+    runtime.Strict = *strict
+    // directories given on the command line are searched before CHARM_PATH
+    runtime.SearchPath = append(filepath.SplitList(*searchPath), filepath.SplitList(os.Getenv("CHARM_PATH"))...)
+    runtime.Files = object.FilePolicy{
+        NoWrite: *noWrite,
+        ReadRoots: filepath.SplitList(*allowRead),
+        WriteRoots: filepath.SplitList(*allowWrite),
+    }
+    runtime.Process = object.ProcessPolicy{
+        NoRun: *noRun,
+        NoEnv: *noEnv,
+        NoExit: *noExit,
+    }
+    flag.Visit(func(f *flag.Flag) {
+        if f.Name == "seed" {
+            runtime.Random = object.NewRand(*seed)
+        }
+    })
 
-    if len(args) == 1 {
+    args := flag.Args()
+
+    if len(args) == 0 {
         fmt.Printf("Charm v0.1\n")
-        repl.Start(os.Stdin, os.Stdout)
-    } else if len(args) == 2 {
-        filePath := args[1]
+        repl.Start(os.Stdin, os.Stdout, runtime)
+    } else {
+        filePath := args[0]
+        // the arguments after the script are the script's own, even if they
+        // look like flags
+        runtime.Args = args[1:]
         file, err := os.ReadFile(filePath)
         if err != nil {
             fmt.Printf("error reading file: %s\n", filePath)
@@ -30,11 +71,14 @@ func main() {
             return
         }
 
-        env := object.NewEnvironment()
+        env := object.NewModuleEnvironment(runtime, filepath.Dir(filePath))
 
-        evaluator.Eval(program, env)
-    } else {
-        fmt.Println("incorrect number of arguments")
+        if err, ok := evaluator.Eval(program, env).(*object.Error); ok {
+            if err.Exit {
+                os.Exit(err.Code)
+            }
+            os.Exit(1)
+        }
     }
 }
 
@@ -2,24 +2,86 @@ package object
 
 import (
 	"bytes"
+	"errors"
+)
+
+var (
+    ErrUndeclared = errors.New("undeclared identifier")
+    ErrRedeclared = errors.New("identifier already declared")
+    ErrConstant = errors.New("identifier is constant")
 )
 
 type Environment struct {
     store map[string]Object
+    constants map[string]bool
     outer *Environment
+    runtime *Runtime
+
+    // set on the root environment of a module
+    dir string
+    exports map[string]bool
 }
 
 func NewEnvironment() *Environment {
+    return NewEnvironmentWithRuntime(NewRuntime())
+}
+
+// NewEnvironmentWithRuntime creates a root environment for an interpreter
+// configured by runtime.
+func NewEnvironmentWithRuntime(runtime *Runtime) *Environment {
     s := make(map[string]Object)
-    return &Environment{store: s, outer: nil}
+    c := make(map[string]bool)
+    return &Environment{store: s, constants: c, outer: nil, runtime: runtime}
+}
+
+// NewModuleEnvironment creates the root environment of a module whose file is
+// in dir. Relative imports of the module are resolved against dir.
+func NewModuleEnvironment(runtime *Runtime, dir string) *Environment {
+    env := NewEnvironmentWithRuntime(runtime)
+    env.dir = dir
+    return env
 }
 
 func NewEnclosedEnvironment(outer *Environment) *Environment {
-    env := NewEnvironment()
+    env := NewEnvironmentWithRuntime(outer.runtime)
     env.outer = outer
     return env
 }
 
+func (e *Environment) Runtime() *Runtime {
+    return e.runtime
+}
+
+func (e *Environment) root() *Environment {
+    for e.outer != nil {
+        e = e.outer
+    }
+    return e
+}
+
+// Dir is the directory of the module the environment belongs to
+func (e *Environment) Dir() string {
+    return e.root().dir
+}
+
+// Export marks a binding as exported by the module. Only bindings of the
+// module's root scope can be exported.
+func (e *Environment) Export(key string) bool {
+    if e.outer != nil {
+        return false
+    }
+
+    if e.exports == nil {
+        e.exports = map[string]bool{}
+    }
+    e.exports[key] = true
+    return true
+}
+
+func (e *Environment) Exported(key string) bool {
+    return e.exports[key]
+}
+
 func (e *Environment) Get(key string) (Object, bool) {
     val, ok := e.store[key]
     if !ok && e.outer != nil {
@@ -28,11 +90,44 @@ func (e *Environment) Get(key string) (Object, bool) {
     return val, ok
 }
 
+// Set binds key in the current scope without any checks. Used for function
+// parameters and other bindings the evaluator creates on its own.
 func (e *Environment) Set(key string, val Object) Object {
     e.store[key] = val
+    delete(e.constants, key)
     return val
 }
 
+// Declare introduces a new binding in the current scope. A name can only be
+// declared once per scope, but it may shadow a binding of an outer scope.
+func (e *Environment) Declare(key string, val Object, constant bool) error {
+    if _, ok := e.store[key]; ok {
+        return ErrRedeclared
+    }
+
+    e.store[key] = val
+    if constant {
+        e.constants[key] = true
+    }
+    return nil
+}
+
+// Assign updates the nearest enclosing binding of key.
+func (e *Environment) Assign(key string, val Object) error {
+    if _, ok := e.store[key]; ok {
+        if e.constants[key] {
+            return ErrConstant
+        }
+        e.store[key] = val
+        return nil
+    }
+
+    if e.outer == nil {
+        return ErrUndeclared
+    }
+    return e.outer.Assign(key, val)
+}
+
 func (e *Environment) String() string {
     var out bytes.Buffer
 
@@ -0,0 +1,105 @@
+package object
+
+// Equal compares values structurally. Integers and floats compare by
+// numeric value, other objects without a value of their own by identity.
+func Equal(left Object, right Object) bool {
+	switch left := left.(type) {
+	case *Integer:
+		switch right := right.(type) {
+		case *Integer:
+			return left.Value == right.Value
+		case *Float:
+			return float64(left.Value) == right.Value
+		}
+		return false
+	case *Float:
+		switch right := right.(type) {
+		case *Integer:
+			return left.Value == float64(right.Value)
+		case *Float:
+			return left.Value == right.Value
+		}
+		return false
+	case *String:
+		right, ok := right.(*String)
+		return ok && left.Value == right.Value
+	case *Boolean:
+		right, ok := right.(*Boolean)
+		return ok && left.Value == right.Value
+	case *DateTime:
+		right, ok := right.(*DateTime)
+		return ok && left.Time.Equal(right.Time)
+	case *Duration:
+		right, ok := right.(*Duration)
+		return ok && left.Value == right.Value
+	case *Array:
+		right, ok := right.(*Array)
+		if !ok || len(left.Elements) != len(right.Elements) {
+			return false
+		}
+		for i := range left.Elements {
+			if !Equal(left.Elements[i], right.Elements[i]) {
+				return false
+			}
+		}
+		return true
+	case *Tuple:
+		right, ok := right.(*Tuple)
+		if !ok || len(left.Elements) != len(right.Elements) {
+			return false
+		}
+		for i := range left.Elements {
+			if !Equal(left.Elements[i], right.Elements[i]) {
+				return false
+			}
+		}
+		return true
+	case *Set:
+		right, ok := right.(*Set)
+		if !ok || len(left.Elements) != len(right.Elements) {
+			return false
+		}
+		for hashCode := range left.Elements {
+			if _, ok := right.Elements[hashCode]; !ok {
+				return false
+			}
+		}
+		return true
+	case *HashMap:
+		right, ok := right.(*HashMap)
+		if !ok || len(left.Map) != len(right.Map) {
+			return false
+		}
+		for hashCode, leftPair := range left.Map {
+			rightPair, ok := right.Map[hashCode]
+			if !ok || !Equal(leftPair.Value, rightPair.Value) {
+				return false
+			}
+		}
+		return true
+	case *Instance:
+		right, ok := right.(*Instance)
+		if !ok || left.Struct != right.Struct {
+			return false
+		}
+		for name, value := range left.Fields {
+			if !Equal(value, right.Fields[name]) {
+				return false
+			}
+		}
+		return true
+	case *EnumValue:
+		right, ok := right.(*EnumValue)
+		if !ok || left.Variant != right.Variant {
+			return false
+		}
+		for i := range left.Payload {
+			if !Equal(left.Payload[i], right.Payload[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return left == right
+	}
+}
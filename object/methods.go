@@ -0,0 +1,1217 @@
+package object
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MethodFunction implements a built-in method. The receiver is the value the
+// method was accessed on, e.g. the array in "arr.push(4)".
+type MethodFunction func(receiver Object, args ...Object) Object
+
+// ContextMethodFunction implements a built-in method that calls back into the
+// interpreter or takes keyword arguments.
+type ContextMethodFunction func(ctx *CallContext, receiver Object, args ...Object) Object
+
+// BoundMethod is a built-in method together with its receiver. It is the
+// value of a member expression like "s.upper".
+type BoundMethod struct {
+	Name     string
+	Receiver Object
+	Fn       MethodFunction
+	// ContextFn is set instead of Fn for methods with a call context
+	ContextFn ContextMethodFunction
+}
+
+func (bm *BoundMethod) Type() ObjectType {
+	return METHOD_OBJ
+}
+func (bm *BoundMethod) Inspect() string {
+	return fmt.Sprintf("builtin method %s.%s", bm.Receiver.Type(), bm.Name)
+}
+
+// Call invokes the method on its receiver. Methods with a call context are
+// called by the evaluator instead.
+func (bm *BoundMethod) Call(args ...Object) Object {
+	if bm.ContextFn != nil {
+		return NewError("builtin method %s.%s cannot be called without a call context", bm.Receiver.Type(), bm.Name)
+	}
+	return bm.Fn(bm.Receiver, args...)
+}
+
+var methods = map[ObjectType]map[string]MethodFunction{
+	STRING_OBJ:  stringMethods,
+	ARRAY_OBJ:   arrayMethods,
+	HASHMAP_OBJ: hashMapMethods,
+	SET_OBJ:     setMethods,
+	TUPLE_OBJ:   tupleMethods,
+	REGEX_OBJ:   regexMethods,
+	DATETIME_OBJ: datetimeMethods,
+	DURATION_OBJ: durationMethods,
+	RANDOM_OBJ:   randomMethods,
+}
+
+var contextMethods = map[ObjectType]map[string]ContextMethodFunction{
+	REGEX_OBJ: regexContextMethods,
+}
+
+// LookupMethod finds the built-in method name of obj's type and binds it to obj.
+func LookupMethod(obj Object, name string) (*BoundMethod, bool) {
+	if fn, ok := methods[obj.Type()][name]; ok {
+		return &BoundMethod{Name: name, Receiver: obj, Fn: fn}, true
+	}
+	if fn, ok := contextMethods[obj.Type()][name]; ok {
+		return &BoundMethod{Name: name, Receiver: obj, ContextFn: fn}, true
+	}
+	return nil, false
+}
+
+// MethodNames lists the built-in methods of a type in alphabetical order
+func MethodNames(objectType ObjectType) []string {
+	names := []string{}
+	for name := range methods[objectType] {
+		names = append(names, name)
+	}
+	for name := range contextMethods[objectType] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func wrongArguments(name string, args []Object, expected int) *Error {
+	if len(args) == expected {
+		return nil
+	}
+	return NewError("wrong number of arguments to %s: expected %d, got %d", name, expected, len(args))
+}
+
+// wrongArgumentRange is wrongArguments for methods with optional arguments
+func wrongArgumentRange(name string, args []Object, min, max int) *Error {
+	if len(args) >= min && len(args) <= max {
+		return nil
+	}
+	return NewError("wrong number of arguments to %s: expected %d to %d, got %d", name, min, max, len(args))
+}
+
+func stringArgument(name string, arg Object) (string, *Error) {
+	str, ok := arg.(*String)
+	if !ok {
+		return "", NewError("argument to `%s` must be STRING, got %s", name, arg.Type())
+	}
+	return str.Value, nil
+}
+
+func integerArgument(name string, arg Object) (int64, *Error) {
+	integer, ok := arg.(*Integer)
+	if !ok {
+		return 0, NewError("argument to `%s` must be INTEGER, got %s", name, arg.Type())
+	}
+	return integer.Value, nil
+}
+
+// runeIndex converts a byte offset into s to a character offset
+func runeIndex(s string, byteIndex int) int64 {
+	if byteIndex < 0 {
+		return -1
+	}
+	return int64(utf8.RuneCountInString(s[:byteIndex]))
+}
+
+// trimMethod builds trim, trim_start and trim_end. Without an argument they
+// remove whitespace, otherwise any of the characters in the argument.
+func trimMethod(name string, trimSpace func(string) string, trimCutset func(string, string) string) MethodFunction {
+	return func(receiver Object, args ...Object) Object {
+		if err := wrongArgumentRange(name, args, 0, 1); err != nil {
+			return err
+		}
+		if len(args) == 0 {
+			return &String{Value: trimSpace(receiver.(*String).Value)}
+		}
+		cutset, err := stringArgument(name, args[0])
+		if err != nil {
+			return err
+		}
+		return &String{Value: trimCutset(receiver.(*String).Value, cutset)}
+	}
+}
+
+// maxStringLength bounds in bytes the strings built by repeat and padding, so
+// that a huge count is an error instead of a crash
+const maxStringLength = 1 << 30
+
+// repeatString repeats s count times for the method name
+func repeatString(name string, s string, count int64) (string, *Error) {
+	if len(s) > 0 && count > int64(maxStringLength/len(s)) {
+		return "", NewError("result of `%s` would exceed %d bytes", name, maxStringLength)
+	}
+	return strings.Repeat(s, int(count)), nil
+}
+
+// padMethod builds pad_left and pad_right, which pad a string to a width in
+// characters with spaces or the optional fill character
+func padMethod(name string, left bool) MethodFunction {
+	return func(receiver Object, args ...Object) Object {
+		if err := wrongArgumentRange(name, args, 1, 2); err != nil {
+			return err
+		}
+		width, err := integerArgument(name, args[0])
+		if err != nil {
+			return err
+		}
+		fill := " "
+		if len(args) == 2 {
+			if fill, err = stringArgument(name, args[1]); err != nil {
+				return err
+			}
+			if utf8.RuneCountInString(fill) != 1 {
+				return NewError("fill argument to `%s` must be a single character, got %q", name, fill)
+			}
+		}
+
+		str := receiver.(*String).Value
+		missing := width - int64(utf8.RuneCountInString(str))
+		if missing <= 0 {
+			return receiver
+		}
+		padding, err := repeatString(name, fill, missing)
+		if err != nil {
+			return err
+		}
+		if left {
+			return &String{Value: padding + str}
+		}
+		return &String{Value: str + padding}
+	}
+}
+
+// string methods count and index in characters (runes) rather than bytes,
+// except for byte_len
+var stringMethods = map[string]MethodFunction{
+	"len": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("len", args, 0); err != nil {
+			return err
+		}
+		return &Integer{Value: int64(utf8.RuneCountInString(receiver.(*String).Value))}
+	},
+	"byte_len": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("byte_len", args, 0); err != nil {
+			return err
+		}
+		return &Integer{Value: int64(len(receiver.(*String).Value))}
+	},
+	"chars": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("chars", args, 0); err != nil {
+			return err
+		}
+		elements := []Object{}
+		for _, char := range receiver.(*String).Value {
+			elements = append(elements, &String{Value: string(char)})
+		}
+		return &Array{Elements: elements}
+	},
+	"upper": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("upper", args, 0); err != nil {
+			return err
+		}
+		return &String{Value: strings.ToUpper(receiver.(*String).Value)}
+	},
+	"lower": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("lower", args, 0); err != nil {
+			return err
+		}
+		return &String{Value: strings.ToLower(receiver.(*String).Value)}
+	},
+	"trim":       trimMethod("trim", strings.TrimSpace, strings.Trim),
+	"trim_start": trimMethod("trim_start", func(s string) string { return strings.TrimLeftFunc(s, unicode.IsSpace) }, strings.TrimLeft),
+	"trim_end":   trimMethod("trim_end", func(s string) string { return strings.TrimRightFunc(s, unicode.IsSpace) }, strings.TrimRight),
+	// split splits on whitespace without a separator. The optional limit is
+	// the maximum number of splits.
+	"split": func(receiver Object, args ...Object) Object {
+		if err := wrongArgumentRange("split", args, 0, 2); err != nil {
+			return err
+		}
+		str := receiver.(*String).Value
+
+		var parts []string
+		if len(args) == 0 {
+			parts = strings.Fields(str)
+		} else {
+			sep, err := stringArgument("split", args[0])
+			if err != nil {
+				return err
+			}
+			limit := int64(-1)
+			if len(args) == 2 {
+				if limit, err = integerArgument("split", args[1]); err != nil {
+					return err
+				}
+			}
+			if limit < 0 {
+				parts = strings.Split(str, sep)
+			} else {
+				parts = strings.SplitN(str, sep, int(limit)+1)
+			}
+		}
+
+		elements := []Object{}
+		for _, part := range parts {
+			elements = append(elements, &String{Value: part})
+		}
+		return &Array{Elements: elements}
+	},
+	"contains": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("contains", args, 1); err != nil {
+			return err
+		}
+		sub, err := stringArgument("contains", args[0])
+		if err != nil {
+			return err
+		}
+		return NativeBool(strings.Contains(receiver.(*String).Value, sub))
+	},
+	"starts_with": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("starts_with", args, 1); err != nil {
+			return err
+		}
+		prefix, err := stringArgument("starts_with", args[0])
+		if err != nil {
+			return err
+		}
+		return NativeBool(strings.HasPrefix(receiver.(*String).Value, prefix))
+	},
+	"ends_with": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("ends_with", args, 1); err != nil {
+			return err
+		}
+		suffix, err := stringArgument("ends_with", args[0])
+		if err != nil {
+			return err
+		}
+		return NativeBool(strings.HasSuffix(receiver.(*String).Value, suffix))
+	},
+	// find returns the character index of the first occurrence, or -1
+	"find": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("find", args, 1); err != nil {
+			return err
+		}
+		sub, err := stringArgument("find", args[0])
+		if err != nil {
+			return err
+		}
+		str := receiver.(*String).Value
+		return &Integer{Value: runeIndex(str, strings.Index(str, sub))}
+	},
+	// index is find but reports a missing substring as an error
+	"index": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("index", args, 1); err != nil {
+			return err
+		}
+		sub, err := stringArgument("index", args[0])
+		if err != nil {
+			return err
+		}
+		str := receiver.(*String).Value
+		byteIndex := strings.Index(str, sub)
+		if byteIndex < 0 {
+			return NewError("substring not found: %q", sub)
+		}
+		return &Integer{Value: runeIndex(str, byteIndex)}
+	},
+	"count": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("count", args, 1); err != nil {
+			return err
+		}
+		sub, err := stringArgument("count", args[0])
+		if err != nil {
+			return err
+		}
+		return &Integer{Value: int64(strings.Count(receiver.(*String).Value, sub))}
+	},
+	// replace replaces every occurrence, or only the first count of them
+	"replace": func(receiver Object, args ...Object) Object {
+		if err := wrongArgumentRange("replace", args, 2, 3); err != nil {
+			return err
+		}
+		old, err := stringArgument("replace", args[0])
+		if err != nil {
+			return err
+		}
+		new, err := stringArgument("replace", args[1])
+		if err != nil {
+			return err
+		}
+		count := int64(-1)
+		if len(args) == 3 {
+			if count, err = integerArgument("replace", args[2]); err != nil {
+				return err
+			}
+		}
+		return &String{Value: strings.Replace(receiver.(*String).Value, old, new, int(count))}
+	},
+	"repeat": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("repeat", args, 1); err != nil {
+			return err
+		}
+		count, err := integerArgument("repeat", args[0])
+		if err != nil {
+			return err
+		}
+		if count < 0 {
+			return NewError("negative count in `repeat`: %d", count)
+		}
+		repeated, err := repeatString("repeat", receiver.(*String).Value, count)
+		if err != nil {
+			return err
+		}
+		return &String{Value: repeated}
+	},
+	"pad_left":  padMethod("pad_left", true),
+	"pad_right": padMethod("pad_right", false),
+}
+
+// array methods modify the receiver in place, unlike the `push` builtin
+var arrayMethods = map[string]MethodFunction{
+	"len": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("len", args, 0); err != nil {
+			return err
+		}
+		return &Integer{Value: int64(len(receiver.(*Array).Elements))}
+	},
+	"push": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("push", args, 1); err != nil {
+			return err
+		}
+		array := receiver.(*Array)
+		array.Elements = append(array.Elements, args[0])
+		return array
+	},
+	"pop": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("pop", args, 0); err != nil {
+			return err
+		}
+		array := receiver.(*Array)
+		if len(array.Elements) == 0 {
+			return NULL
+		}
+
+		last := array.Elements[len(array.Elements)-1]
+		array.Elements = array.Elements[:len(array.Elements)-1]
+		return last
+	},
+	"first": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("first", args, 0); err != nil {
+			return err
+		}
+		array := receiver.(*Array)
+		if len(array.Elements) == 0 {
+			return NULL
+		}
+		return array.Elements[0]
+	},
+	"last": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("last", args, 0); err != nil {
+			return err
+		}
+		array := receiver.(*Array)
+		if len(array.Elements) == 0 {
+			return NULL
+		}
+		return array.Elements[len(array.Elements)-1]
+	},
+	"contains": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("contains", args, 1); err != nil {
+			return err
+		}
+		for _, element := range receiver.(*Array).Elements {
+			if Equal(element, args[0]) {
+				return TRUE
+			}
+		}
+		return FALSE
+	},
+	"index_of": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("index_of", args, 1); err != nil {
+			return err
+		}
+		for i, element := range receiver.(*Array).Elements {
+			if Equal(element, args[0]) {
+				return &Integer{Value: int64(i)}
+			}
+		}
+		return &Integer{Value: -1}
+	},
+	"join": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("join", args, 1); err != nil {
+			return err
+		}
+		sep, err := stringArgument("join", args[0])
+		if err != nil {
+			return err
+		}
+
+		parts := []string{}
+		for _, element := range receiver.(*Array).Elements {
+			parts = append(parts, element.Inspect())
+		}
+		return &String{Value: strings.Join(parts, sep)}
+	},
+}
+
+func hashKey(name string, arg Object) (Hashable, *Error) {
+	key, ok := AsHashable(arg)
+	if !ok {
+		return nil, NewError("unusable as hashkey in `%s`: %s", name, arg.Type())
+	}
+	return key, nil
+}
+
+var hashMapMethods = map[string]MethodFunction{
+	"len": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("len", args, 0); err != nil {
+			return err
+		}
+		return &Integer{Value: int64(len(receiver.(*HashMap).Map))}
+	},
+	"keys": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("keys", args, 0); err != nil {
+			return err
+		}
+		keys := []Object{}
+		for _, pair := range receiver.(*HashMap).Pairs() {
+			keys = append(keys, pair.Key)
+		}
+		return &Array{Elements: keys}
+	},
+	"values": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("values", args, 0); err != nil {
+			return err
+		}
+		values := []Object{}
+		for _, pair := range receiver.(*HashMap).Pairs() {
+			values = append(values, pair.Value)
+		}
+		return &Array{Elements: values}
+	},
+	"has": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("has", args, 1); err != nil {
+			return err
+		}
+		key, err := hashKey("has", args[0])
+		if err != nil {
+			return err
+		}
+		_, ok := receiver.(*HashMap).Map[key.HashCode()]
+		return NativeBool(ok)
+	},
+	// get returns the value of a key, or the optional default when it is missing
+	"get": func(receiver Object, args ...Object) Object {
+		if err := wrongArgumentRange("get", args, 1, 2); err != nil {
+			return err
+		}
+		key, err := hashKey("get", args[0])
+		if err != nil {
+			return err
+		}
+		if pair, ok := receiver.(*HashMap).Map[key.HashCode()]; ok {
+			return pair.Value
+		}
+		if len(args) == 2 {
+			return args[1]
+		}
+		return NULL
+	},
+	"set": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("set", args, 2); err != nil {
+			return err
+		}
+		key, err := hashKey("set", args[0])
+		if err != nil {
+			return err
+		}
+		hashMap := receiver.(*HashMap)
+		hashMap.Put(key, args[1])
+		return hashMap
+	},
+	"delete": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("delete", args, 1); err != nil {
+			return err
+		}
+		key, err := hashKey("delete", args[0])
+		if err != nil {
+			return err
+		}
+		receiver.(*HashMap).Delete(key)
+		return NULL
+	},
+}
+
+func setElement(name string, arg Object) (Hashable, *Error) {
+	element, ok := AsHashable(arg)
+	if !ok {
+		return nil, NewError("unusable as set element in `%s`: %s", name, arg.Type())
+	}
+	return element, nil
+}
+
+func setArgument(name string, arg Object) (*Set, *Error) {
+	set, ok := arg.(*Set)
+	if !ok {
+		return nil, NewError("argument to `%s` must be SET, got %s", name, arg.Type())
+	}
+	return set, nil
+}
+
+// union, intersection and difference return new sets, add and remove modify the receiver
+var setMethods = map[string]MethodFunction{
+	"len": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("len", args, 0); err != nil {
+			return err
+		}
+		return &Integer{Value: int64(len(receiver.(*Set).Elements))}
+	},
+	"contains": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("contains", args, 1); err != nil {
+			return err
+		}
+		element, ok := AsHashable(args[0])
+		return NativeBool(ok && receiver.(*Set).Has(element))
+	},
+	"add": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("add", args, 1); err != nil {
+			return err
+		}
+		element, err := setElement("add", args[0])
+		if err != nil {
+			return err
+		}
+		set := receiver.(*Set)
+		set.Add(element)
+		return set
+	},
+	"remove": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("remove", args, 1); err != nil {
+			return err
+		}
+		element, err := setElement("remove", args[0])
+		if err != nil {
+			return err
+		}
+		set := receiver.(*Set)
+		set.Remove(element)
+		return set
+	},
+	"union": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("union", args, 1); err != nil {
+			return err
+		}
+		other, err := setArgument("union", args[0])
+		if err != nil {
+			return err
+		}
+
+		result := NewSet()
+		for _, element := range receiver.(*Set).Values() {
+			result.Add(element)
+		}
+		for _, element := range other.Values() {
+			result.Add(element)
+		}
+		return result
+	},
+	"intersection": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("intersection", args, 1); err != nil {
+			return err
+		}
+		other, err := setArgument("intersection", args[0])
+		if err != nil {
+			return err
+		}
+
+		result := NewSet()
+		for _, element := range receiver.(*Set).Values() {
+			if other.Has(element) {
+				result.Add(element)
+			}
+		}
+		return result
+	},
+	"difference": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("difference", args, 1); err != nil {
+			return err
+		}
+		other, err := setArgument("difference", args[0])
+		if err != nil {
+			return err
+		}
+
+		result := NewSet()
+		for _, element := range receiver.(*Set).Values() {
+			if !other.Has(element) {
+				result.Add(element)
+			}
+		}
+		return result
+	},
+}
+
+var tupleMethods = map[string]MethodFunction{
+	"len": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("len", args, 0); err != nil {
+			return err
+		}
+		return &Integer{Value: int64(len(receiver.(*Tuple).Elements))}
+	},
+	"contains": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("contains", args, 1); err != nil {
+			return err
+		}
+		for _, element := range receiver.(*Tuple).Elements {
+			if Equal(element, args[0]) {
+				return TRUE
+			}
+		}
+		return FALSE
+	},
+}
+
+// regexMatch describes the match of re in s at loc, as returned by
+// FindStringSubmatchIndex. Offsets count characters like the string methods.
+func regexMatch(re *regexp.Regexp, s string, loc []int) *HashMap {
+	groups := []Object{}
+	named := NewHashMap()
+	for i := 1; i < len(loc)/2; i++ {
+		var group Object = NULL
+		if loc[2*i] >= 0 {
+			group = &String{Value: s[loc[2*i]:loc[2*i+1]]}
+		}
+		groups = append(groups, group)
+		if name := re.SubexpNames()[i]; name != "" {
+			named.Put(&String{Value: name}, group)
+		}
+	}
+
+	match := NewHashMap()
+	match.Put(&String{Value: "text"}, &String{Value: s[loc[0]:loc[1]]})
+	match.Put(&String{Value: "start"}, &Integer{Value: runeIndex(s, loc[0])})
+	match.Put(&String{Value: "end"}, &Integer{Value: runeIndex(s, loc[1])})
+	match.Put(&String{Value: "groups"}, &Array{Elements: groups})
+	match.Put(&String{Value: "named"}, named)
+	return match
+}
+
+// regexLimit reads the optional limit argument at index i, -1 meaning no limit
+func regexLimit(name string, args []Object, i int) (int, *Error) {
+	if len(args) <= i {
+		return -1, nil
+	}
+	limit, err := integerArgument(name, args[i])
+	if err != nil {
+		return 0, err
+	}
+	if limit < 0 {
+		return -1, nil
+	}
+	return int(limit), nil
+}
+
+// regex methods take the string to search as their first argument. A match
+// is a hashmap with the matched text, its start and end, the groups in
+// order and the named groups by name.
+var regexMethods = map[string]MethodFunction{
+	"matches": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("matches", args, 1); err != nil {
+			return err
+		}
+		str, err := stringArgument("matches", args[0])
+		if err != nil {
+			return err
+		}
+		return NativeBool(receiver.(*Regex).Regexp.MatchString(str))
+	},
+	"match": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("match", args, 1); err != nil {
+			return err
+		}
+		str, err := stringArgument("match", args[0])
+		if err != nil {
+			return err
+		}
+		re := receiver.(*Regex).Regexp
+		loc := re.FindStringSubmatchIndex(str)
+		if loc == nil {
+			return NULL
+		}
+		return regexMatch(re, str, loc)
+	},
+	"match_all": func(receiver Object, args ...Object) Object {
+		if err := wrongArgumentRange("match_all", args, 1, 2); err != nil {
+			return err
+		}
+		str, err := stringArgument("match_all", args[0])
+		if err != nil {
+			return err
+		}
+		limit, err := regexLimit("match_all", args, 1)
+		if err != nil {
+			return err
+		}
+
+		re := receiver.(*Regex).Regexp
+		matches := []Object{}
+		for _, loc := range re.FindAllStringSubmatchIndex(str, limit) {
+			matches = append(matches, regexMatch(re, str, loc))
+		}
+		return &Array{Elements: matches}
+	},
+	"find_all": func(receiver Object, args ...Object) Object {
+		if err := wrongArgumentRange("find_all", args, 1, 2); err != nil {
+			return err
+		}
+		str, err := stringArgument("find_all", args[0])
+		if err != nil {
+			return err
+		}
+		limit, err := regexLimit("find_all", args, 1)
+		if err != nil {
+			return err
+		}
+
+		found := []Object{}
+		for _, text := range receiver.(*Regex).Regexp.FindAllString(str, limit) {
+			found = append(found, &String{Value: text})
+		}
+		return &Array{Elements: found}
+	},
+	"split": func(receiver Object, args ...Object) Object {
+		if err := wrongArgumentRange("split", args, 1, 2); err != nil {
+			return err
+		}
+		str, err := stringArgument("split", args[0])
+		if err != nil {
+			return err
+		}
+		limit, err := regexLimit("split", args, 1)
+		if err != nil {
+			return err
+		}
+		if limit >= 0 {
+			limit++
+		}
+
+		parts := []Object{}
+		for _, part := range receiver.(*Regex).Regexp.Split(str, limit) {
+			parts = append(parts, &String{Value: part})
+		}
+		return &Array{Elements: parts}
+	},
+}
+
+var regexContextMethods = map[string]ContextMethodFunction{
+	// replace substitutes matches with a template, where $1 and $name refer
+	// to groups, or with the result of calling a function with the match
+	"replace": func(ctx *CallContext, receiver Object, args ...Object) Object {
+		if err := wrongArgumentRange("replace", args, 2, 3); err != nil {
+			return err
+		}
+		if len(ctx.Keywords) > 0 {
+			return NewError("keyword arguments not supported by builtin methods")
+		}
+		str, err := stringArgument("replace", args[0])
+		if err != nil {
+			return err
+		}
+		limit, err := regexLimit("replace", args, 2)
+		if err != nil {
+			return err
+		}
+
+		re := receiver.(*Regex).Regexp
+		var out []byte
+		last := 0
+		for _, loc := range re.FindAllStringSubmatchIndex(str, limit) {
+			out = append(out, str[last:loc[0]]...)
+			last = loc[1]
+
+			if template, ok := args[1].(*String); ok {
+				out = re.ExpandString(out, template.Value, str, loc)
+				continue
+			}
+			replacement := ctx.Call(args[1], regexMatch(re, str, loc))
+			switch replacement := replacement.(type) {
+			case *Error:
+				return replacement
+			case *String:
+				out = append(out, replacement.Value...)
+			default:
+				return NewError("replacement function of `replace` must return STRING, got %s", replacement.Type())
+			}
+		}
+		out = append(out, str[last:]...)
+		return &String{Value: string(out)}
+	},
+}
+
+func durationArgument(name string, arg Object) (time.Duration, *Error) {
+	duration, ok := arg.(*Duration)
+	if !ok {
+		return 0, NewError("argument to `%s` must be DURATION, got %s", name, arg.Type())
+	}
+	return duration.Value, nil
+}
+
+// datetimeField builds the methods reading a calendar field of a datetime
+func datetimeField(name string, field func(time.Time) int) MethodFunction {
+	return func(receiver Object, args ...Object) Object {
+		if err := wrongArguments(name, args, 0); err != nil {
+			return err
+		}
+		return &Integer{Value: int64(field(receiver.(*DateTime).Time))}
+	}
+}
+
+// datetimeIn builds the methods converting a datetime to another zone
+func datetimeIn(name string, location *time.Location) MethodFunction {
+	return func(receiver Object, args ...Object) Object {
+		if err := wrongArguments(name, args, 0); err != nil {
+			return err
+		}
+		return &DateTime{Time: receiver.(*DateTime).Time.In(location)}
+	}
+}
+
+// datetime methods read fields in the zone of the datetime. Layouts of
+// format are those of Go's time package, e.g. "2006-01-02 15:04".
+var datetimeMethods = map[string]MethodFunction{
+	"year":       datetimeField("year", time.Time.Year),
+	"month":      datetimeField("month", func(t time.Time) int { return int(t.Month()) }),
+	"day":        datetimeField("day", time.Time.Day),
+	"hour":       datetimeField("hour", time.Time.Hour),
+	"minute":     datetimeField("minute", time.Time.Minute),
+	"second":     datetimeField("second", time.Time.Second),
+	"nanosecond": datetimeField("nanosecond", time.Time.Nanosecond),
+	"year_day":   datetimeField("year_day", time.Time.YearDay),
+	"weekday": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("weekday", args, 0); err != nil {
+			return err
+		}
+		return &String{Value: receiver.(*DateTime).Time.Weekday().String()}
+	},
+	"unix": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("unix", args, 0); err != nil {
+			return err
+		}
+		return &Integer{Value: receiver.(*DateTime).Time.Unix()}
+	},
+	"zone": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("zone", args, 0); err != nil {
+			return err
+		}
+		return &String{Value: receiver.(*DateTime).Time.Location().String()}
+	},
+	"format": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("format", args, 1); err != nil {
+			return err
+		}
+		layout, err := stringArgument("format", args[0])
+		if err != nil {
+			return err
+		}
+		return &String{Value: receiver.(*DateTime).Time.Format(layout)}
+	},
+	"utc":   datetimeIn("utc", time.UTC),
+	"local": datetimeIn("local", time.Local),
+	// in_tz converts to a zone of the IANA database, e.g. "Europe/Paris"
+	"in_tz": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("in_tz", args, 1); err != nil {
+			return err
+		}
+		name, err := stringArgument("in_tz", args[0])
+		if err != nil {
+			return err
+		}
+		location, loadErr := time.LoadLocation(name)
+		if loadErr != nil {
+			return NewError("%s", loadErr)
+		}
+		return &DateTime{Time: receiver.(*DateTime).Time.In(location)}
+	},
+	// add_date adds calendar years, months and days, normalizing overflow
+	// the way Go does: October 31 plus one month is December 1
+	"add_date": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("add_date", args, 3); err != nil {
+			return err
+		}
+		parts := [3]int64{}
+		for i, arg := range args {
+			part, err := integerArgument("add_date", arg)
+			if err != nil {
+				return err
+			}
+			parts[i] = part
+		}
+		t := receiver.(*DateTime).Time
+		return &DateTime{Time: t.AddDate(int(parts[0]), int(parts[1]), int(parts[2]))}
+	},
+	"truncate": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("truncate", args, 1); err != nil {
+			return err
+		}
+		unit, err := durationArgument("truncate", args[0])
+		if err != nil {
+			return err
+		}
+		return &DateTime{Time: receiver.(*DateTime).Time.Truncate(unit)}
+	},
+}
+
+// durationIn builds the methods measuring a duration in a unit as a float
+func durationIn(name string, unit time.Duration) MethodFunction {
+	return func(receiver Object, args ...Object) Object {
+		if err := wrongArguments(name, args, 0); err != nil {
+			return err
+		}
+		value := receiver.(*Duration).Value
+		whole, fraction := value/unit, value%unit
+		return &Float{Value: float64(whole) + float64(fraction)/float64(unit)}
+	}
+}
+
+// durationRounding builds round and truncate, which take the unit to round to
+func durationRounding(name string, round func(time.Duration, time.Duration) time.Duration) MethodFunction {
+	return func(receiver Object, args ...Object) Object {
+		if err := wrongArguments(name, args, 1); err != nil {
+			return err
+		}
+		unit, err := durationArgument(name, args[0])
+		if err != nil {
+			return err
+		}
+		return &Duration{Value: round(receiver.(*Duration).Value, unit)}
+	}
+}
+
+var durationMethods = map[string]MethodFunction{
+	"hours":   durationIn("hours", time.Hour),
+	"minutes": durationIn("minutes", time.Minute),
+	"seconds": durationIn("seconds", time.Second),
+	"milliseconds": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("milliseconds", args, 0); err != nil {
+			return err
+		}
+		return &Integer{Value: receiver.(*Duration).Value.Milliseconds()}
+	},
+	"nanoseconds": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("nanoseconds", args, 0); err != nil {
+			return err
+		}
+		return &Integer{Value: receiver.(*Duration).Value.Nanoseconds()}
+	},
+	"abs": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("abs", args, 0); err != nil {
+			return err
+		}
+		return &Duration{Value: receiver.(*Duration).Value.Abs()}
+	},
+	"round":    durationRounding("round", time.Duration.Round),
+	"truncate": durationRounding("truncate", time.Duration.Truncate),
+}
+
+func numberArgument(name string, arg Object) (float64, *Error) {
+	switch arg := arg.(type) {
+	case *Integer:
+		return float64(arg.Value), nil
+	case *Float:
+		return arg.Value, nil
+	default:
+		return 0, NewError("argument to `%s` must be INTEGER or FLOAT, got %s", name, arg.Type())
+	}
+}
+
+func sequenceArgument(name string, arg Object) ([]Object, *Error) {
+	switch arg := arg.(type) {
+	case *Array:
+		return arg.Elements, nil
+	case *Tuple:
+		return arg.Elements, nil
+	default:
+		return nil, NewError("argument to `%s` must be ARRAY or TUPLE, got %s", name, arg.Type())
+	}
+}
+
+// random methods draw from the receiver's generator. Collections are never
+// modified: shuffle and sample return new arrays.
+var randomMethods = map[string]MethodFunction{
+	// int returns an integer between min and max, both included
+	"int": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("int", args, 2); err != nil {
+			return err
+		}
+		min, err := integerArgument("int", args[0])
+		if err != nil {
+			return err
+		}
+		max, err := integerArgument("int", args[1])
+		if err != nil {
+			return err
+		}
+		if min > max {
+			return NewError("empty range for `int`: %d > %d", min, max)
+		}
+
+		r := receiver.(*Random).Rand
+		span := uint64(max-min) + 1
+		if span == 0 {
+			return &Integer{Value: int64(r.Uint64())}
+		}
+		return &Integer{Value: min + int64(r.Uint64N(span))}
+	},
+	// float returns a float in [0, 1), or in [min, max)
+	"float": func(receiver Object, args ...Object) Object {
+		if len(args) != 0 && len(args) != 2 {
+			return NewError("wrong number of arguments to float: expected 0 or 2, got %d", len(args))
+		}
+		value := receiver.(*Random).Rand.Float64()
+		if len(args) == 0 {
+			return &Float{Value: value}
+		}
+		min, err := numberArgument("float", args[0])
+		if err != nil {
+			return err
+		}
+		max, err := numberArgument("float", args[1])
+		if err != nil {
+			return err
+		}
+		if min > max {
+			return NewError("empty range for `float`: %s > %s", args[0].Inspect(), args[1].Inspect())
+		}
+		return &Float{Value: min + (max-min)*value}
+	},
+	"choice": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("choice", args, 1); err != nil {
+			return err
+		}
+		elements, err := sequenceArgument("choice", args[0])
+		if err != nil {
+			return err
+		}
+		if len(elements) == 0 {
+			return NewError("`choice` from an empty sequence")
+		}
+		return elements[receiver.(*Random).Rand.IntN(len(elements))]
+	},
+	"shuffle": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("shuffle", args, 1); err != nil {
+			return err
+		}
+		elements, err := sequenceArgument("shuffle", args[0])
+		if err != nil {
+			return err
+		}
+		shuffled := append([]Object{}, elements...)
+		receiver.(*Random).Rand.Shuffle(len(shuffled), func(i, j int) {
+			shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+		})
+		return &Array{Elements: shuffled}
+	},
+	// sample picks count distinct elements, in the order they were drawn
+	"sample": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("sample", args, 2); err != nil {
+			return err
+		}
+		elements, err := sequenceArgument("sample", args[0])
+		if err != nil {
+			return err
+		}
+		count, err := integerArgument("sample", args[1])
+		if err != nil {
+			return err
+		}
+		if count < 0 || count > int64(len(elements)) {
+			return NewError("sample larger than the population or negative: %d of %d", count, len(elements))
+		}
+
+		pool := append([]Object{}, elements...)
+		r := receiver.(*Random).Rand
+		for i := 0; i < int(count); i++ {
+			j := i + r.IntN(len(pool)-i)
+			pool[i], pool[j] = pool[j], pool[i]
+		}
+		return &Array{Elements: pool[:count]}
+	},
+	// weighted picks an element with a probability proportional to its weight
+	"weighted": func(receiver Object, args ...Object) Object {
+		if err := wrongArguments("weighted", args, 2); err != nil {
+			return err
+		}
+		elements, err := sequenceArgument("weighted", args[0])
+		if err != nil {
+			return err
+		}
+		weightObjects, err := sequenceArgument("weighted", args[1])
+		if err != nil {
+			return err
+		}
+		if len(elements) != len(weightObjects) {
+			return NewError("`weighted` needs a weight for every element: %d elements, %d weights", len(elements), len(weightObjects))
+		}
+
+		weights := make([]float64, len(weightObjects))
+		total := 0.0
+		for i, weightObject := range weightObjects {
+			weight, err := numberArgument("weighted", weightObject)
+			if err != nil {
+				return err
+			}
+			if weight < 0 || math.IsNaN(weight) || math.IsInf(weight, 0) {
+				return NewError("weights of `weighted` must be finite and not negative, got %s", weightObject.Inspect())
+			}
+			weights[i] = weight
+			total += weight
+		}
+		if total == 0 {
+			return NewError("weights of `weighted` must not all be zero")
+		}
+
+		target := receiver.(*Random).Rand.Float64() * total
+		for i, weight := range weights {
+			if target < weight {
+				return elements[i]
+			}
+			target -= weight
+		}
+		// rounding can leave target at the total; the last weighted element takes it
+		for i := len(weights) - 1; ; i-- {
+			if weights[i] > 0 {
+				return elements[i]
+			}
+		}
+	},
+	// normal draws from a normal distribution, the standard one by default
+	"normal": func(receiver Object, args ...Object) Object {
+		if len(args) != 0 && len(args) != 2 {
+			return NewError("wrong number of arguments to normal: expected 0 or 2, got %d", len(args))
+		}
+		mean, stddev := 0.0, 1.0
+		if len(args) == 2 {
+			var err *Error
+			if mean, err = numberArgument("normal", args[0]); err != nil {
+				return err
+			}
+			if stddev, err = numberArgument("normal", args[1]); err != nil {
+				return err
+			}
+			if stddev < 0 {
+				return NewError("standard deviation of `normal` must not be negative, got %s", args[1].Inspect())
+			}
+		}
+		return &Float{Value: mean + stddev*receiver.(*Random).Rand.NormFloat64()}
+	},
+}
@@ -3,9 +3,16 @@ package object
 import (
 	"bytes"
 	"charm/ast"
+	"encoding/binary"
 	"fmt"
 	"strings"
+	"hash"
 	"hash/fnv"
+	"math"
+	"math/rand/v2"
+	"regexp"
+	"strconv"
+	"time"
 )
 
 type ObjectType string
@@ -24,8 +31,32 @@ const (
 	ARRAY_OBJ        = "ARRAY"
 	HASHMAP_OBJ      = "HASHMAP"
 	PAIR_OBJ         = "PAIR"
+	METHOD_OBJ       = "METHOD"
+	STRUCT_OBJ       = "STRUCT"
+	ENUM_OBJ         = "ENUM"
+	VARIANT_OBJ      = "VARIANT"
+	SET_OBJ          = "SET"
+	TUPLE_OBJ        = "TUPLE"
+	MODULE_OBJ       = "MODULE"
+	REGEX_OBJ        = "REGEX"
+	DATETIME_OBJ     = "DATETIME"
+	DURATION_OBJ     = "DURATION"
+	RANDOM_OBJ       = "RANDOM"
 )
 
+var (
+	TRUE  = &Boolean{Value: true}
+	FALSE = &Boolean{Value: false}
+	NULL  = &Null{}
+)
+
+func NativeBool(value bool) *Boolean {
+	if value {
+		return TRUE
+	}
+	return FALSE
+}
+
 type Object interface {
 	Type() ObjectType
 	Inspect() string
@@ -48,7 +79,22 @@ func (f *Float) Type() ObjectType {
 	return FLOAT_OBJ
 }
 func (f *Float) Inspect() string {
-	return fmt.Sprintf("%f", f.Value)
+	return FormatFloat(f.Value)
+}
+
+// FormatFloat is the shortest text that reads back as value. Whole numbers
+// keep a ".0" to tell them from integers, and very large or small magnitudes
+// use an exponent.
+func FormatFloat(value float64) string {
+	magnitude := math.Abs(value)
+	if magnitude != 0 && (magnitude < 1e-4 || magnitude >= 1e16) && !math.IsInf(value, 0) {
+		return strconv.FormatFloat(value, 'e', -1, 64)
+	}
+	text := strconv.FormatFloat(value, 'f', -1, 64)
+	if strings.ContainsAny(text, ".IN") {
+		return text
+	}
+	return text + ".0"
 }
 
 type String struct {
@@ -92,6 +138,10 @@ func (rv *ReturnValue) Inspect() string {
 
 type Error struct {
 	Message string
+	// Exit marks the error returned by the exit builtin, which unwinds the
+	// program like an error and ends it with status Code
+	Exit bool
+	Code int
 }
 
 func (e *Error) Type() ObjectType {
@@ -101,8 +151,13 @@ func (e *Error) Inspect() string {
 	return "ERROR:" + e.Message
 }
 
+func NewError(format string, a ...any) *Error {
+	return &Error{Message: fmt.Sprintf(format, a...)}
+}
+
 type Function struct {
-	Parameters []*ast.Identifier
+	Name       string
+	Parameters []*ast.Parameter
 	Body       *ast.BlockStatement
 	Env        *Environment
 }
@@ -128,9 +183,212 @@ func (f *Function) Inspect() string {
 	return out.String()
 }
 
+// Signature describes how the function is called, e.g. "add(a, b = 2, ...rest)".
+// Anonymous functions are shown as "func(...)".
+func (f *Function) Signature() string {
+	params := []string{}
+	for _, p := range f.Parameters {
+		params = append(params, p.String())
+	}
+
+	name := f.Name
+	if name == "" {
+		name = "func"
+	}
+
+	return name + "(" + strings.Join(params, ", ") + ")"
+}
+
+// StructType is a user-defined type declared with "struct". Calling it
+// constructs an Instance.
+type StructType struct {
+	Name    string
+	Fields  []*ast.Parameter
+	Methods map[string]*Function
+	Env     *Environment
+}
+
+func (st *StructType) Type() ObjectType {
+	return STRUCT_OBJ
+}
+func (st *StructType) Inspect() string {
+	return "struct " + st.Name
+}
+
+// Instance is a value of a StructType. Its object type is the struct's name.
+type Instance struct {
+	Struct *StructType
+	Fields map[string]Object
+}
+
+func (i *Instance) Type() ObjectType {
+	return ObjectType(i.Struct.Name)
+}
+func (i *Instance) Inspect() string {
+	fields := []string{}
+	for _, field := range i.Struct.Fields {
+		fields = append(fields, field.Name.Value+": "+i.Fields[field.Name.Value].Inspect())
+	}
+
+	return i.Struct.Name + "(" + strings.Join(fields, ", ") + ")"
+}
+
+// EnumType is a type declared with "enum", made of named variants
+type EnumType struct {
+	Name     string
+	Variants []*EnumVariant
+}
+
+func (et *EnumType) Type() ObjectType {
+	return ENUM_OBJ
+}
+func (et *EnumType) Inspect() string {
+	return "enum " + et.Name
+}
+
+func (et *EnumType) Variant(name string) (*EnumVariant, bool) {
+	for _, variant := range et.Variants {
+		if variant.Name == name {
+			return variant, true
+		}
+	}
+	return nil, false
+}
+
+// EnumVariant is a variant carrying a payload, e.g. "Result.Ok". Calling it
+// constructs an EnumValue. Variants without a payload have a single Value.
+type EnumVariant struct {
+	Enum   *EnumType
+	Name   string
+	Fields []string
+	Value  *EnumValue
+}
+
+func (ev *EnumVariant) Type() ObjectType {
+	return VARIANT_OBJ
+}
+func (ev *EnumVariant) Inspect() string {
+	return ev.Enum.Name + "." + ev.Name + "(" + strings.Join(ev.Fields, ", ") + ")"
+}
+
+// EnumValue is a value of an enum. Its object type is the enum's name.
+type EnumValue struct {
+	Variant *EnumVariant
+	Payload []Object
+}
+
+func (ev *EnumValue) Type() ObjectType {
+	return ObjectType(ev.Variant.Enum.Name)
+}
+func (ev *EnumValue) Inspect() string {
+	name := ev.Variant.Enum.Name + "." + ev.Variant.Name
+	if ev.Variant.Fields == nil {
+		return name
+	}
+
+	payload := []string{}
+	for _, value := range ev.Payload {
+		payload = append(payload, value.Inspect())
+	}
+
+	return name + "(" + strings.Join(payload, ", ") + ")"
+}
+
+// Field returns the payload value of the named field
+func (ev *EnumValue) Field(name string) (Object, bool) {
+	for i, field := range ev.Variant.Fields {
+		if field == name {
+			return ev.Payload[i], true
+		}
+	}
+	return nil, false
+}
+
+// Module is an imported file. Its exports are the exported bindings of Env.
+type Module struct {
+	Name string
+	Path string
+	Env  *Environment
+}
+
+func (m *Module) Type() ObjectType {
+	return MODULE_OBJ
+}
+func (m *Module) Inspect() string {
+	return "module " + m.Name
+}
+
+// Regex is a compiled regular expression, created by the `regex` builtin
+type Regex struct {
+	Regexp *regexp.Regexp
+}
+
+func (r *Regex) Type() ObjectType {
+	return REGEX_OBJ
+}
+func (r *Regex) Inspect() string {
+	return fmt.Sprintf("regex(%q)", r.Regexp.String())
+}
+
+// DateTime is an instant together with the time zone it is shown in
+type DateTime struct {
+	Time time.Time
+}
+
+func (dt *DateTime) Type() ObjectType {
+	return DATETIME_OBJ
+}
+func (dt *DateTime) Inspect() string {
+	return dt.Time.Format(time.RFC3339Nano)
+}
+
+// Random is a pseudo-random generator. Generators with the same seed produce
+// the same values.
+type Random struct {
+	Rand *rand.Rand
+}
+
+// NewRand returns a generator determined by seed
+func NewRand(seed int64) *rand.Rand {
+	return rand.New(rand.NewPCG(uint64(seed), 0))
+}
+
+func (r *Random) Type() ObjectType {
+	return RANDOM_OBJ
+}
+func (r *Random) Inspect() string {
+	return "random generator"
+}
+
+type Duration struct {
+	Value time.Duration
+}
+
+func (d *Duration) Type() ObjectType {
+	return DURATION_OBJ
+}
+func (d *Duration) Inspect() string {
+	return d.Value.String()
+}
+
 type BuiltinFunction func(args ...Object) Object
+
+// CallContext is passed to builtins that call back into the interpreter
+type CallContext struct {
+	Runtime *Runtime
+	// Keywords holds the keyword arguments of the call by name
+	Keywords map[string]Object
+	// Call applies a function, or any other callable value, to args
+	Call func(fn Object, args ...Object) Object
+}
+
+type ContextFunction func(ctx *CallContext, args ...Object) Object
+
 type Builtin struct {
 	Fn BuiltinFunction
+	// ContextFn is called instead of Fn when set. Unlike Fn it accepts
+	// keyword arguments.
+	ContextFn ContextFunction
 }
 
 func (b *Builtin) Type() ObjectType {
@@ -173,9 +431,17 @@ func (p *Pair) Inspect() string {
 	return p.Key.Inspect() + ": " + p.Value.Inspect()
 }
 
+// HashMap keeps its pairs in insertion order, so hashmaps print and iterate
+// predictably. Writes must go through Put and Delete to maintain the order.
 type HashMap struct {
-	Map map[uint64]Pair
+	Map   map[uint64]Pair
+	order []uint64
+}
+
+func NewHashMap() *HashMap {
+	return &HashMap{Map: map[uint64]Pair{}}
 }
+
 func (hm *HashMap) Type() ObjectType {
 	return HASHMAP_OBJ
 }
@@ -184,7 +450,7 @@ func (hm *HashMap) Inspect() string {
 
 	pairs := []string{}
 
-	for _, pair := range hm.Map {
+	for _, pair := range hm.Pairs() {
 		pairs = append(pairs, pair.Inspect())
 	}
 
@@ -195,6 +461,119 @@ func (hm *HashMap) Inspect() string {
 	return out.String()
 }
 
+// Put sets the value of key. A new key goes last, an existing key keeps its place.
+func (hm *HashMap) Put(key Hashable, value Object) {
+	hashCode := key.HashCode()
+	if _, ok := hm.Map[hashCode]; !ok {
+		hm.order = append(hm.order, hashCode)
+	}
+	hm.Map[hashCode] = Pair{Key: key, Value: value}
+}
+
+func (hm *HashMap) Delete(key Hashable) {
+	hashCode := key.HashCode()
+	if _, ok := hm.Map[hashCode]; !ok {
+		return
+	}
+
+	delete(hm.Map, hashCode)
+	for i, code := range hm.order {
+		if code == hashCode {
+			hm.order = append(hm.order[:i:i], hm.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// Pairs returns the pairs in insertion order
+func (hm *HashMap) Pairs() []Pair {
+	pairs := []Pair{}
+	for _, hashCode := range hm.order {
+		pairs = append(pairs, hm.Map[hashCode])
+	}
+	return pairs
+}
+
+// Set is an unordered collection of distinct hashable values. Elements are
+// kept in insertion order so sets print predictably.
+type Set struct {
+	Elements map[uint64]Hashable
+	order    []uint64
+}
+
+func NewSet() *Set {
+	return &Set{Elements: map[uint64]Hashable{}}
+}
+
+func (s *Set) Type() ObjectType {
+	return SET_OBJ
+}
+func (s *Set) Inspect() string {
+	elements := []string{}
+	for _, element := range s.Values() {
+		elements = append(elements, element.Inspect())
+	}
+
+	return "{" + strings.Join(elements, ", ") + "}"
+}
+
+func (s *Set) Add(element Hashable) {
+	hashCode := element.HashCode()
+	if _, ok := s.Elements[hashCode]; !ok {
+		s.order = append(s.order, hashCode)
+	}
+	s.Elements[hashCode] = element
+}
+
+func (s *Set) Remove(element Hashable) {
+	hashCode := element.HashCode()
+	if _, ok := s.Elements[hashCode]; !ok {
+		return
+	}
+
+	delete(s.Elements, hashCode)
+	for i, code := range s.order {
+		if code == hashCode {
+			s.order = append(s.order[:i:i], s.order[i+1:]...)
+			break
+		}
+	}
+}
+
+func (s *Set) Has(element Hashable) bool {
+	_, ok := s.Elements[element.HashCode()]
+	return ok
+}
+
+// Values returns the elements in insertion order
+func (s *Set) Values() []Hashable {
+	values := []Hashable{}
+	for _, hashCode := range s.order {
+		values = append(values, s.Elements[hashCode])
+	}
+	return values
+}
+
+// Tuple is an immutable sequence. Tuples of hashable values can be hashmap keys.
+type Tuple struct {
+	Elements []Object
+}
+
+func (t *Tuple) Type() ObjectType {
+	return TUPLE_OBJ
+}
+func (t *Tuple) Inspect() string {
+	elements := []string{}
+	for _, element := range t.Elements {
+		elements = append(elements, element.Inspect())
+	}
+
+	if len(elements) == 1 {
+		return "(" + elements[0] + ",)"
+	}
+	return "(" + strings.Join(elements, ", ") + ")"
+}
+
 // for debugging and testing purposes, Object interface is being nested.
 // necessary for viewing the value through Inspect()
 // TODO: investigate the performance impact of nested interfaces
@@ -202,17 +581,81 @@ type Hashable interface {
     Object
 	HashCode() uint64
 }
+
+// AsHashable returns obj as a hash key. Tuples and enum values are only
+// usable as keys when all their elements are, since a mutable element could
+// change after the key is stored.
+func AsHashable(obj Object) (Hashable, bool) {
+	hashable, ok := obj.(Hashable)
+	if !ok {
+		return nil, false
+	}
+	switch obj := obj.(type) {
+	case *Tuple:
+		return hashable, allHashable(obj.Elements)
+	case *EnumValue:
+		return hashable, allHashable(obj.Payload)
+	}
+	return hashable, true
+}
+
+func allHashable(values []Object) bool {
+	for _, value := range values {
+		if _, ok := AsHashable(value); !ok {
+			return false
+		}
+	}
+	return true
+}
 func (i *Integer) HashCode() uint64 {
 	return uint64(i.Value)
 }
+// booleans are hashed by name so that true and 1 are distinct keys
 func (b *Boolean) HashCode() uint64 {
-	if b.Value {
-		return 1
-	}
-	return 0
+	h := fnv.New64()
+	h.Write([]byte("bool:" + b.Inspect()))
+	return h.Sum64()
 }
 func (s *String) HashCode() uint64 {
 	h := fnv.New64()
 	h.Write([]byte(s.Value))
 	return h.Sum64()
 }
+
+// datetimes are equal when they are the same instant, whatever their zone
+func (dt *DateTime) HashCode() uint64 {
+	h := fnv.New64()
+	h.Write([]byte("datetime"))
+	h.Write(binary.LittleEndian.AppendUint64(nil, uint64(dt.Time.UnixNano())))
+	return h.Sum64()
+}
+func (d *Duration) HashCode() uint64 {
+	h := fnv.New64()
+	h.Write([]byte("duration"))
+	h.Write(binary.LittleEndian.AppendUint64(nil, uint64(d.Value)))
+	return h.Sum64()
+}
+
+func (ev *EnumValue) HashCode() uint64 {
+	h := fnv.New64()
+	h.Write([]byte(ev.Variant.Enum.Name + "." + ev.Variant.Name))
+	writeHashCodes(h, ev.Payload)
+	return h.Sum64()
+}
+func (t *Tuple) HashCode() uint64 {
+	h := fnv.New64()
+	h.Write([]byte("tuple"))
+	writeHashCodes(h, t.Elements)
+	return h.Sum64()
+}
+
+// values that are not hashable contribute their Inspect() output
+func writeHashCodes(h hash.Hash64, values []Object) {
+	for _, value := range values {
+		if hashable, ok := value.(Hashable); ok {
+			h.Write(binary.LittleEndian.AppendUint64(nil, hashable.HashCode()))
+		} else {
+			h.Write([]byte(value.Inspect()))
+		}
+	}
+}
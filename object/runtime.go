@@ -0,0 +1,236 @@
+package object
+
+import (
+    "bufio"
+    "container/list"
+    "fmt"
+    "io"
+    "math/rand/v2"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+)
+
+// Runtime holds the settings of an interpreter. Every environment of a
+// program shares the runtime of its root environment.
+type Runtime struct {
+    // Strict makes out of range indexing an error instead of null
+    Strict bool
+    // SearchPath lists the directories imports are looked up in after the
+    // directory of the importing module
+    SearchPath []string
+    // In and Out are the standard input and output of scripts, and Err is
+    // where uncaught errors are reported
+    In io.Reader
+    Out io.Writer
+    Err io.Writer
+    // Files limits what scripts may do with the file system
+    Files FilePolicy
+    // Args are the command-line arguments after the script name
+    Args []string
+    // Process takes access to the process and its environment away
+    Process ProcessPolicy
+    // Clock is the time source of the time module
+    Clock Clock
+    // Random is the global generator of the random module. It is seeded
+    // randomly unless replaced by NewRand(seed) for reproducible runs.
+    Random *rand.Rand
+
+    modules map[string]*Module
+    loading []string
+    input *bufio.Reader
+    patterns map[string]*list.Element
+    patternOrder *list.List
+}
+
+func NewRuntime() *Runtime {
+    return &Runtime{modules: map[string]*Module{}, In: os.Stdin, Out: os.Stdout, Err: os.Stderr, Clock: SystemClock,
+        Random: rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))}
+}
+
+// ReadLine reads a line from In without its line ending. io.EOF is only
+// returned when nothing was read.
+func (r *Runtime) ReadLine() (string, error) {
+    if r.input == nil {
+        r.input = bufio.NewReader(r.In)
+    }
+
+    line, err := r.input.ReadString('\n')
+    if err == io.EOF && line != "" {
+        err = nil
+    }
+    return strings.TrimRight(line, "\r\n"), err
+}
+
+// Clock tells scripts the time. Embedders replace it with a fake clock to
+// make scripts using the time module deterministic.
+type Clock interface {
+    Now() time.Time
+    Sleep(d time.Duration)
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+func (systemClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+// SystemClock is the real time, and the default clock of a runtime
+var SystemClock Clock = systemClock{}
+
+// ProcessPolicy is set by embedders to take capabilities away from scripts.
+// The zero value allows everything.
+type ProcessPolicy struct {
+    // NoArgs hides the command-line arguments, NoEnv forbids reading and
+    // setting environment variables, NoExit forbids ending the process and
+    // NoRun forbids starting subprocesses
+    NoArgs bool
+    NoEnv bool
+    NoExit bool
+    NoRun bool
+}
+
+// FilePolicy is set by embedders to confine untrusted scripts. The zero value
+// allows everything. Subprocesses started by run are not confined, so a
+// restrictive policy should come with ProcessPolicy.NoRun.
+type FilePolicy struct {
+    // NoRead and NoWrite forbid reading, and writing or removing, any file
+    NoRead bool
+    NoWrite bool
+    // ReadRoots confines reading to files under these directories, and
+    // WriteRoots confines writing and removing. Empty means anywhere.
+    ReadRoots []string
+    WriteRoots []string
+}
+
+// CheckRead returns an error unless the policy allows reading path
+func (p *FilePolicy) CheckRead(path string) error {
+    if p.NoRead {
+        return fmt.Errorf("permission denied: reading files is disabled")
+    }
+    if !underRoots(path, p.ReadRoots) {
+        return fmt.Errorf("permission denied: %s is outside the readable directories", path)
+    }
+    return nil
+}
+
+// CheckWrite returns an error unless the policy allows writing or removing path
+func (p *FilePolicy) CheckWrite(path string) error {
+    if p.NoWrite {
+        return fmt.Errorf("permission denied: writing files is disabled")
+    }
+    if !underRoots(path, p.WriteRoots) {
+        return fmt.Errorf("permission denied: %s is outside the writable directories", path)
+    }
+    return nil
+}
+
+func underRoots(path string, roots []string) bool {
+    if len(roots) == 0 {
+        return true
+    }
+
+    resolved := resolvePath(path)
+    for _, root := range roots {
+        rel, err := filepath.Rel(resolvePath(root), resolved)
+        if err == nil && rel != ".." && !strings.HasPrefix(rel, ".." + string(filepath.Separator)) {
+            return true
+        }
+    }
+    return false
+}
+
+// resolvePath makes path absolute and follows symbolic links, so that a link
+// cannot lead out of a root. A path that does not exist yet is resolved
+// through its parent directory.
+func resolvePath(path string) string {
+    abs, err := filepath.Abs(path)
+    if err != nil {
+        return filepath.Clean(path)
+    }
+    if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+        return resolved
+    }
+    if dir, err := filepath.EvalSymlinks(filepath.Dir(abs)); err == nil {
+        return filepath.Join(dir, filepath.Base(abs))
+    }
+    return abs
+}
+
+// Module returns the module loaded from path, if it was loaded before
+func (r *Runtime) Module(path string) (*Module, bool) {
+    module, ok := r.modules[path]
+    return module, ok
+}
+
+func (r *Runtime) AddModule(module *Module) {
+    if r.modules == nil {
+        r.modules = map[string]*Module{}
+    }
+    r.modules[module.Path] = module
+}
+
+// MaxCachedPatterns bounds the compiled regexes a runtime keeps. When it is
+// full, the least recently used one is dropped.
+const MaxCachedPatterns = 256
+
+type cachedPattern struct {
+    pattern string
+    regex *Regex
+}
+
+// CachedRegex returns the regex compiled earlier for pattern, if it is still
+// cached
+func (r *Runtime) CachedRegex(pattern string) (*Regex, bool) {
+    element, ok := r.patterns[pattern]
+    if !ok {
+        return nil, false
+    }
+    r.patternOrder.MoveToFront(element)
+    return element.Value.(*cachedPattern).regex, true
+}
+
+// CacheRegex remembers the regex compiled for pattern. Regexes are immutable,
+// so every script of the runtime can share them.
+func (r *Runtime) CacheRegex(pattern string, regex *Regex) {
+    if r.patterns == nil {
+        r.patterns = map[string]*list.Element{}
+        r.patternOrder = list.New()
+    }
+    if element, ok := r.patterns[pattern]; ok {
+        element.Value.(*cachedPattern).regex = regex
+        r.patternOrder.MoveToFront(element)
+        return
+    }
+
+    r.patterns[pattern] = r.patternOrder.PushFront(&cachedPattern{pattern: pattern, regex: regex})
+    if r.patternOrder.Len() > MaxCachedPatterns {
+        oldest := r.patternOrder.Back()
+        r.patternOrder.Remove(oldest)
+        delete(r.patterns, oldest.Value.(*cachedPattern).pattern)
+    }
+}
+
+// StartLoading records that the module at path is being evaluated. If it
+// already is, the import is circular and the chain of imports leading back to
+// path is returned instead.
+func (r *Runtime) StartLoading(path string) ([]string, bool) {
+    for i, loading := range r.loading {
+        if loading == path {
+            chain := append([]string{}, r.loading[i:]...)
+            return append(chain, path), false
+        }
+    }
+
+    r.loading = append(r.loading, path)
+    return nil, true
+}
+
+func (r *Runtime) FinishLoading(path string) {
+    for i := len(r.loading) - 1; i >= 0; i-- {
+        if r.loading[i] == path {
+            r.loading = append(r.loading[:i], r.loading[i+1:]...)
+            return
+        }
+    }
+}
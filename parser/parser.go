@@ -16,6 +16,8 @@ type (
 const (
     _ int = iota
     LOWEST
+    PIPE // x |> f()
+    COALESCE // a ?? b
     EQUALS // ==
     LESSGREATER // > or <
     SUM // + or -
@@ -26,18 +28,25 @@ const (
 )
 
 var precedences = map[token.TokenType]int {
+    token.PIPE: PIPE,
+    token.NULLISH: COALESCE,
     token.EQ: EQUALS,
     token.NOT_EQ: EQUALS,
     token.LT: LESSGREATER,
     token.LT_EQ: LESSGREATER,
     token.GT: LESSGREATER,
     token.GT_EQ: LESSGREATER,
+    token.IN: LESSGREATER,
+    token.NOT: LESSGREATER,
     token.PLUS: SUM,
     token.MINUS: SUM,
     token.SLASH: PRODUCT,
     token.ASTERISK: PRODUCT,
     token.LPAREN: CALL,
     token.LBRACKET: INDEX,
+    token.DOT: INDEX,
+    token.OPTIONAL_DOT: INDEX,
+    token.OPTIONAL_BRACKET: INDEX,
 }
 
 type Parser struct {
@@ -62,7 +71,7 @@ func New(lexer *lexer.Lexer) *Parser {
     parser.nextToken()
 
     parser.prefixParseFns = make(map[token.TokenType]prefixParseFn)
-    parser.registerPrefix(token.IDENT, parser.parseIdentifier)
+    parser.registerPrefix(token.IDENT, parser.parseIdentifierOrLambda)
     parser.registerPrefix(token.INT, parser.parseIntegerLiteral)
     parser.registerPrefix(token.FLOAT, parser.parseFloatLiteral)
     parser.registerPrefix(token.BANG, parser.parsePrefixExpression)
@@ -70,10 +79,13 @@ func New(lexer *lexer.Lexer) *Parser {
     parser.registerPrefix(token.TRUE, parser.parseBooleanLiteral)
     parser.registerPrefix(token.FALSE, parser.parseBooleanLiteral)
     parser.registerPrefix(token.STRING, parser.parseStringLiteral)
+    parser.registerPrefix(token.TEMPLATE_HEAD, parser.parseTemplateLiteral)
+    parser.registerPrefix(token.NULL, parser.parseNullLiteral)
+    parser.registerPrefix(token.MATCH, parser.parseMatchExpression)
     parser.registerPrefix(token.FUNCTION, parser.parseFunctionLiteral)
     parser.registerPrefix(token.LBRACKET, parser.parseArrayLiteral)
     parser.registerPrefix(token.LBRACE, parser.parseHashMapLiteral)
-    parser.registerPrefix(token.LPAREN, parser.parseGroupedExpression)
+    parser.registerPrefix(token.LPAREN, parser.parseGroupedExpressionOrLambda)
 
     parser.infixParseFns = make(map[token.TokenType]infixParseFn)
     parser.registerInfix(token.PLUS, parser.parseInfixExpression)
@@ -88,6 +100,13 @@ func New(lexer *lexer.Lexer) *Parser {
     parser.registerInfix(token.GT_EQ, parser.parseInfixExpression)
     parser.registerInfix(token.LPAREN, parser.parseCallExpression)
     parser.registerInfix(token.LBRACKET, parser.parseIndexExpression)
+    parser.registerInfix(token.PIPE, parser.parsePipeExpression)
+    parser.registerInfix(token.DOT, parser.parseMemberExpression)
+    parser.registerInfix(token.OPTIONAL_DOT, parser.parseMemberExpression)
+    parser.registerInfix(token.OPTIONAL_BRACKET, parser.parseIndexExpression)
+    parser.registerInfix(token.NULLISH, parser.parseInfixExpression)
+    parser.registerInfix(token.IN, parser.parseInfixExpression)
+    parser.registerInfix(token.NOT, parser.parseNotInExpression)
 
     return parser
 }
@@ -110,6 +129,31 @@ func (parser *Parser) GetErrors() []string {
     return parser.errors
 }
 
+// parserState is a snapshot of the parser used to backtrack when a construct
+// can only be told apart by what follows it, such as "(a, b) => a + b" versus "(a + b)"
+type parserState struct {
+    lexer lexer.Lexer
+    currToken token.Token
+    peekToken token.Token
+    errors int
+}
+
+func (parser *Parser) save() parserState {
+    return parserState{
+        lexer: parser.lexer.Copy(),
+        currToken: parser.currToken,
+        peekToken: parser.peekToken,
+        errors: len(parser.errors),
+    }
+}
+
+func (parser *Parser) restore(state parserState) {
+    *parser.lexer = state.lexer.Copy()
+    parser.currToken = state.currToken
+    parser.peekToken = state.peekToken
+    parser.errors = parser.errors[:state.errors]
+}
+
 func (parser *Parser) nextToken() {
     parser.currToken = parser.peekToken
     parser.peekToken = parser.lexer.NextToken()
@@ -137,6 +181,8 @@ func (parser *Parser) parseStatement() ast.Statement {
     switch {
         case currToken == token.IDENT && parser.peekToken.Type == token.ASSIGN:
             return parser.parseAssignmentStatement()
+        case currToken == token.LET || currToken == token.CONST:
+            return parser.parseLetStatement()
         case currToken == token.RETURN:
             return parser.parseReturnStatement()
         case currToken == token.IF:
@@ -145,6 +191,16 @@ func (parser *Parser) parseStatement() ast.Statement {
             return parser.parseWhileStatement()
         case currToken == token.FUNCTION && parser.peekToken.Type == token.IDENT:
             return parser.parseFunctionStatement()
+        case currToken == token.STRUCT:
+            return parser.parseStructStatement()
+        case currToken == token.ENUM:
+            return parser.parseEnumStatement()
+        case currToken == token.IMPORT:
+            return parser.parseImportStatement()
+        case currToken == token.EXPORT:
+            return parser.parseExportStatement()
+        case currToken == token.LBRACKET || currToken == token.LBRACE:
+            return parser.parseDestructuringStatement()
         default:
             return parser.parseExpressionStatement()
     }
@@ -169,6 +225,304 @@ func (parser *Parser) parseAssignmentStatement() *ast.AssignmentStatement {
     return stmt
 }
 
+func (parser *Parser) parseLetStatement() *ast.LetStatement {
+    stmt := &ast.LetStatement{Token: parser.currToken}
+
+    if parser.peekToken.Type == token.LBRACKET || parser.peekToken.Type == token.LBRACE {
+        parser.nextToken()
+        stmt.Pattern = parser.parsePattern()
+        if stmt.Pattern == nil {
+            return nil
+        }
+
+        if parser.peekToken.Type != token.ASSIGN {
+            msg := fmt.Sprintf("missing value in destructuring declaration: %s", stmt.Pattern.String())
+            parser.errors = append(parser.errors, msg)
+            return nil
+        }
+    } else {
+        if !parser.expectPeek(token.IDENT) {
+            return nil
+        }
+
+        stmt.Identifier = &ast.Identifier{Token: parser.currToken, Value: parser.currToken.Literal}
+    }
+
+    // "let x;" declares x without a value, a constant always needs one
+    if parser.peekToken.Type == token.ASSIGN {
+        parser.nextToken()
+        parser.nextToken()
+        stmt.Value = parser.parseExpression(LOWEST)
+    } else if stmt.IsConstant() {
+        msg := fmt.Sprintf("missing value in const declaration: %s", stmt.Identifier.Value)
+        parser.errors = append(parser.errors, msg)
+        return nil
+    }
+
+    if !parser.expectPeek(token.SEMICOLON) {
+        return nil
+    }
+
+    return stmt
+}
+
+// a statement starting with "[" or "{" is a destructuring assignment if it is a
+// valid pattern followed by "=", otherwise it is an expression statement
+func (parser *Parser) parseDestructuringStatement() ast.Statement {
+    state := parser.save()
+
+    stmt := &ast.AssignmentStatement{Token: parser.currToken}
+    stmt.Pattern = parser.parsePattern()
+
+    if stmt.Pattern == nil || len(parser.errors) > state.errors || parser.peekToken.Type != token.ASSIGN {
+        parser.restore(state)
+        return parser.parseExpressionStatement()
+    }
+
+    parser.nextToken()
+    parser.nextToken()
+    stmt.Value = parser.parseExpression(LOWEST)
+
+    if !parser.expectPeek(token.SEMICOLON) {
+        return nil
+    }
+
+    return stmt
+}
+
+// parses the value of an assignment to a member or index, e.g. "p.x = 1;"
+func (parser *Parser) parseTargetAssignment(target ast.Expression) ast.Statement {
+    switch target.(type) {
+    case *ast.MemberExpression, *ast.IndexExpression:
+    default:
+        if target != nil {
+            parser.errors = append(parser.errors, fmt.Sprintf("cannot assign to %s", target.String()))
+        }
+        return nil
+    }
+
+    parser.nextToken()
+    stmt := &ast.AssignmentStatement{Token: parser.currToken, Target: target}
+
+    parser.nextToken()
+    stmt.Value = parser.parseExpression(LOWEST)
+
+    if !parser.expectPeek(token.SEMICOLON) {
+        return nil
+    }
+
+    return stmt
+}
+
+func (parser *Parser) parseStructStatement() ast.Statement {
+    stmt := &ast.StructStatement{Token: parser.currToken}
+
+    if !parser.expectPeek(token.IDENT) {
+        return nil
+    }
+    stmt.Name = &ast.Identifier{Token: parser.currToken, Value: parser.currToken.Literal}
+
+    if !parser.expectPeek(token.LBRACE) {
+        return nil
+    }
+
+    names := map[string]bool{}
+    for parser.peekToken.Type != token.RBRACE {
+        parser.nextToken()
+
+        var name string
+        switch {
+        case parser.currToken.Type == token.IDENT:
+            field := parser.parseStructField()
+            if field == nil {
+                return nil
+            }
+            name = field.Name.Value
+            stmt.Fields = append(stmt.Fields, field)
+        case parser.currToken.Type == token.FUNCTION && parser.peekToken.Type == token.IDENT:
+            method := parser.parseFunctionStatement()
+            if method == nil {
+                return nil
+            }
+            name = method.Identifier.Value
+            stmt.Methods = append(stmt.Methods, method)
+        default:
+            msg := fmt.Sprintf("expected field or method in struct %s, got %s instead",
+                stmt.Name.Value, parser.currToken.Type)
+            parser.errors = append(parser.errors, msg)
+            return nil
+        }
+
+        if names[name] {
+            parser.errors = append(parser.errors, fmt.Sprintf("duplicate member in struct %s: %s", stmt.Name.Value, name))
+            return nil
+        }
+        names[name] = true
+    }
+    parser.nextToken()
+
+    return stmt
+}
+
+func (parser *Parser) parseEnumStatement() ast.Statement {
+    stmt := &ast.EnumStatement{Token: parser.currToken}
+
+    if !parser.expectPeek(token.IDENT) {
+        return nil
+    }
+    stmt.Name = &ast.Identifier{Token: parser.currToken, Value: parser.currToken.Literal}
+
+    if !parser.expectPeek(token.LBRACE) {
+        return nil
+    }
+
+    names := map[string]bool{}
+    for parser.peekToken.Type != token.RBRACE {
+        if !parser.expectPeek(token.IDENT) {
+            return nil
+        }
+
+        variant := &ast.EnumVariant{Name: &ast.Identifier{Token: parser.currToken, Value: parser.currToken.Literal}}
+        if names[variant.Name.Value] {
+            parser.errors = append(parser.errors, fmt.Sprintf("duplicate variant in enum %s: %s", stmt.Name.Value, variant.Name.Value))
+            return nil
+        }
+        names[variant.Name.Value] = true
+
+        if parser.peekToken.Type == token.LPAREN {
+            parser.nextToken()
+            variant.Fields = parser.parseEnumFields()
+            if variant.Fields == nil {
+                return nil
+            }
+        }
+        stmt.Variants = append(stmt.Variants, variant)
+
+        if parser.peekToken.Type != token.RBRACE && !parser.expectPeek(token.COMMA) {
+            return nil
+        }
+    }
+    parser.nextToken()
+
+    if len(stmt.Variants) == 0 {
+        parser.errors = append(parser.errors, fmt.Sprintf("enum %s has no variants", stmt.Name.Value))
+        return nil
+    }
+
+    return stmt
+}
+
+// parses the payload fields of a variant, e.g. "(value, message)". The result
+// is empty rather than nil for "()" so it still marks a payload variant.
+func (parser *Parser) parseEnumFields() []*ast.Identifier {
+    fields := []*ast.Identifier{}
+
+    for parser.peekToken.Type != token.RPAREN {
+        if !parser.expectPeek(token.IDENT) {
+            return nil
+        }
+        fields = append(fields, &ast.Identifier{Token: parser.currToken, Value: parser.currToken.Literal})
+
+        if parser.peekToken.Type != token.RPAREN && !parser.expectPeek(token.COMMA) {
+            return nil
+        }
+    }
+    parser.nextToken()
+
+    return fields
+}
+
+// parses a field declaration such as "y = 0;"
+func (parser *Parser) parseStructField() *ast.Parameter {
+    field := &ast.Parameter{Token: parser.currToken}
+    field.Name = &ast.Identifier{Token: parser.currToken, Value: parser.currToken.Literal}
+
+    if parser.peekToken.Type == token.ASSIGN {
+        parser.nextToken()
+        parser.nextToken()
+        field.Default = parser.parseExpression(LOWEST)
+    }
+
+    if !parser.expectPeek(token.SEMICOLON) {
+        return nil
+    }
+
+    return field
+}
+
+func (parser *Parser) parseImportStatement() ast.Statement {
+    stmt := &ast.ImportStatement{Token: parser.currToken}
+
+    if parser.peekToken.Type == token.LBRACE {
+        parser.nextToken()
+        stmt.Names = []*ast.Identifier{}
+
+        for parser.peekToken.Type != token.RBRACE {
+            if !parser.expectPeek(token.IDENT) {
+                return nil
+            }
+            stmt.Names = append(stmt.Names, &ast.Identifier{Token: parser.currToken, Value: parser.currToken.Literal})
+
+            if parser.peekToken.Type != token.RBRACE && !parser.expectPeek(token.COMMA) {
+                return nil
+            }
+        }
+        parser.nextToken()
+
+        if len(stmt.Names) == 0 {
+            parser.errors = append(parser.errors, "import list is empty")
+            return nil
+        }
+
+        // "from" is only a keyword here, so it stays usable as a name
+        if parser.peekToken.Type != token.IDENT || parser.peekToken.Literal != "from" {
+            msg := fmt.Sprintf("expected from after import list, got %s instead", parser.peekToken.Literal)
+            parser.errors = append(parser.errors, msg)
+            return nil
+        }
+        parser.nextToken()
+    }
+
+    if !parser.expectPeek(token.STRING) {
+        return nil
+    }
+    stmt.Path = &ast.StringLiteral{Token: parser.currToken, Value: parser.currToken.Literal}
+
+    if !parser.expectPeek(token.SEMICOLON) {
+        return nil
+    }
+
+    return stmt
+}
+
+func (parser *Parser) parseExportStatement() ast.Statement {
+    stmt := &ast.ExportStatement{Token: parser.currToken}
+    parser.nextToken()
+
+    switch {
+    case parser.currToken.Type == token.LET || parser.currToken.Type == token.CONST:
+        if declaration := parser.parseLetStatement(); declaration != nil {
+            stmt.Declaration = declaration
+        }
+    case parser.currToken.Type == token.FUNCTION && parser.peekToken.Type == token.IDENT:
+        if declaration := parser.parseFunctionStatement(); declaration != nil {
+            stmt.Declaration = declaration
+        }
+    case parser.currToken.Type == token.STRUCT:
+        stmt.Declaration = parser.parseStructStatement()
+    case parser.currToken.Type == token.ENUM:
+        stmt.Declaration = parser.parseEnumStatement()
+    default:
+        msg := fmt.Sprintf("expected declaration after export, got %s instead", parser.currToken.Type)
+        parser.errors = append(parser.errors, msg)
+    }
+
+    if stmt.Declaration == nil {
+        return nil
+    }
+    return stmt
+}
+
 func (parser *Parser) parseReturnStatement() *ast.ReturnStatement {
     stmt := &ast.ReturnStatement{Token: parser.currToken}
 
@@ -244,15 +598,24 @@ func (parser *Parser) parseFunctionStatement() *ast.FunctionStatement {
     return stmt
 }
 
-func (parser *Parser) parseExpressionStatement() *ast.ExpressionStatement {
+func (parser *Parser) parseExpressionStatement() ast.Statement {
     stmt := &ast.ExpressionStatement{Token: parser.currToken}
 
     stmt.Expression = parser.parseExpression(LOWEST)
 
+    if parser.peekToken.Type == token.ASSIGN {
+        return parser.parseTargetAssignment(stmt.Expression)
+    }
+
     // if parser.peekToken.Type == token.SEMICOLON {
     //     parser.nextToken()
     // }
 
+    // a match used as a statement ends with its closing brace, like if and while
+    if _, ok := stmt.Expression.(*ast.MatchExpression); ok && parser.peekToken.Type != token.SEMICOLON {
+        return stmt
+    }
+
     if !parser.expectPeek(token.SEMICOLON) {
         return nil
     }
@@ -394,6 +757,39 @@ func (parser *Parser) parseStringLiteral() ast.Expression {
     return &ast.StringLiteral{Token: parser.currToken, Value: parser.currToken.Literal}
 }
 
+func (parser *Parser) parseTemplateLiteral() ast.Expression {
+    template := &ast.TemplateLiteral{Token: parser.currToken}
+    template.Parts = append(template.Parts, parser.parseStringLiteral())
+
+    for parser.currToken.Type != token.TEMPLATE_TAIL {
+        if parser.peekToken.Type == token.TEMPLATE_MIDDLE || parser.peekToken.Type == token.TEMPLATE_TAIL {
+            parser.errors = append(parser.errors, "empty expression in template string")
+            return nil
+        }
+        parser.nextToken()
+        expression := parser.parseExpression(LOWEST)
+        if expression == nil {
+            return nil
+        }
+        template.Parts = append(template.Parts, expression)
+
+        if parser.peekToken.Type != token.TEMPLATE_MIDDLE && parser.peekToken.Type != token.TEMPLATE_TAIL {
+            msg := fmt.Sprintf("expected } to close template expression, got %s instead",
+                parser.peekToken.Type)
+            parser.errors = append(parser.errors, msg)
+            return nil
+        }
+        parser.nextToken()
+        template.Parts = append(template.Parts, parser.parseStringLiteral())
+    }
+
+    return template
+}
+
+func (parser *Parser) parseNullLiteral() ast.Expression {
+    return &ast.NullLiteral{Token: parser.currToken}
+}
+
 func (parser *Parser) parsePrefixExpression() ast.Expression {
     expression := &ast.PrefixExpression{
         Token: parser.currToken,
@@ -420,66 +816,232 @@ func (parser *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
     return expression
 }
 
+func (parser *Parser) parseNotInExpression(left ast.Expression) ast.Expression {
+    expression := &ast.InfixExpression{Token: parser.currToken, Operator: "not in", Left: left}
+
+    if !parser.expectPeek(token.IN) {
+        return nil
+    }
+
+    precedence := parser.currPrecedence()
+    parser.nextToken()
+    expression.Right = parser.parseExpression(precedence)
+
+    return expression
+}
+
+// parses "(x)" as x, and "()", "(x,)" or "(x, y)" as tuples
 func (parser *Parser) parseGroupedExpression() ast.Expression {
+    tuple := &ast.TupleLiteral{Token: parser.currToken, Elements: []ast.Expression{}}
+
+    if parser.peekToken.Type == token.RPAREN {
+        parser.nextToken()
+        return tuple
+    }
+
     parser.nextToken()
 
     exp := parser.parseExpression(LOWEST)
 
-    if !parser.expectPeek(token.RPAREN) {
-        return nil
+    if parser.peekToken.Type != token.COMMA {
+        if !parser.expectPeek(token.RPAREN) {
+            return nil
+        }
+        return exp
     }
 
-    return exp
-}
+    tuple.Elements = append(tuple.Elements, exp)
+    parser.nextToken()
 
-func (parser *Parser) parseFunctionLiteral() ast.Expression {
-    function := &ast.FunctionLiteral{Token: parser.currToken}
+    for parser.peekToken.Type != token.RPAREN {
+        parser.nextToken()
+        element := parser.parseExpression(LOWEST)
+        if element == nil {
+            return nil
+        }
+        tuple.Elements = append(tuple.Elements, element)
 
-    if !parser.expectPeek(token.LPAREN) {
-        return nil
+        if parser.peekToken.Type != token.RPAREN && !parser.expectPeek(token.COMMA) {
+            return nil
+        }
     }
+    parser.nextToken()
 
-    function.Parameters = parser.parseFunctionParameters()
+    return tuple
+}
 
-    if !parser.expectPeek(token.LBRACE) {
-        return nil
+func (parser *Parser) parseIdentifierOrLambda() ast.Expression {
+    if parser.peekToken.Type != token.ARROW {
+        return parser.parseIdentifier()
     }
 
-    function.Body = parser.parseBlockStatement()
+    lambda := &ast.LambdaLiteral{Token: parser.currToken}
+    identifier := &ast.Identifier{Token: parser.currToken, Value: parser.currToken.Literal}
+    lambda.Parameters = []*ast.Parameter{{Token: parser.currToken, Name: identifier}}
 
-    return function
+    return parser.parseLambdaBody(lambda)
 }
 
-func (parser *Parser) parseFunctionParameters() []*ast.Identifier {
-    parameters := []*ast.Identifier{}
+func (parser *Parser) parseGroupedExpressionOrLambda() ast.Expression {
+    state := parser.save()
 
-    if parser.peekToken.Type == token.RPAREN {
-        parser.nextToken()
-        return parameters
+    lambda := &ast.LambdaLiteral{Token: parser.currToken}
+    lambda.Parameters = parser.parseFunctionParameters()
+
+    if len(parser.errors) > state.errors || parser.peekToken.Type != token.ARROW {
+        parser.restore(state)
+        return parser.parseGroupedExpression()
     }
 
-    if !parser.expectPeek(token.IDENT) {
+    return parser.parseLambdaBody(lambda)
+}
+
+// expects the current token to be the last token before "=>"
+func (parser *Parser) parseLambdaBody(lambda *ast.LambdaLiteral) ast.Expression {
+    if !parser.expectPeek(token.ARROW) {
         return nil
     }
 
-    identifier := &ast.Identifier{Token: parser.currToken, Value: parser.currToken.Literal}
-    parameters = append(parameters, identifier)
+    parser.nextToken()
 
-    for parser.peekToken.Type == token.COMMA {
-        parser.nextToken()
-        parser.nextToken()
-        identifier := &ast.Identifier{Token: parser.currToken, Value: parser.currToken.Literal}
-        parameters = append(parameters, identifier)
+    if parser.currToken.Type == token.LBRACE {
+        lambda.Body = parser.parseBlockStatement()
+        return lambda
     }
 
-    if !parser.expectPeek(token.RPAREN) {
+    stmt := &ast.ExpressionStatement{Token: parser.currToken}
+    stmt.Expression = parser.parseExpression(LOWEST)
+    if stmt.Expression == nil {
         return nil
     }
 
-    return parameters
+    lambda.Body = &ast.BlockStatement{Token: stmt.Token, Statements: []ast.Statement{stmt}}
+    return lambda
 }
 
-func (parser *Parser) parseCallExpression(function ast.Expression) ast.Expression {
+func (parser *Parser) parsePipeExpression(left ast.Expression) ast.Expression {
+    pipe := &ast.PipeExpression{Token: parser.currToken, Left: left}
+
+    parser.nextToken()
+    pipe.Right = parser.parseExpression(PIPE)
+
+    return pipe
+}
+
+func (parser *Parser) parseFunctionLiteral() ast.Expression {
+    function := &ast.FunctionLiteral{Token: parser.currToken}
+
+    if !parser.expectPeek(token.LPAREN) {
+        return nil
+    }
+
+    function.Parameters = parser.parseFunctionParameters()
+
+    if !parser.expectPeek(token.LBRACE) {
+        return nil
+    }
+
+    function.Body = parser.parseBlockStatement()
+
+    return function
+}
+
+func (parser *Parser) parseFunctionParameters() []*ast.Parameter {
+    parameters := []*ast.Parameter{}
+
+    if parser.peekToken.Type == token.RPAREN {
+        parser.nextToken()
+        return parameters
+    }
+
+    parser.nextToken()
+    parameters = append(parameters, parser.parseParameter())
+
+    for parser.peekToken.Type == token.COMMA {
+        parser.nextToken()
+        parser.nextToken()
+        parameters = append(parameters, parser.parseParameter())
+    }
+
+    if !parser.expectPeek(token.RPAREN) {
+        return nil
+    }
+
+    if !parser.checkParameters(parameters) {
+        return nil
+    }
+
+    return parameters
+}
+
+// parses "name", "name = default" and "...name"
+func (parser *Parser) parseParameter() *ast.Parameter {
+    param := &ast.Parameter{Token: parser.currToken}
+
+    if parser.currToken.Type == token.ELLIPSIS {
+        param.Rest = true
+        parser.nextToken()
+    }
+
+    switch {
+    case parser.currToken.Type == token.IDENT:
+        param.Name = &ast.Identifier{Token: parser.currToken, Value: parser.currToken.Literal}
+    case !param.Rest && (parser.currToken.Type == token.LBRACKET || parser.currToken.Type == token.LBRACE):
+        param.Pattern = parser.parsePattern()
+        if param.Pattern == nil {
+            return nil
+        }
+    default:
+        msg := fmt.Sprintf("expected parameter name, got %s instead", parser.currToken.Type)
+        parser.errors = append(parser.errors, msg)
+        return nil
+    }
+
+    if !param.Rest && parser.peekToken.Type == token.ASSIGN {
+        parser.nextToken()
+        parser.nextToken()
+        param.Default = parser.parseExpression(LOWEST)
+    }
+
+    return param
+}
+
+// a parameter list is valid if names are unique, the rest parameter comes last
+// and no required parameter follows one with a default value
+func (parser *Parser) checkParameters(parameters []*ast.Parameter) bool {
+    seen := map[string]bool{}
+    hasDefault := false
+
+    for i, param := range parameters {
+        if param == nil {
+            return false
+        }
+
+        var msg string
+        switch {
+        case param.Name != nil && seen[param.Name.Value]:
+            msg = fmt.Sprintf("duplicate parameter: %s", param.Name.Value)
+        case param.Rest && i != len(parameters) - 1:
+            msg = fmt.Sprintf("rest parameter must be last: %s", param.Name.Value)
+        case !param.Rest && param.Default == nil && hasDefault:
+            msg = fmt.Sprintf("required parameter follows parameter with default value: %s", param.String())
+        }
+
+        if msg != "" {
+            parser.errors = append(parser.errors, msg)
+            return false
+        }
+
+        if param.Name != nil {
+            seen[param.Name.Value] = true
+        }
+        hasDefault = hasDefault || param.Default != nil
+    }
+
+    return true
+}
+
+func (parser *Parser) parseCallExpression(function ast.Expression) ast.Expression {
     callExp := &ast.CallExpression{Token: parser.currToken, FunctionLiteral: function}
     callExp.Arguments = parser.parseCallArguments()
 
@@ -496,13 +1058,13 @@ func (parser *Parser) parseArrayLiteral() ast.Expression {
 
     parser.nextToken()
     var expr ast.Expression
-    expr = parser.parseExpression(LOWEST)
+    expr = parser.parseElement()
     elements = append(elements, expr)
 
     for parser.peekToken.Type == token.COMMA {
         parser.nextToken()
         parser.nextToken()
-        expr = parser.parseExpression(LOWEST)
+        expr = parser.parseElement()
         elements = append(elements, expr)
     }
 
@@ -513,6 +1075,19 @@ func (parser *Parser) parseArrayLiteral() ast.Expression {
     return &ast.ArrayLiteral{Elements: elements}
 }
 
+// parses an array element or positional argument, which may be spread
+func (parser *Parser) parseElement() ast.Expression {
+    if parser.currToken.Type != token.ELLIPSIS {
+        return parser.parseExpression(LOWEST)
+    }
+
+    spread := &ast.SpreadExpression{Token: parser.currToken}
+    parser.nextToken()
+    spread.Value = parser.parseExpression(LOWEST)
+
+    return spread
+}
+
 func (parser *Parser) parseCallArguments() []ast.Expression {
     args := []ast.Expression{}
 
@@ -522,30 +1097,66 @@ func (parser *Parser) parseCallArguments() []ast.Expression {
     }
 
     parser.nextToken()
-    args = append(args, parser.parseExpression(LOWEST))
+    args = append(args, parser.parseArgument())
 
     for parser.peekToken.Type == token.COMMA {
         parser.nextToken()
         parser.nextToken()
-        args = append(args, parser.parseExpression(LOWEST))
+        args = append(args, parser.parseArgument())
     }
 
     // TODO: return error instead of nil, then handle error in caller
     if !parser.expectPeek(token.RPAREN) {
         return nil
     }
+
+    keyword := false
+    for _, arg := range args {
+        if _, ok := arg.(*ast.KeywordArgument); ok {
+            keyword = true
+        } else if keyword {
+            parser.errors = append(parser.errors, "positional argument follows keyword argument")
+            return nil
+        }
+    }
+
     return args
 }
 
+// parses a positional argument or a keyword argument such as "b: 3"
+func (parser *Parser) parseArgument() ast.Expression {
+    if parser.currToken.Type != token.IDENT || parser.peekToken.Type != token.COLON {
+        return parser.parseElement()
+    }
+
+    arg := &ast.KeywordArgument{Token: parser.currToken}
+    arg.Name = &ast.Identifier{Token: parser.currToken, Value: parser.currToken.Literal}
+
+    parser.nextToken()
+    parser.nextToken()
+    arg.Value = parser.parseExpression(LOWEST)
+
+    return arg
+}
+
 func (parser *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
     indexExpr := &ast.IndexExpression{
         Token: parser.currToken,
         Left: left,
+        Optional: parser.currToken.Type == token.OPTIONAL_BRACKET,
+    }
+
+    if parser.peekToken.Type == token.COLON {
+        return parser.parseSliceExpression(indexExpr.Token, left, nil)
     }
 
     parser.nextToken()
     index := parser.parseExpression(LOWEST)
 
+    if parser.peekToken.Type == token.COLON {
+        return parser.parseSliceExpression(indexExpr.Token, left, index)
+    }
+
     if !parser.expectPeek(token.RBRACKET) {
         return nil
     }
@@ -554,6 +1165,52 @@ func (parser *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
     return indexExpr
 }
 
+// expects the peek token to be the colon following the start of the slice
+func (parser *Parser) parseSliceExpression(start token.Token, left ast.Expression, from ast.Expression) ast.Expression {
+    slice := &ast.SliceExpression{Token: start, Left: left, Start: from, Optional: start.Type == token.OPTIONAL_BRACKET}
+
+    parser.nextToken()
+    slice.End = parser.parseSliceBound()
+
+    if parser.peekToken.Type == token.COLON {
+        parser.nextToken()
+        slice.Step = parser.parseSliceBound()
+    }
+
+    if !parser.expectPeek(token.RBRACKET) {
+        return nil
+    }
+
+    return slice
+}
+
+// a bound is omitted when the next token is a colon or the closing bracket
+func (parser *Parser) parseSliceBound() ast.Expression {
+    if parser.peekToken.Type == token.COLON || parser.peekToken.Type == token.RBRACKET {
+        return nil
+    }
+
+    parser.nextToken()
+    return parser.parseExpression(LOWEST)
+}
+
+func (parser *Parser) parseMemberExpression(object ast.Expression) ast.Expression {
+    member := &ast.MemberExpression{
+        Token: parser.currToken,
+        Object: object,
+        Optional: parser.currToken.Type == token.OPTIONAL_DOT,
+    }
+
+    if token.IsKeyword(parser.peekToken) {
+        parser.nextToken()
+    } else if !parser.expectPeek(token.IDENT) {
+        return nil
+    }
+
+    member.Property = &ast.Identifier{Token: parser.currToken, Value: parser.currToken.Literal}
+    return member
+}
+
 // TODO: Better error reporting for incorrect grammar of hashmaps
 func (parser *Parser) parseHashMapLiteral() ast.Expression {
     hashMap := &ast.HashMapLiteral{
@@ -565,6 +1222,11 @@ func (parser *Parser) parseHashMapLiteral() ast.Expression {
         parser.nextToken()
         keyExpr := parser.parseExpression(LOWEST)
 
+        // a first entry without a value makes this a set
+        if len(hashMap.Map) == 0 && (parser.peekToken.Type == token.COMMA || parser.peekToken.Type == token.RBRACE) {
+            return parser.parseSetLiteral(hashMap.Token, keyExpr)
+        }
+
         if !parser.expectPeek(token.COLON) {
             return nil
         }
@@ -576,6 +1238,7 @@ func (parser *Parser) parseHashMapLiteral() ast.Expression {
         }
 
         hashMap.Map[keyExpr] = valueExpr
+        hashMap.Keys = append(hashMap.Keys, keyExpr)
         
         if parser.peekToken.Type != token.RBRACE && !parser.expectPeek(token.COMMA) {
             return nil
@@ -589,6 +1252,316 @@ func (parser *Parser) parseHashMapLiteral() ast.Expression {
     return hashMap
 }
 
+// expects the current token to be the end of the first element
+func (parser *Parser) parseSetLiteral(start token.Token, first ast.Expression) ast.Expression {
+    set := &ast.SetLiteral{Token: start, Elements: []ast.Expression{first}}
+
+    for parser.peekToken.Type != token.RBRACE {
+        if !parser.expectPeek(token.COMMA) {
+            return nil
+        }
+        if parser.peekToken.Type == token.RBRACE {
+            break
+        }
+
+        parser.nextToken()
+        element := parser.parseExpression(LOWEST)
+        if element == nil {
+            return nil
+        }
+        set.Elements = append(set.Elements, element)
+    }
+    parser.nextToken()
+
+    return set
+}
+
+func (parser *Parser) parsePattern() ast.Pattern {
+    var pattern ast.Pattern
+
+    switch parser.currToken.Type {
+    case token.IDENT:
+        if parser.peekToken.Type == token.DOT {
+            return parser.parseEnumPattern()
+        }
+        if parser.currToken.Literal == "_" {
+            pattern = &ast.WildcardPattern{Token: parser.currToken}
+        } else {
+            pattern = &ast.Identifier{Token: parser.currToken, Value: parser.currToken.Literal}
+        }
+    case token.LBRACKET:
+        return parser.parseArrayPattern()
+    case token.LBRACE:
+        return parser.parseHashMapPattern()
+    case token.INT, token.FLOAT, token.STRING, token.TRUE, token.FALSE, token.NULL, token.MINUS:
+        return parser.parseLiteralPattern()
+    default:
+        msg := fmt.Sprintf("expected pattern, got %s instead", parser.currToken.Type)
+        parser.errors = append(parser.errors, msg)
+        return nil
+    }
+
+    if parser.peekToken.Type != token.COLON {
+        return pattern
+    }
+
+    parser.nextToken()
+    typePattern := &ast.TypePattern{Token: parser.currToken, Target: pattern}
+
+    if !parser.expectPeek(token.IDENT) {
+        return nil
+    }
+    typePattern.TypeName = &ast.Identifier{Token: parser.currToken, Value: parser.currToken.Literal}
+
+    return typePattern
+}
+
+func (parser *Parser) parseEnumPattern() ast.Pattern {
+    pattern := &ast.EnumPattern{Token: parser.currToken}
+    pattern.Enum = &ast.Identifier{Token: parser.currToken, Value: parser.currToken.Literal}
+
+    parser.nextToken()
+    if !parser.expectPeek(token.IDENT) {
+        return nil
+    }
+    pattern.Variant = &ast.Identifier{Token: parser.currToken, Value: parser.currToken.Literal}
+
+    if parser.peekToken.Type != token.LPAREN {
+        return pattern
+    }
+    parser.nextToken()
+
+    pattern.Payload = []ast.Pattern{}
+    for parser.peekToken.Type != token.RPAREN {
+        parser.nextToken()
+        element := parser.parsePattern()
+        if element == nil {
+            return nil
+        }
+        pattern.Payload = append(pattern.Payload, element)
+
+        if parser.peekToken.Type != token.RPAREN && !parser.expectPeek(token.COMMA) {
+            return nil
+        }
+    }
+    parser.nextToken()
+
+    return pattern
+}
+
+// literal patterns are numbers, optionally negated, strings, booleans and null
+func (parser *Parser) parseLiteralPattern() ast.Pattern {
+    pattern := &ast.LiteralPattern{Token: parser.currToken}
+
+    if parser.currToken.Type == token.MINUS {
+        if parser.peekToken.Type != token.INT && parser.peekToken.Type != token.FLOAT {
+            msg := fmt.Sprintf("expected number after '-' in pattern, got %s instead", parser.peekToken.Type)
+            parser.errors = append(parser.errors, msg)
+            return nil
+        }
+
+        pattern.Value = parser.parsePrefixExpression()
+        return pattern
+    }
+
+    prefix := parser.prefixParseFns[parser.currToken.Type]
+    pattern.Value = prefix()
+    if pattern.Value == nil {
+        return nil
+    }
+
+    return pattern
+}
+
+func (parser *Parser) parseMatchExpression() ast.Expression {
+    match := &ast.MatchExpression{Token: parser.currToken}
+
+    if !parser.expectPeek(token.LPAREN) {
+        return nil
+    }
+
+    parser.nextToken()
+    match.Subject = parser.parseExpression(LOWEST)
+
+    if !parser.expectPeek(token.RPAREN) {
+        return nil
+    }
+
+    if !parser.expectPeek(token.LBRACE) {
+        return nil
+    }
+
+    for parser.peekToken.Type != token.RBRACE {
+        parser.nextToken()
+
+        arm := parser.parseMatchArm()
+        if arm == nil {
+            return nil
+        }
+        match.Arms = append(match.Arms, arm)
+
+        // arms with a block body don't need a separating comma
+        blockBody := arm.Body.Token.Type == token.LBRACE
+        if parser.peekToken.Type == token.COMMA {
+            parser.nextToken()
+        } else if parser.peekToken.Type != token.RBRACE && !blockBody {
+            parser.Err(token.COMMA)
+            return nil
+        }
+    }
+
+    if !parser.expectPeek(token.RBRACE) {
+        return nil
+    }
+
+    if len(match.Arms) == 0 {
+        parser.errors = append(parser.errors, "match expression has no arms")
+        return nil
+    }
+
+    return match
+}
+
+func (parser *Parser) parseMatchArm() *ast.MatchArm {
+    arm := &ast.MatchArm{}
+
+    arm.Pattern = parser.parsePattern()
+    if arm.Pattern == nil {
+        return nil
+    }
+
+    if parser.peekToken.Type == token.IF {
+        parser.nextToken()
+        parser.nextToken()
+        arm.Guard = parser.parseExpression(LOWEST)
+    }
+
+    if !parser.expectPeek(token.ARROW) {
+        return nil
+    }
+
+    parser.nextToken()
+
+    if parser.currToken.Type == token.LBRACE {
+        arm.Body = parser.parseBlockStatement()
+        return arm
+    }
+
+    stmt := &ast.ExpressionStatement{Token: parser.currToken}
+    stmt.Expression = parser.parseExpression(LOWEST)
+    if stmt.Expression == nil {
+        return nil
+    }
+
+    arm.Body = &ast.BlockStatement{Token: stmt.Token, Statements: []ast.Statement{stmt}}
+    return arm
+}
+
+// parses a pattern nested in an array or hashmap pattern, which may have a
+// default value
+func (parser *Parser) parsePatternElement() ast.Pattern {
+    target := parser.parsePattern()
+    if target == nil {
+        return nil
+    }
+
+    if parser.peekToken.Type != token.ASSIGN {
+        return target
+    }
+
+    parser.nextToken()
+    pattern := &ast.DefaultPattern{Token: parser.currToken, Target: target}
+
+    parser.nextToken()
+    pattern.Default = parser.parseExpression(LOWEST)
+    if pattern.Default == nil {
+        return nil
+    }
+
+    return pattern
+}
+
+func (parser *Parser) parseArrayPattern() ast.Pattern {
+    pattern := &ast.ArrayPattern{Token: parser.currToken}
+
+    for parser.peekToken.Type != token.RBRACKET {
+        parser.nextToken()
+
+        if parser.currToken.Type == token.ELLIPSIS {
+            if !parser.expectPeek(token.IDENT) {
+                return nil
+            }
+            pattern.Rest = &ast.Identifier{Token: parser.currToken, Value: parser.currToken.Literal}
+            break
+        }
+
+        element := parser.parsePatternElement()
+        if element == nil {
+            return nil
+        }
+        pattern.Elements = append(pattern.Elements, element)
+
+        if parser.peekToken.Type != token.RBRACKET && !parser.expectPeek(token.COMMA) {
+            return nil
+        }
+    }
+
+    if !parser.expectPeek(token.RBRACKET) {
+        return nil
+    }
+
+    return pattern
+}
+
+// identifier keys name string keys, so "{name: n}" reads the "name" key
+func (parser *Parser) parseHashMapPattern() ast.Pattern {
+    pattern := &ast.HashMapPattern{Token: parser.currToken}
+
+    for parser.peekToken.Type != token.RBRACE {
+        parser.nextToken()
+        entry := &ast.HashMapPatternEntry{}
+
+        switch parser.currToken.Type {
+        case token.IDENT:
+            entry.Key = &ast.StringLiteral{Token: parser.currToken, Value: parser.currToken.Literal}
+            entry.Shorthand = parser.peekToken.Type != token.COLON
+        case token.STRING:
+            entry.Key = parser.parseStringLiteral()
+        case token.INT:
+            entry.Key = parser.parseIntegerLiteral()
+        case token.TRUE, token.FALSE:
+            entry.Key = parser.parseBooleanLiteral()
+        default:
+            msg := fmt.Sprintf("expected hashmap pattern key, got %s instead", parser.currToken.Type)
+            parser.errors = append(parser.errors, msg)
+            return nil
+        }
+
+        if !entry.Shorthand {
+            if !parser.expectPeek(token.COLON) {
+                return nil
+            }
+            parser.nextToken()
+        }
+
+        entry.Value = parser.parsePatternElement()
+        if entry.Key == nil || entry.Value == nil {
+            return nil
+        }
+        pattern.Entries = append(pattern.Entries, entry)
+
+        if parser.peekToken.Type != token.RBRACE && !parser.expectPeek(token.COMMA) {
+            return nil
+        }
+    }
+
+    if !parser.expectPeek(token.RBRACE) {
+        return nil
+    }
+
+    return pattern
+}
+
 func (parser *Parser) noPrefixFnError(token token.TokenType) {
     // msg := fmt.Sprintf("no prefix parse function for %s found", token)
     msg := fmt.Sprintf("unexpected token: '%s'", token)
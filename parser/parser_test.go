@@ -46,6 +46,92 @@ func TestLetStatements(t *testing.T) {
     }
 }
 
+func TestDeclarationStatements(t *testing.T) {
+    tests := []struct {
+        input string
+        expectedIdentifier string
+        expectedValue any
+        expectedConstant bool
+    } {
+        {"let x = 5;", "x", 5, false},
+        {"let y = true;", "y", true, false},
+        {"let z;", "z", nil, false},
+        {"const foobar = y;", "foobar", "y", true},
+    }
+
+    for _, test := range tests {
+        lexer := lexer.New(test.input)
+        parser := New(lexer)
+        program := parser.ParseProgram()
+
+        checkParserErrors(t, parser)
+
+        if len(program.Statements) != 1 {
+            t.Fatalf("Expected 1 statements. Got %d\n", len(program.Statements))
+        }
+
+        stmt, ok := program.Statements[0].(*ast.LetStatement)
+        if !ok {
+            t.Fatalf("stmt not *ast.LetStatement. got=%T", program.Statements[0])
+        }
+
+        if stmt.Identifier.Value != test.expectedIdentifier {
+            t.Errorf("stmt.Identifier.Value not %s. got=%s", test.expectedIdentifier, stmt.Identifier.Value)
+        }
+
+        if stmt.IsConstant() != test.expectedConstant {
+            t.Errorf("stmt.IsConstant() not %t", test.expectedConstant)
+        }
+
+        if test.expectedValue == nil {
+            if stmt.Value != nil {
+                t.Errorf("stmt.Value not nil. got=%s", stmt.Value.String())
+            }
+            continue
+        }
+
+        if !testLiteralExpression(t, stmt.Value, test.expectedValue) {
+            return
+        }
+
+        if stmt.String() != test.input {
+            t.Errorf("stmt.String() wrong. expected=%q, got=%q", test.input, stmt.String())
+        }
+    }
+}
+
+func TestDestructuringStatements(t *testing.T) {
+    tests := []struct {
+        input string
+        expected string
+    } {
+        {"let [a, b] = x;", "let [a, b] = x;"},
+        {"let [a, b = 2, ...rest] = x;", "let [a, b = 2, ...rest] = x;"},
+        {"const {name, age: years} = person;", "const {name, age: years} = person;"},
+        {`let {"full name": full, 1: one, true: yes} = x;`, "let {full name: full, 1: one, true: yes} = x;"},
+        {"let {name = 1, pos: [x, y]} = p;", "let {name = 1, pos: [x, y]} = p;"},
+        {"[a, b] = [b, a];", "[a, b] = [b, a];"},
+        {"{name, age} = person;", "{name, age} = person;"},
+        {"[[a, b], {c}] = x;", "[[a, b], {c}] = x;"},
+        {"[1, 2];", "[1, 2]"},
+        {`{"a": 1};`, "{a:1}"},
+        {"[a, b][0];", "([a, b][0])"},
+        {"func f([a, b], {c} = x) { a; }", "func f([a, b], {c} = x) a"},
+    }
+
+    for _, test := range tests {
+        lexer := lexer.New(test.input)
+        parser := New(lexer)
+        program := parser.ParseProgram()
+
+        checkParserErrors(t, parser)
+
+        if program.String() != test.expected {
+            t.Errorf("expected=%q, got=%q", test.expected, program.String())
+        }
+    }
+}
+
 func TestReturnStatement(t *testing.T) {
     tests := []struct {
         input string
@@ -134,6 +220,33 @@ func TestError(t *testing.T) {
         {"x 1234;", "expected next token to be ;, got INT instead"},
         {"x = 1234 5;", "expected next token to be ;, got INT instead"},
         {" = 5;", "unexpected token: '='"},
+        {"const x;", "missing value in const declaration: x"},
+        {"let 5 = x;", "expected next token to be IDENT, got INT instead"},
+        {"func f(a, a) {}", "duplicate parameter: a"},
+        {"func f(...a, b) {}", "rest parameter must be last: a"},
+        {"func f(a = 1, b) {}", "required parameter follows parameter with default value: b"},
+        {"func f(1) {}", "expected parameter name, got INT instead"},
+        {"f(a: 1, 2);", "positional argument follows keyword argument"},
+        {"let [a, b];", "missing value in destructuring declaration: [a, b]"},
+        {"match (x) { };", "match expression has no arms"},
+        {"match (x) { 1 => a 2 => b };", "expected next token to be ,, got INT instead"},
+        {"match (x) { - a => 1 };", "expected number after '-' in pattern, got IDENT instead"},
+        {"let [a, *] = x;", "expected pattern, got * instead"},
+        {"struct P { 1; }", "expected field or method in struct P, got INT instead"},
+        {"struct P { x; func x() {} }", "duplicate member in struct P: x"},
+        {"struct P { x }", "expected next token to be ;, got } instead"},
+        {"f() = 1;", "cannot assign to f()"},
+        {"enum E { }", "enum E has no variants"},
+        {"enum E { A, A }", "duplicate variant in enum E: A"},
+        {"enum E { A B }", "expected next token to be ,, got IDENT instead"},
+        {"enum E { A(1) }", "expected next token to be IDENT, got INT instead"},
+        {"a not b;", "expected next token to be IN, got IDENT instead"},
+        {"import utils;", "expected next token to be STRING, got IDENT instead"},
+        {`"a ${} b";`, "empty expression in template string"},
+        {`"a ${x y} b";`, "expected } to close template expression, got IDENT instead"},
+        {`import { a } "x";`, "expected from after import list, got x instead"},
+        {`import { } from "x";`, "import list is empty"},
+        {"export x = 1;", "expected declaration after export, got IDENT instead"},
     }
 
     for i, test := range tests {
@@ -489,6 +602,46 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
             "add(a * b[2], b[1], 2 * [1, 2][1]);",
             "add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
         },
+        {
+            "a + b |> f(c) |> g;",
+            "(((a + b) |> f(c)) |> g)",
+        },
+        {
+            "a |> f == b;",
+            "(a |> (f == b))",
+        },
+        {
+            "a.b.c(d)[1];",
+            "(a.b.c(d)[1])",
+        },
+        {
+            "-s.len() + x.y * 2;",
+            "((-s.len()) + (x.y * 2))",
+        },
+        {
+            "a[1:2] + b[:n - 1] + c[::-1] + d[i:];",
+            "((((a[1:2]) + (b[:(n - 1)])) + (c[::(-1)])) + (d[i:]))",
+        },
+        {
+            "a[:][1:2:3];",
+            "((a[:])[1:2:3])",
+        },
+        {
+            "a in b == c not in d;",
+            "((a in b) == (c not in d))",
+        },
+        {
+            "x + 1 in xs;",
+            "((x + 1) in xs)",
+        },
+        {
+            "a ?? b ?? c == d;",
+            "((a ?? b) ?? (c == d))",
+        },
+        {
+            "a?.b?[0].c ?? d?[1:];",
+            "((a?.b?[0]).c ?? (d?[1:]))",
+        },
     }
 
     for i, tt := range tests {
@@ -526,6 +679,38 @@ func TestParsingIndexExpressions(t *testing.T) {
     }
 }
 
+func TestParsingMemberExpressions(t *testing.T) {
+    tests := []struct {
+        input string
+        object string
+        property string
+    } {
+        {"person.name;", "person", "name"},
+        {"re.match;", "re", "match"},
+        {"config?.import;", "config", "import"},
+    }
+
+    for _, test := range tests {
+        l := lexer.New(test.input)
+        p := New(l)
+        program := p.ParseProgram()
+
+        checkParserErrors(t, p)
+
+        stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+        member, ok := stmt.Expression.(*ast.MemberExpression)
+        if !ok {
+            t.Fatalf("exp not *ast.MemberExpression. got=%T", stmt.Expression)
+        }
+        if !testIdentifier(t, member.Object, test.object) {
+            return
+        }
+        if !testIdentifier(t, member.Property, test.property) {
+            return
+        }
+    }
+}
+
 func TestIfStatement(t *testing.T) {
     input := `if (x < y) { x; }`
 
@@ -640,8 +825,8 @@ func TestParseFunctionLiteral(t *testing.T) {
         t.Fatalf("function literal parameters wrong. want 2, got=%d\n",
             len(function.Parameters))
     }
-    testLiteralExpression(t, function.Parameters[0], "x")
-    testLiteralExpression(t, function.Parameters[1], "y")
+    testLiteralExpression(t, function.Parameters[0].Name, "x")
+    testLiteralExpression(t, function.Parameters[1].Name, "y")
     if len(function.Body.Statements) != 1 {
         t.Fatalf("function.Body.Statements has not 1 statements. got=%d\n",
             len(function.Body.Statements))
@@ -680,8 +865,8 @@ func TestParseFunctionStatement(t *testing.T) {
         t.Fatalf("function literal parameters wrong. want 2, got=%d\n",
             len(function.Parameters))
     }
-    testLiteralExpression(t, function.Parameters[0], "x")
-    testLiteralExpression(t, function.Parameters[1], "y")
+    testLiteralExpression(t, function.Parameters[0].Name, "x")
+    testLiteralExpression(t, function.Parameters[1].Name, "y")
     if len(function.Body.Statements) != 1 {
         t.Fatalf("function.Body.Statements has not 1 statements. got=%d\n",
             len(function.Body.Statements))
@@ -702,6 +887,9 @@ func TestParseFunctionParameters(t *testing.T) {
         {input: "func() {};", expected: []string{}},
         {input: "func(x) {};", expected: []string{"x"}},
         {input: "func(x, y, z) {};", expected: []string{"x", "y", "z"}},
+        {input: "func(x, y = 2) {};", expected: []string{"x", "y = 2"}},
+        {input: "func(x, y = x + 1, ...rest) {};", expected: []string{"x", "y = (x + 1)", "...rest"}},
+        {input: "func(...rest) {};", expected: []string{"...rest"}},
     }
 
     for _, test := range tests {
@@ -725,9 +913,9 @@ func TestParseFunctionParameters(t *testing.T) {
             t.Fatalf("Expected %d parameters. Got=%d\n", len(test.expected), len(fnLit.Parameters))
         }
 
-        for i, ident := range fnLit.Parameters {
-            if !testIdentifier(t, ident, test.expected[i]) {
-                return
+        for i, param := range fnLit.Parameters {
+            if param.String() != test.expected[i] {
+                t.Errorf("parameter %d wrong. expected=%q, got=%q", i, test.expected[i], param.String())
             }
         }
     }
@@ -772,6 +960,304 @@ func TestParseCallExpression(t *testing.T) {
     testInfixExpression(t, callExp.Arguments[2], 4, "+", 5)
 }
 
+func TestParseCallArguments(t *testing.T) {
+    tests := []struct {
+        input string
+        expected string
+    } {
+        {"f(...xs);", "f(...xs)"},
+        {"f(1, ...xs, 2);", "f(1, ...xs, 2)"},
+        {"f(a: 1);", "f(a: 1)"},
+        {"f(1, b: 2 * 3, c: x);", "f(1, b: (2 * 3), c: x)"},
+        {"[1, ...xs];", "[1, ...xs]"},
+    }
+
+    for _, test := range tests {
+        lexer := lexer.New(test.input)
+        parser := New(lexer)
+        program := parser.ParseProgram()
+
+        checkParserErrors(t, parser)
+
+        if program.String() != test.expected {
+            t.Errorf("expected=%q, got=%q", test.expected, program.String())
+        }
+    }
+}
+
+func TestParseLambdaLiteral(t *testing.T) {
+    tests := []struct {
+        input string
+        expectedParams []string
+        expectedBody string
+    } {
+        {"x => x * 2;", []string{"x"}, "(x * 2)"},
+        {"() => 1;", []string{}, "1"},
+        {"(x) => x;", []string{"x"}, "x"},
+        {"(a, b = 2, ...rest) => a + b;", []string{"a", "b = 2", "...rest"}, "(a + b)"},
+        {"(a, b) => { return a + b; };", []string{"a", "b"}, "return (a + b);"},
+        {"x => y => x + y;", []string{"x"}, "(y) => (x + y)"},
+    }
+
+    for _, test := range tests {
+        lexer := lexer.New(test.input)
+        parser := New(lexer)
+        program := parser.ParseProgram()
+
+        checkParserErrors(t, parser)
+
+        if len(program.Statements) != 1 {
+            t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+        }
+
+        stmt := program.Statements[0].(*ast.ExpressionStatement)
+        lambda, ok := stmt.Expression.(*ast.LambdaLiteral)
+        if !ok {
+            t.Fatalf("stmt.Expression is not *ast.LambdaLiteral. got=%T", stmt.Expression)
+        }
+
+        if len(lambda.Parameters) != len(test.expectedParams) {
+            t.Fatalf("expected %d parameters. got=%d", len(test.expectedParams), len(lambda.Parameters))
+        }
+
+        for i, param := range lambda.Parameters {
+            if param.String() != test.expectedParams[i] {
+                t.Errorf("parameter %d wrong. expected=%q, got=%q", i, test.expectedParams[i], param.String())
+            }
+        }
+
+        if lambda.Body.String() != test.expectedBody {
+            t.Errorf("body wrong. expected=%q, got=%q", test.expectedBody, lambda.Body.String())
+        }
+    }
+}
+
+func TestGroupedExpressionIsNotLambda(t *testing.T) {
+    input := "(a + b) * (c);"
+
+    lexer := lexer.New(input)
+    parser := New(lexer)
+    program := parser.ParseProgram()
+
+    checkParserErrors(t, parser)
+
+    if program.String() != "((a + b) * c)" {
+        t.Errorf("program.String() wrong. got=%q", program.String())
+    }
+}
+
+func TestParseStructStatement(t *testing.T) {
+    input := `struct Point {
+        x;
+        y = 0;
+        func norm() { return self.x * self.x + self.y * self.y; }
+    }`
+
+    lexer := lexer.New(input)
+    parser := New(lexer)
+    program := parser.ParseProgram()
+
+    checkParserErrors(t, parser)
+
+    if len(program.Statements) != 1 {
+        t.Fatalf("expected 1 statement. got=%d", len(program.Statements))
+    }
+
+    stmt, ok := program.Statements[0].(*ast.StructStatement)
+    if !ok {
+        t.Fatalf("stmt not *ast.StructStatement. got=%T", program.Statements[0])
+    }
+
+    if stmt.Name.Value != "Point" {
+        t.Errorf("struct name wrong. got=%q", stmt.Name.Value)
+    }
+
+    if len(stmt.Fields) != 2 || stmt.Fields[0].String() != "x" || stmt.Fields[1].String() != "y = 0" {
+        t.Errorf("fields wrong. got=%v", stmt.Fields)
+    }
+
+    if len(stmt.Methods) != 1 || stmt.Methods[0].Identifier.Value != "norm" {
+        t.Errorf("methods wrong. got=%v", stmt.Methods)
+    }
+}
+
+func TestParseEnumStatement(t *testing.T) {
+    tests := []struct {
+        input string
+        expected string
+    } {
+        {"enum Color { Red, Green, Blue }", "enum Color { Red, Green, Blue }"},
+        {"enum Result { Ok(value), Err(message), }", "enum Result { Ok(value), Err(message) }"},
+        {"enum Shape { Rect(w, h), Empty() }", "enum Shape { Rect(w, h), Empty() }"},
+    }
+
+    for _, test := range tests {
+        lexer := lexer.New(test.input)
+        parser := New(lexer)
+        program := parser.ParseProgram()
+
+        checkParserErrors(t, parser)
+
+        stmt, ok := program.Statements[0].(*ast.EnumStatement)
+        if !ok {
+            t.Fatalf("stmt not *ast.EnumStatement. got=%T", program.Statements[0])
+        }
+
+        if stmt.String() != test.expected {
+            t.Errorf("expected=%q, got=%q", test.expected, stmt.String())
+        }
+    }
+}
+
+func TestParseSetAndTupleLiterals(t *testing.T) {
+    tests := []struct {
+        input string
+        expected string
+    } {
+        {"(1, 2);", "(1, 2)"},
+        {"(1 + 2,);", "((1 + 2),)"},
+        {"();", "()"},
+        {"(1 + 2) * 3;", "((1 + 2) * 3)"},
+        {"((a, b), c);", "((a, b), c)"},
+        {"{1, 2, 3};", "{1, 2, 3}"},
+        {"{x};", "{x}"},
+        {"{a + b, c,};", "{(a + b), c}"},
+        {"let s = {1};", "let s = {1};"},
+    }
+
+    for _, test := range tests {
+        lexer := lexer.New(test.input)
+        parser := New(lexer)
+        program := parser.ParseProgram()
+
+        checkParserErrors(t, parser)
+
+        if program.String() != test.expected {
+            t.Errorf("expected=%q, got=%q", test.expected, program.String())
+        }
+    }
+}
+
+func TestParseTemplateLiteral(t *testing.T) {
+    tests := []struct {
+        input string
+        expected string
+        parts int
+    } {
+        {`"Hello ${name}";`, "Hello ${name}", 3},
+        {`"${a + 1}${b}";`, "${(a + 1)}${b}", 5},
+        {`"${ {"k": 1}["k"] }!";`, "${({k:1}[k])}!", 3},
+        {`"outer ${"inner ${x}"}";`, "outer ${inner ${x}}", 3},
+    }
+
+    for _, test := range tests {
+        lexer := lexer.New(test.input)
+        parser := New(lexer)
+        program := parser.ParseProgram()
+
+        checkParserErrors(t, parser)
+
+        stmt := program.Statements[0].(*ast.ExpressionStatement)
+        template, ok := stmt.Expression.(*ast.TemplateLiteral)
+        if !ok {
+            t.Fatalf("expression is not *ast.TemplateLiteral. got=%T", stmt.Expression)
+        }
+
+        if len(template.Parts) != test.parts {
+            t.Errorf("wrong number of parts. expected=%d, got=%d", test.parts, len(template.Parts))
+        }
+
+        if template.String() != test.expected {
+            t.Errorf("expected=%q, got=%q", test.expected, template.String())
+        }
+    }
+}
+
+func TestParseImportAndExportStatements(t *testing.T) {
+    tests := []struct {
+        input string
+        expected string
+    } {
+        {`import "lib/utils";`, `import "lib/utils";`},
+        {`import { a, b } from "./utils";`, `import { a, b } from "./utils";`},
+        {"export let x = 1;", "export let x = 1;"},
+        {"export const [a, b] = pair;", "export const [a, b] = pair;"},
+        {"export func f(a) { a; }", "export func f(a) a"},
+        {"export enum E { A }", "export enum E { A }"},
+        {"let from = 1;", "let from = 1;"},
+    }
+
+    for _, test := range tests {
+        lexer := lexer.New(test.input)
+        parser := New(lexer)
+        program := parser.ParseProgram()
+
+        checkParserErrors(t, parser)
+
+        if len(program.Statements) != 1 {
+            t.Fatalf("expected 1 statement for %q. got=%d", test.input, len(program.Statements))
+        }
+
+        if program.String() != test.expected {
+            t.Errorf("expected=%q, got=%q", test.expected, program.String())
+        }
+    }
+}
+
+func TestTargetAssignment(t *testing.T) {
+    tests := []struct {
+        input string
+        expected string
+    } {
+        {"self.x = 1;", "self.x = 1;"},
+        {"arr[i + 1] = x * 2;", "(arr[(i + 1)]) = (x * 2);"},
+        {"a.b[0].c = 3;", "(a.b[0]).c = 3;"},
+    }
+
+    for _, test := range tests {
+        lexer := lexer.New(test.input)
+        parser := New(lexer)
+        program := parser.ParseProgram()
+
+        checkParserErrors(t, parser)
+
+        stmt, ok := program.Statements[0].(*ast.AssignmentStatement)
+        if !ok {
+            t.Fatalf("stmt not *ast.AssignmentStatement. got=%T", program.Statements[0])
+        }
+
+        if stmt.String() != test.expected {
+            t.Errorf("expected=%q, got=%q", test.expected, stmt.String())
+        }
+    }
+}
+
+func TestParseMatchExpression(t *testing.T) {
+    tests := []struct {
+        input string
+        expected string
+    } {
+        {"match (x) { 1 => a, _ => b };", "match (x) { 1 => a, _ => b }"},
+        {"match (x) { -1 => a, 2.5 => b, \"s\" => c, true => d, null => e }", "match (x) { (-1) => a, 2.5 => b, s => c, true => d, null => e }"},
+        {"match (x) { n: Integer if n > 0 => n, _: String => 0 }", "match (x) { n: Integer if (n > 0) => n, _: String => 0 }"},
+        {"match (x) { [first, ...rest] => first, {name, age: a} => name }", "match (x) { [first, ...rest] => first, {name, age: a} => name }"},
+        {"match (x) { [1, _] => { y; } _ => { z; } }", "match (x) { [1, _] => y, _ => z }"},
+        {"let y = match (x) { _ => 1 } + 1;", "let y = (match (x) { _ => 1 } + 1);"},
+    }
+
+    for _, test := range tests {
+        lexer := lexer.New(test.input)
+        parser := New(lexer)
+        program := parser.ParseProgram()
+
+        checkParserErrors(t, parser)
+
+        if program.String() != test.expected {
+            t.Errorf("expected=%q, got=%q", test.expected, program.String())
+        }
+    }
+}
+
 func TestStringLiteralExpression(t *testing.T) {
     input := `"hello world";`
 
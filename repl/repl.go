@@ -6,18 +6,17 @@ import (
 	"charm/lexer"
 	"charm/object"
 	"charm/parser"
-	"fmt"
 	"io"
 )
 
 const PROMPT = ">> "
 
-func Start(in io.Reader, out io.Writer) {
+func Start(in io.Reader, out io.Writer, runtime *object.Runtime) {
     scanner := bufio.NewScanner(in)
-    environment := object.NewEnvironment()
+    environment := object.NewEnvironmentWithRuntime(runtime)
 
     for {
-        fmt.Printf(PROMPT)
+        io.WriteString(out, PROMPT)
         scanned := scanner.Scan()
 
         if !scanned {
@@ -39,6 +38,9 @@ func Start(in io.Reader, out io.Writer) {
         }
 
         evaluated := evaluator.Eval(program, environment)
+        if err, ok := evaluated.(*object.Error); ok && err.Exit {
+            return
+        }
         if evaluated != nil {
             io.WriteString(out, evaluated.Inspect())
             io.WriteString(out,"\n")
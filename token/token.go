@@ -12,6 +12,12 @@ const (
 	EOF     = "EOF"
     STRING  = "STRING"
 
+    // Template strings such as "a ${x} b ${y} c" are lexed as
+    // TEMPLATE_HEAD "a ", x, TEMPLATE_MIDDLE " b ", y, TEMPLATE_TAIL " c"
+    TEMPLATE_HEAD   = "TEMPLATE_HEAD"
+    TEMPLATE_MIDDLE = "TEMPLATE_MIDDLE"
+    TEMPLATE_TAIL   = "TEMPLATE_TAIL"
+
 	// Identifiers + literals
 	IDENT = "IDENT" // add, foobar, x, y, ...
 	INT   = "INT"   // 1343456
@@ -33,10 +39,19 @@ const (
 	EQ     = "=="
 	NOT_EQ = "!="
 
+	ARROW = "=>"
+	PIPE  = "|>"
+
+	NULLISH          = "??"
+	OPTIONAL_DOT     = "?."
+	OPTIONAL_BRACKET = "?["
+
 	// Delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
     COLON     = ":"
+    ELLIPSIS  = "..."
+    DOT       = "."
 
 	LPAREN = "("
 	RPAREN = ")"
@@ -53,6 +68,16 @@ const (
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
     WHILE    = "WHILE"
+    LET      = "LET"
+    CONST    = "CONST"
+    MATCH    = "MATCH"
+    NULL     = "NULL"
+    STRUCT   = "STRUCT"
+    ENUM     = "ENUM"
+    IN       = "IN"
+    NOT      = "NOT"
+    IMPORT   = "IMPORT"
+    EXPORT   = "EXPORT"
 )
 
 var keywords = map[string]TokenType {
@@ -63,6 +88,23 @@ var keywords = map[string]TokenType {
     "else": ELSE,
     "return": RETURN,
     "while": WHILE,
+    "let": LET,
+    "const": CONST,
+    "match": MATCH,
+    "null": NULL,
+    "struct": STRUCT,
+    "class": STRUCT,
+    "enum": ENUM,
+    "in": IN,
+    "not": NOT,
+    "import": IMPORT,
+    "export": EXPORT,
+}
+
+// IsKeyword reports whether tok is a keyword, which can still name a member
+// after a dot, as in "re.match"
+func IsKeyword(tok Token) bool {
+    return tok.Type != IDENT && keywords[tok.Literal] == tok.Type
 }
 
 func LookupIdentifier(identifier string) TokenType {